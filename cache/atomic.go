@@ -0,0 +1,29 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// AtomicCache is an extension of MemoryCache for implementations that support atomic
+// read-modify-write operations, e.g. for usage quotas & idempotency keys
+type AtomicCache interface {
+	MemoryCache
+
+	// add the delta to the integer counter for the key & return the new value; a
+	// missing counter starts at 0 & is created with the supplied TTL, the TTL of
+	// an existing counter is not changed. Counters can be read with Fetch into an
+	// *int64
+	Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// subtract the delta from the integer counter for the key, see Incr
+	Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+
+	// store the value with the TTL only if the key does not exist, return true if stored
+	SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error)
+
+	// replace the value with new & the TTL only if the current value equals old,
+	// return true if swapped. A missing key never equals old. Redis compares the
+	// encoded values, so values containing maps may not compare equal
+	CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error)
+}
@@ -0,0 +1,150 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// atomicCaches returns the AtomicCache implementations under test.
+func atomicCaches(t *testing.T) map[string]AtomicCache {
+	r, _ := newTestRedisCache(t)
+	r2, _ := newTestRedisCache(t)
+	return map[string]AtomicCache{
+		"ram":    newTestRamCache(t),
+		"redis":  r,
+		"tiered": newTestTieredCache(t, r2, TieredConfig{}),
+	}
+}
+
+func TestAtomicCache_Incr(t *testing.T) {
+	for name, c := range atomicCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if n, err := c.Incr(ctx, "n", 2, time.Minute); err != nil || n != 2 {
+				t.Fatalf("Incr = (%v, %v); want 2", n, err)
+			}
+			if n, err := c.Incr(ctx, "n", 3, NoExpiry); err != nil || n != 5 {
+				t.Errorf("Incr = (%v, %v); want 5", n, err)
+			}
+			if n, err := c.Decr(ctx, "n", 1, NoExpiry); err != nil || n != 4 {
+				t.Errorf("Decr = (%v, %v); want 4", n, err)
+			}
+
+			var got int64
+			ttl, err := c.FetchWithTtl(ctx, "n", &got)
+			if err != nil || got != 4 {
+				t.Errorf("FetchWithTtl = (%v, %v); want 4", got, err)
+			}
+			if ttl == nil || *ttl <= 0 || *ttl > time.Minute {
+				t.Errorf("FetchWithTtl ttl = %v; want the creation ttl", ttl)
+			}
+		})
+	}
+}
+
+func TestAtomicCache_Incr_Concurrent(t *testing.T) {
+	for name, c := range atomicCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			var wg sync.WaitGroup
+			for i := 0; i < 50; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, _ = c.Incr(ctx, "n", 1, NoExpiry)
+				}()
+			}
+			wg.Wait()
+
+			if n, err := c.Incr(ctx, "n", 0, NoExpiry); err != nil || n != 50 {
+				t.Errorf("Incr = (%v, %v); want 50", n, err)
+			}
+		})
+	}
+}
+
+func TestAtomicCache_SetIfAbsent(t *testing.T) {
+	for name, c := range atomicCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if ok, err := c.SetIfAbsent(ctx, "k", "first", time.Minute); err != nil || !ok {
+				t.Fatalf("SetIfAbsent = (%v, %v); want true", ok, err)
+			}
+			if ok, err := c.SetIfAbsent(ctx, "k", "second", time.Minute); err != nil || ok {
+				t.Errorf("SetIfAbsent = (%v, %v); want false", ok, err)
+			}
+
+			var got string
+			if err := c.Fetch(ctx, "k", &got); err != nil || got != "first" {
+				t.Errorf("Fetch = (%v, %v); want first", got, err)
+			}
+		})
+	}
+}
+
+func TestAtomicCache_CompareAndSwap(t *testing.T) {
+	for name, c := range atomicCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if ok, err := c.CompareAndSwap(ctx, "k", serdeTestValue{Name: "a"}, serdeTestValue{Name: "b"}, NoExpiry); err != nil || ok {
+				t.Errorf("CompareAndSwap(missing) = (%v, %v); want false", ok, err)
+			}
+
+			_ = c.Put(ctx, "k", serdeTestValue{Name: "a"})
+			if ok, err := c.CompareAndSwap(ctx, "k", serdeTestValue{Name: "x"}, serdeTestValue{Name: "b"}, NoExpiry); err != nil || ok {
+				t.Errorf("CompareAndSwap(x) = (%v, %v); want false", ok, err)
+			}
+			if ok, err := c.CompareAndSwap(ctx, "k", serdeTestValue{Name: "a"}, serdeTestValue{Name: "b"}, time.Minute); err != nil || !ok {
+				t.Errorf("CompareAndSwap(a) = (%v, %v); want true", ok, err)
+			}
+
+			var got serdeTestValue
+			ttl, err := c.FetchWithTtl(ctx, "k", &got)
+			if err != nil || got.Name != "b" {
+				t.Errorf("FetchWithTtl = (%+v, %v); want b", got, err)
+			}
+			if ttl == nil || *ttl <= 0 || *ttl > time.Minute {
+				t.Errorf("FetchWithTtl ttl = %v; want the swap ttl", ttl)
+			}
+		})
+	}
+}
+
+func TestAtomicCache_CompareAndSwap_Counter(t *testing.T) {
+	for name, c := range atomicCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_, _ = c.Incr(ctx, "n", 5, NoExpiry)
+			if ok, err := c.CompareAndSwap(ctx, "n", int64(5), int64(0), NoExpiry); err != nil || !ok {
+				t.Errorf("CompareAndSwap = (%v, %v); want true", ok, err)
+			}
+		})
+	}
+}
+
+func TestRamCache_Incr_NotCounter(t *testing.T) {
+	c := newTestRamCache(t)
+	_ = c.Put(context.Background(), "k", "text")
+	if _, err := c.Incr(context.Background(), "k", 1, NoExpiry); err == nil {
+		t.Errorf("Incr returned nil error; want error")
+	}
+}
+
+func TestNilCache_Atomic(t *testing.T) {
+	ctx := context.Background()
+	c := &NilCache{}
+	if n, err := c.Incr(ctx, "n", 3, NoExpiry); err != nil || n != 3 {
+		t.Errorf("Incr = (%v, %v); want 3", n, err)
+	}
+	if n, err := c.Decr(ctx, "n", 3, NoExpiry); err != nil || n != -3 {
+		t.Errorf("Decr = (%v, %v); want -3", n, err)
+	}
+	if ok, err := c.SetIfAbsent(ctx, "k", "v", NoExpiry); err != nil || !ok {
+		t.Errorf("SetIfAbsent = (%v, %v); want true", ok, err)
+	}
+	if ok, err := c.CompareAndSwap(ctx, "k", "v", "w", NoExpiry); err != nil || ok {
+		t.Errorf("CompareAndSwap = (%v, %v); want false", ok, err)
+	}
+}
@@ -0,0 +1,69 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Item is a key, value & TTL to store with PutMany
+type Item struct {
+	Key string
+	Val any
+	Ttl time.Duration // NoExpiry stores the item without expiry
+}
+
+// BulkCache is an extension of MemoryCache for implementations that can operate on
+// many keys in a single round trip
+type BulkCache interface {
+	MemoryCache
+
+	// fetch the values for the supplied keys into the supplied map[string]T; keys
+	// that are not found are not added to the map
+	FetchMany(context.Context, []string, any) error
+
+	// store the supplied items, each with its own TTL
+	PutMany(context.Context, []Item) error
+
+	// delete all keys that start with the supplied prefix, return the number deleted
+	DeleteByPrefix(context.Context, string) (int64, error)
+}
+
+// mapTarget validates that vals is a non-nil map[string]T & returns it
+func mapTarget(vals any) (reflect.Value, error) {
+	m := reflect.ValueOf(vals)
+	if m.Kind() != reflect.Map || m.IsNil() || m.Type().Key().Kind() != reflect.String {
+		return reflect.Value{}, &ValueError{errors.Errorf("attemp to FetchMany into %T, a non-nil map[string]T is required", vals)}
+	}
+	return m, nil
+}
+
+// setMapValue sets m[key] to the value, if it is assignable to the map element type
+func setMapValue(m reflect.Value, key string, val any) error {
+	elemType := m.Type().Elem()
+	v := reflect.Zero(elemType)
+	if val != nil {
+		if !reflect.TypeOf(val).AssignableTo(elemType) {
+			return &ValueError{errors.Errorf("value of type %v cannot be assigned to type %v", reflect.TypeOf(val), elemType)}
+		}
+		v = reflect.ValueOf(val)
+	}
+	m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), v)
+	return nil
+}
+
+// globEscape escapes the redis glob-style pattern characters in s
+func globEscape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
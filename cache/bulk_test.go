@@ -0,0 +1,125 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// bulkCaches returns the BulkCache implementations under test.
+func bulkCaches(t *testing.T) map[string]BulkCache {
+	r, _ := newTestRedisCache(t)
+	return map[string]BulkCache{
+		"ram":   newTestRamCache(t),
+		"redis": r,
+	}
+}
+
+func TestBulkCache_PutManyFetchMany(t *testing.T) {
+	for name, c := range bulkCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			err := c.PutMany(ctx, []Item{
+				{Key: "a", Val: serdeTestValue{Name: "a"}, Ttl: time.Minute},
+				{Key: "b", Val: serdeTestValue{Name: "b"}, Ttl: NoExpiry},
+			})
+			if err != nil {
+				t.Fatalf("PutMany returned unexpected error: %v", err)
+			}
+
+			got := map[string]serdeTestValue{}
+			if err := c.FetchMany(ctx, []string{"a", "b", "missing"}, got); err != nil {
+				t.Fatalf("FetchMany returned unexpected error: %v", err)
+			}
+			if len(got) != 2 || got["a"].Name != "a" || got["b"].Name != "b" {
+				t.Errorf("FetchMany = %+v; want a & b", got)
+			}
+		})
+	}
+}
+
+func TestBulkCache_PutMany_Ttl(t *testing.T) {
+	for name, c := range bulkCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_ = c.PutMany(ctx, []Item{
+				{Key: "a", Val: "a", Ttl: time.Minute},
+				{Key: "b", Val: "b", Ttl: NoExpiry},
+			})
+
+			var v string
+			if ttl, err := c.FetchWithTtl(ctx, "a", &v); err != nil || *ttl <= 0 {
+				t.Errorf("FetchWithTtl(a) = (%v, %v); want positive ttl", ttl, err)
+			}
+			if ttl, err := c.FetchWithTtl(ctx, "b", &v); err != nil || *ttl != PersistentTtl {
+				t.Errorf("FetchWithTtl(b) = (%v, %v); want %v", ttl, err, PersistentTtl)
+			}
+		})
+	}
+}
+
+func TestBulkCache_FetchMany_InvalidTarget(t *testing.T) {
+	caches := bulkCaches(t)
+	caches["nil"] = &NilCache{}
+	for name, c := range caches {
+		t.Run(name, func(t *testing.T) {
+			var notAMap []string
+			if err := c.FetchMany(context.Background(), []string{"a"}, &notAMap); err == nil {
+				t.Error("FetchMany into a slice returned nil error; want error")
+			}
+		})
+	}
+}
+
+func TestBulkCache_DeleteByPrefix(t *testing.T) {
+	for name, c := range bulkCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_ = c.PutMany(ctx, []Item{
+				{Key: "principal::1", Val: "1"},
+				{Key: "principal::2", Val: "2"},
+				{Key: "principal*::3", Val: "3"},
+				{Key: "other::1", Val: "o"},
+			})
+
+			n, err := c.DeleteByPrefix(ctx, "principal::")
+			if err != nil {
+				t.Fatalf("DeleteByPrefix returned unexpected error: %v", err)
+			}
+			if n != 2 {
+				t.Errorf("DeleteByPrefix = %d; want 2", n)
+			}
+
+			got := map[string]string{}
+			_ = c.FetchMany(ctx, []string{"principal::1", "principal*::3", "other::1"}, got)
+			if _, ok := got["principal::1"]; ok {
+				t.Error("principal::1 exists after DeleteByPrefix")
+			}
+			if len(got) != 2 {
+				t.Errorf("remaining keys = %v; want principal*::3 & other::1", got)
+			}
+		})
+	}
+}
+
+func TestNilCache_Bulk(t *testing.T) {
+	c := &NilCache{}
+	ctx := context.Background()
+
+	if err := c.PutMany(ctx, []Item{{Key: "a", Val: "a"}}); err != nil {
+		t.Errorf("PutMany returned unexpected error: %v", err)
+	}
+	got := map[string]string{}
+	if err := c.FetchMany(ctx, []string{"a"}, got); err != nil || len(got) != 0 {
+		t.Errorf("FetchMany = (%v, %v); want (empty, nil)", got, err)
+	}
+	if n, err := c.DeleteByPrefix(ctx, "a"); err != nil || n != 0 {
+		t.Errorf("DeleteByPrefix = (%d, %v); want (0, nil)", n, err)
+	}
+}
+
+func TestGlobEscape(t *testing.T) {
+	if got := globEscape(`a*b?c[d]\`); got != `a\*b\?c\[d\]\\` {
+		t.Errorf("globEscape = %q; want %q", got, `a\*b\?c\[d\]\\`)
+	}
+}
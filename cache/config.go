@@ -1,9 +1,14 @@
 package cache
 
 import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"os"
+	"time"
 
-	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
@@ -13,24 +18,169 @@ const (
 	Nil            MemoryCacheKind = "nil"
 	InternalMemory MemoryCacheKind = "memory"
 	Redis          MemoryCacheKind = "redis"
+	Tiered         MemoryCacheKind = "tiered" // in-memory L1 in front of redis L2
+	File           MemoryCacheKind = "file"   // entries persisted to a local directory
 )
 
 type Config struct {
-	Kind        MemoryCacheKind `json:"kind"`
-	RedisConfig *RedisConfig    `json:"redis-config"`
+	Kind         MemoryCacheKind `json:"kind"`
+	RedisConfig  *RedisConfig    `json:"redis-config"`
+	RamConfig    *RamConfig      `json:"ram-config"`
+	TieredConfig *TieredConfig   `json:"tiered-config"`
+	FileConfig   *FileConfig     `json:"file-config"`
+
+	// wrap the cache in a Resilient decorator, disabled if nil
+	ResilienceConfig *ResilienceConfig `json:"resilience-config"`
+}
+
+type TieredConfig struct {
+	L1Ttl        time.Duration `json:"l1-ttl"`       // maximum TTL of L1 entries, defaults to 1m
+	Invalidation bool          `json:"invalidation"` // publish puts & deletes so other instances evict their L1 entries
+	Channel      string        `json:"channel"`      // pub/sub channel for invalidations
+}
+
+type FileConfig struct {
+	Dir             string         `json:"dir"`              // directory of the entries, defaults to gotham in the user cache directory
+	Serializer      SerdeKind      `json:"serializer"`       // gob|json|msgpack, defaults to gob
+	JanitorInterval *time.Duration `json:"janitor-interval"` // interval to compact expired entries
+}
+
+type ResilienceConfig struct {
+	Enabled          bool            `json:"enabled"`
+	StaleGrace       time.Duration   `json:"stale-grace"`       // serve values expired no longer than this when the backend fails, 0 disables
+	StaleMaxEntries  int             `json:"stale-max-entries"` // bound of the stale copies, defaults to DefaultL1MaxEntries
+	FailureThreshold int             `json:"failure-threshold"` // defaults to DefaultFailureThreshold
+	OpenTimeout      time.Duration   `json:"open-timeout"`      // defaults to DefaultOpenTimeout
+	Fallback         MemoryCacheKind `json:"fallback"`          // nil|memory, the cache used when the backend fails, defaults to nil
+}
+
+type RamConfig struct {
+	JanitorInterval *time.Duration     `json:"janitor-interval"` // interval to purge expired entries
+	MaxEntries      int                `json:"max-entries"`      // maximum number of entries, 0 is unbounded
+	MaxBytes        int64              `json:"max-bytes"`        // maximum estimated size of all values, 0 is unbounded
+	Eviction        EvictionPolicyKind `json:"eviction"`         // lru|lfu, defaults to lru
+	Policy          EvictionPolicy     `json:"-"`                // custom eviction policy, takes precedence over Eviction
 }
 
 type RedisConfig struct {
-	Host string `json:"host"`
-	Port *int   `json:"port"`
-	Db   int    `json:"db"`
+	Host          string    `json:"host"`
+	Port          *int      `json:"port"`
+	Db            int       `json:"db"`
+	Serializer    SerdeKind `json:"serializer"`     // gob|json|msgpack, defaults to gob
+	Mode          RedisMode `json:"mode"`           // single|sentinel|cluster, defaults to single
+	MasterName    string    `json:"master-name"`    // sentinel master name
+	SentinelAddrs []string  `json:"sentinel-addrs"` // sentinel host:port addresses
+	ClusterAddrs  []string  `json:"cluster-addrs"`  // cluster seed node host:port addresses
+
+	// authentication
+	Username         string `json:"username"`          // acl username, redis 6+
+	Password         string `json:"password"`          // password for the acl user or requirepass
+	SentinelPassword string `json:"sentinel-password"` // password for the sentinel nodes, sentinel only
+
+	// in-transit encryption, disabled if nil
+	TlsConfig *RedisTlsConfig `json:"tls"`
+
+	// connection pool & timeouts, the go-redis defaults are used for zero values
+	PoolSize     int           `json:"pool-size"`
+	MinIdleConns int           `json:"min-idle-conns"`
+	DialTimeout  time.Duration `json:"dial-timeout"`
+	ReadTimeout  time.Duration `json:"read-timeout"`
+	WriteTimeout time.Duration `json:"write-timeout"`
+	MaxRetries   int           `json:"max-retries"` // -1 disables retries
+
+	// value compression & size limit; []byte & string values are stored as-is, without
+	// the header that records the compression, so they are never compressed
+	Compression       Compression `json:"compression"`        // none|gzip|zstd, defaults to none
+	CompressThreshold int         `json:"compress-threshold"` // encoded size in bytes from which values are compressed, defaults to 1KiB
+	MaxValueSize      int         `json:"max-value-size"`     // maximum stored size in bytes, larger values are rejected, 0 is unbounded
+}
+
+// userPrefix returns the "user@" prefix for the configured acl username, if any
+func (r RedisConfig) userPrefix() string {
+	if r.Username == "" {
+		return ""
+	}
+	return r.Username + "@"
+}
+
+// authOptions returns the query string of the credentials & TLS settings that are set,
+// as a hash so the passwords are not kept in the clear
+func (r RedisConfig) authOptions() string {
+	if r.Password == "" && r.SentinelPassword == "" && (r.TlsConfig == nil || !r.TlsConfig.Enabled) {
+		return ""
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "%q:%q:%q", r.Username, r.Password, r.SentinelPassword)
+	if t := r.TlsConfig; t != nil && t.Enabled {
+		fmt.Fprintf(h, ":tls:%q:%q:%q:%q:%v", t.CaFile, t.CertFile, t.KeyFile, t.ServerName, t.InsecureSkipVerify)
+	}
+	return fmt.Sprintf("&auth=%x", h.Sum(nil)[:8])
+}
+
+// valueOptions returns the query string of the value options, other than the serde,
+// that are set
+func (r RedisConfig) valueOptions() string {
+	var opts string
+	if r.Compression != "" && r.Compression != CompressionNone {
+		opts += fmt.Sprintf("&compression=%v&compress-threshold=%v", r.Compression, r.CompressThreshold)
+	}
+	if r.MaxValueSize > 0 {
+		opts += fmt.Sprintf("&max-value-size=%v", r.MaxValueSize)
+	}
+	return opts
 }
 
-var redisCacheImplMap map[string]MemoryCache
+type RedisTlsConfig struct {
+	Enabled            bool   `json:"enabled"`
+	CaFile             string `json:"ca-file"`              // PEM CA bundle, system roots are used if empty
+	CertFile           string `json:"cert-file"`            // PEM client certificate for mutual TLS
+	KeyFile            string `json:"key-file"`             // PEM client key for mutual TLS
+	ServerName         string `json:"server-name"`          // overrides the server name used to verify the certificate
+	InsecureSkipVerify bool   `json:"insecure-skip-verify"` // do not verify the server certificate, never use in production
+}
 
-var ramCacheImpl *RamCache
+// load builds a *tls.Config from the supplied files, nil if TLS is not enabled
+func (t *RedisTlsConfig) load() (*tls.Config, error) {
+	if t == nil || !t.Enabled {
+		return nil, nil
+	}
 
-var nilCacheImpl *NilCache
+	cfg := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+
+	if t.CaFile != "" {
+		pem, err := os.ReadFile(t.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading redis ca bundle %v: %w", t.CaFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in redis ca bundle %v", t.CaFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading redis client certificate %v: %w", t.CertFile, err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// defaultRegistry owns the instances returned by the package level functions
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the Registry used by the package level functions
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
 
 // Initialize a new instance of MemoryCache from app settings
 // see InitializeWithConfig for impelementation details.
@@ -44,91 +194,69 @@ func Initialize() (MemoryCache, error) {
 //
 // cache:
 //
-//	kind: redis  # nil|redis|memory|
+//	kind: redis  # nil|redis|memory|tiered|file
 //	redis_config:
 //	  host: localhost
 //	  port: 6379
 //	  db: 0
+//	  serializer: gob # gob|json|msgpack
+//	  mode: single    # single|sentinel|cluster
+//	  master_name: mymaster                 # sentinel only
+//	  sentinel_addrs: [sentinel-0:26379]    # sentinel only
+//	  cluster_addrs: [node-0:6379, node-1:6379] # cluster only
+//	  username: app
+//	  password: secret
+//	  sentinel_password: secret # sentinel only
+//	  pool_size: 10
+//	  min_idle_conns: 0
+//	  dial_timeout: 5s
+//	  read_timeout: 3s
+//	  write_timeout: 3s
+//	  max_retries: 3
+//	  compression: gzip         # none|gzip|zstd, []byte & string values are not compressed
+//	  compress_threshold: 1024  # bytes
+//	  max_value_size: 1048576   # bytes, 0 is unbounded
+//	  tls:
+//	    enabled: true
+//	    ca_file: /etc/ssl/redis-ca.pem
+//	    cert_file: /etc/ssl/redis-client.pem
+//	    key_file: /etc/ssl/redis-client.key
+//	    server_name: redis.internal
+//	ram_config:
+//	  janitor_interval: 1m
+//	  max_entries: 10000 # 0 is unbounded
+//	  max_bytes: 0       # 0 is unbounded
+//	  eviction: lru      # lru|lfu
+//	tiered_config:       # tiered uses ram_config for L1 & redis_config for L2
+//	  l1_ttl: 1m
+//	  invalidation: true
+//	  channel: gotham:cache:invalidate
+//	file_config:
+//	  dir: ~/.cache/gotham # defaults to gotham in the user cache directory
+//	  serializer: gob      # gob|json|msgpack
+//	  janitor_interval: 1m
+//	resilience_config:   # serve stale values & fall back when the backend fails
+//	  enabled: true
+//	  stale_grace: 1m    # 0 disables stale values
+//	  stale_max_entries: 10000
+//	  failure_threshold: 5
+//	  open_timeout: 30s
+//	  fallback: nil      # nil|memory
 //
-// A memory cache impl is initialized & returns, else a non-nil error
+// A memory cache impl is initialized & returns, else a non-nil error. Instances
+// are shared through the default Registry, call Close to release them
 func InitializeWithConfig(cfg *Config) (MemoryCache, error) {
+	return defaultRegistry.InitializeWithConfig(cfg)
+}
 
-	config := cfg
-	if config == nil {
-		c := loadCacheConfigFromAppSettings()
-		config = &c
-	}
-
-	log.Debugf("cache kind is %v", config.Kind)
-	switch config.Kind {
-
-	//
-	// nil
-	//
-	case Nil:
-		if nilCacheImpl == nil {
-			nilCacheImpl = new(NilCache)
-		}
-		return nilCacheImpl, nil
-
-	//
-	// internal memory (RAM)
-	//
-	case InternalMemory:
-		if ramCacheImpl == nil {
-			ramCacheImpl = new(RamCache)
-			ramCacheImpl.initialize()
-		}
-		return ramCacheImpl, nil
-	//
-	// redis
-	//
-	case Redis:
-		// ultimate defaults
-		host := "localhost"
-		port := 6379
-		db := 0
-
-		// now check config
-		if config.RedisConfig != nil {
-			r := config.RedisConfig
-			host = r.Host
-			if r.Port != nil {
-				port = *r.Port
-			}
-			db = r.Db
-		}
-
-		c := &RedisCache{
-			host: host,
-			port: port,
-			db:   db,
-		}
-
-		// check singleton map, if an instance exists, then return it
-		if c, ok := redisCacheImplMap[c.internalSingletonKey()]; ok {
-			return c, nil
-		}
-
-		log.Debugf("initializing redis cache with host: %v, port: %v, db: %v", host, port, db)
-		if err := c.connect(); err != nil {
-			return nil, err
-		}
-
-		// initialize map
-		if redisCacheImplMap == nil {
-			redisCacheImplMap = make(map[string]MemoryCache)
-		}
-
-		// store singleton in map
-		redisCacheImplMap[c.internalSingletonKey()] = c
-		return c, nil
-
-	// default
-	default:
-		return nil, fmt.Errorf("cache type %v not supported", config.Kind)
+// Close closes all caches initialized by the package level functions, see Registry.Close
+func Close() error {
+	return defaultRegistry.Close()
+}
 
-	}
+// Ping checks the health of all caches initialized by the package level functions
+func Ping(ctx context.Context) error {
+	return defaultRegistry.Ping(ctx)
 }
 
 // loadCacheConfigFromAppSettings loads cache configurationf from app settings
@@ -139,6 +267,35 @@ func InitializeWithConfig(cfg *Config) (MemoryCache, error) {
 // redis host: cache.redis_config.host (string)
 // redis port: cache.redis_config.port (int)
 // redis db: cache.redis_config.db (int)
+// redis serializer: cache.redis_config.serializer (string)
+// redis mode: cache.redis_config.mode (string)
+// redis sentinel master name: cache.redis_config.master_name (string)
+// redis sentinel addresses: cache.redis_config.sentinel_addrs ([]string)
+// redis cluster addresses: cache.redis_config.cluster_addrs ([]string)
+// redis username: cache.redis_config.username (string)
+// redis password: cache.redis_config.password (string)
+// redis sentinel password: cache.redis_config.sentinel_password (string)
+// redis pool size: cache.redis_config.pool_size (int)
+// redis min idle connections: cache.redis_config.min_idle_conns (int)
+// redis dial timeout: cache.redis_config.dial_timeout (duration)
+// redis read timeout: cache.redis_config.read_timeout (duration)
+// redis write timeout: cache.redis_config.write_timeout (duration)
+// redis max retries: cache.redis_config.max_retries (int)
+// redis compression: cache.redis_config.compression (string)
+// redis compression threshold: cache.redis_config.compress_threshold (int)
+// redis max value size: cache.redis_config.max_value_size (int)
+// redis tls: cache.redis_config.tls.[enabled|ca_file|cert_file|key_file|server_name|insecure_skip_verify]
+// ram janitor interval: cache.ram_config.janitor_interval (duration)
+// ram max entries: cache.ram_config.max_entries (int)
+// ram max bytes: cache.ram_config.max_bytes (int)
+// ram eviction policy: cache.ram_config.eviction (string)
+// tiered l1 ttl: cache.tiered_config.l1_ttl (duration)
+// tiered invalidation: cache.tiered_config.invalidation (bool)
+// tiered invalidation channel: cache.tiered_config.channel (string)
+// file directory: cache.file_config.dir (string)
+// file serializer: cache.file_config.serializer (string)
+// file janitor interval: cache.file_config.janitor_interval (duration)
+// resilience: cache.resilience_config.[enabled|stale_grace|stale_max_entries|failure_threshold|open_timeout|fallback]
 func loadCacheConfigFromAppSettings() Config {
 
 	// set default to Nil (no-op)
@@ -148,26 +305,109 @@ func loadCacheConfigFromAppSettings() Config {
 
 	if viper.IsSet("cache.kind") {
 		cfg.Kind = MemoryCacheKind(viper.GetString("cache.kind"))
-		if cfg.Kind == Redis {
-			host := "localhost"
-			if viper.IsSet("cache.redis_config.host") {
-				host = viper.GetString("cache.redis_config.host")
+		if cfg.Kind == Redis || cfg.Kind == Tiered {
+			cfg.RedisConfig = redisConfigFromAppSettings()
+		}
+		if cfg.Kind == InternalMemory || cfg.Kind == Tiered {
+			cfg.RamConfig = ramConfigFromAppSettings()
+		}
+		if cfg.Kind == Tiered {
+			cfg.TieredConfig = &TieredConfig{
+				L1Ttl:        viper.GetDuration("cache.tiered_config.l1_ttl"),
+				Invalidation: viper.GetBool("cache.tiered_config.invalidation"),
+				Channel:      viper.GetString("cache.tiered_config.channel"),
 			}
-			port := 6379
-			if viper.IsSet("cache.redis_config.port") {
-				port = viper.GetInt("cache.redis_config.port")
+		}
+		if cfg.Kind == File {
+			cfg.FileConfig = &FileConfig{
+				Dir:        viper.GetString("cache.file_config.dir"),
+				Serializer: SerdeKind(viper.GetString("cache.file_config.serializer")),
 			}
-			db := 0
-			if viper.IsSet("cache.redis_config.db") {
-				db = viper.GetInt("cache.redis_config.db")
+			if viper.IsSet("cache.file_config.janitor_interval") {
+				interval := viper.GetDuration("cache.file_config.janitor_interval")
+				cfg.FileConfig.JanitorInterval = &interval
 			}
-
-			cfg.RedisConfig = &RedisConfig{
-				Host: host,
-				Port: &port,
-				Db:   db,
+		}
+		if viper.GetBool("cache.resilience_config.enabled") {
+			cfg.ResilienceConfig = &ResilienceConfig{
+				Enabled:          true,
+				StaleGrace:       viper.GetDuration("cache.resilience_config.stale_grace"),
+				StaleMaxEntries:  viper.GetInt("cache.resilience_config.stale_max_entries"),
+				FailureThreshold: viper.GetInt("cache.resilience_config.failure_threshold"),
+				OpenTimeout:      viper.GetDuration("cache.resilience_config.open_timeout"),
+				Fallback:         MemoryCacheKind(viper.GetString("cache.resilience_config.fallback")),
 			}
 		}
 	}
 	return cfg
 }
+
+// redisConfigFromAppSettings loads the redis config from app settings, see
+// loadCacheConfigFromAppSettings for the keys
+func redisConfigFromAppSettings() *RedisConfig {
+	host := "localhost"
+	if viper.IsSet("cache.redis_config.host") {
+		host = viper.GetString("cache.redis_config.host")
+	}
+	port := 6379
+	if viper.IsSet("cache.redis_config.port") {
+		port = viper.GetInt("cache.redis_config.port")
+	}
+	db := 0
+	if viper.IsSet("cache.redis_config.db") {
+		db = viper.GetInt("cache.redis_config.db")
+	}
+
+	cfg := &RedisConfig{
+		Host:          host,
+		Port:          &port,
+		Db:            db,
+		Serializer:    SerdeKind(viper.GetString("cache.redis_config.serializer")),
+		Mode:          RedisMode(viper.GetString("cache.redis_config.mode")),
+		MasterName:    viper.GetString("cache.redis_config.master_name"),
+		SentinelAddrs: viper.GetStringSlice("cache.redis_config.sentinel_addrs"),
+		ClusterAddrs:  viper.GetStringSlice("cache.redis_config.cluster_addrs"),
+
+		Username:         viper.GetString("cache.redis_config.username"),
+		Password:         viper.GetString("cache.redis_config.password"),
+		SentinelPassword: viper.GetString("cache.redis_config.sentinel_password"),
+
+		PoolSize:     viper.GetInt("cache.redis_config.pool_size"),
+		MinIdleConns: viper.GetInt("cache.redis_config.min_idle_conns"),
+		DialTimeout:  viper.GetDuration("cache.redis_config.dial_timeout"),
+		ReadTimeout:  viper.GetDuration("cache.redis_config.read_timeout"),
+		WriteTimeout: viper.GetDuration("cache.redis_config.write_timeout"),
+		MaxRetries:   viper.GetInt("cache.redis_config.max_retries"),
+
+		Compression:       Compression(viper.GetString("cache.redis_config.compression")),
+		CompressThreshold: viper.GetInt("cache.redis_config.compress_threshold"),
+		MaxValueSize:      viper.GetInt("cache.redis_config.max_value_size"),
+	}
+
+	if viper.GetBool("cache.redis_config.tls.enabled") {
+		cfg.TlsConfig = &RedisTlsConfig{
+			Enabled:            true,
+			CaFile:             viper.GetString("cache.redis_config.tls.ca_file"),
+			CertFile:           viper.GetString("cache.redis_config.tls.cert_file"),
+			KeyFile:            viper.GetString("cache.redis_config.tls.key_file"),
+			ServerName:         viper.GetString("cache.redis_config.tls.server_name"),
+			InsecureSkipVerify: viper.GetBool("cache.redis_config.tls.insecure_skip_verify"),
+		}
+	}
+	return cfg
+}
+
+// ramConfigFromAppSettings loads the ram config from app settings, see
+// loadCacheConfigFromAppSettings for the keys
+func ramConfigFromAppSettings() *RamConfig {
+	cfg := &RamConfig{
+		MaxEntries: viper.GetInt("cache.ram_config.max_entries"),
+		MaxBytes:   viper.GetInt64("cache.ram_config.max_bytes"),
+		Eviction:   EvictionPolicyKind(viper.GetString("cache.ram_config.eviction")),
+	}
+	if viper.IsSet("cache.ram_config.janitor_interval") {
+		interval := viper.GetDuration("cache.ram_config.janitor_interval")
+		cfg.JanitorInterval = &interval
+	}
+	return cfg
+}
@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// writeTestCertificate writes a self-signed PEM certificate & key to dir & returns their paths.
+func writeTestCertificate(t *testing.T, dir string) (string, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey returned unexpected error: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redis.test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("x509.CreateCertificate returned unexpected error: %v", err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("x509.MarshalECPrivateKey returned unexpected error: %v", err)
+	}
+
+	certPath := filepath.Join(dir, "cert.pem")
+	keyPath := filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("os.WriteFile returned unexpected error: %v", err)
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatalf("os.WriteFile returned unexpected error: %v", err)
+	}
+	return certPath, keyPath
+}
+
+func TestRedisTlsConfig_Load_Disabled(t *testing.T) {
+	var nilCfg *RedisTlsConfig
+	for _, cfg := range []*RedisTlsConfig{nilCfg, {Enabled: false, CaFile: "ignored"}} {
+		got, err := cfg.load()
+		if err != nil || got != nil {
+			t.Errorf("load = (%v, %v); want (nil, nil)", got, err)
+		}
+	}
+}
+
+func TestRedisTlsConfig_Load_Files(t *testing.T) {
+	certPath, keyPath := writeTestCertificate(t, t.TempDir())
+
+	cfg := &RedisTlsConfig{
+		Enabled:    true,
+		CaFile:     certPath,
+		CertFile:   certPath,
+		KeyFile:    keyPath,
+		ServerName: "redis.test",
+	}
+	got, err := cfg.load()
+	if err != nil {
+		t.Fatalf("load returned unexpected error: %v", err)
+	}
+	if got.RootCAs == nil {
+		t.Error("RootCAs = nil; want CA pool")
+	}
+	if len(got.Certificates) != 1 {
+		t.Errorf("len(Certificates) = %d; want 1", len(got.Certificates))
+	}
+	if got.ServerName != "redis.test" {
+		t.Errorf("ServerName = %q; want %q", got.ServerName, "redis.test")
+	}
+}
+
+func TestRedisTlsConfig_Load_MissingCa(t *testing.T) {
+	cfg := &RedisTlsConfig{Enabled: true, CaFile: filepath.Join(t.TempDir(), "missing.pem")}
+	if _, err := cfg.load(); err == nil {
+		t.Fatal("load with missing CA returned nil error; want error")
+	}
+}
+
+func TestLoadCacheConfigFromAppSettings_Redis(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Set("cache.kind", "redis")
+	viper.Set("cache.redis_config.host", "redis.internal")
+	viper.Set("cache.redis_config.username", "app")
+	viper.Set("cache.redis_config.password", "secret")
+	viper.Set("cache.redis_config.pool_size", 20)
+	viper.Set("cache.redis_config.read_timeout", "2s")
+	viper.Set("cache.redis_config.tls.enabled", true)
+	viper.Set("cache.redis_config.tls.ca_file", "/etc/ssl/ca.pem")
+	viper.Set("cache.redis_config.compression", "gzip")
+	viper.Set("cache.redis_config.max_value_size", 4096)
+
+	cfg := loadCacheConfigFromAppSettings()
+	r := cfg.RedisConfig
+	if cfg.Kind != Redis || r == nil {
+		t.Fatalf("config = %+v; want redis config", cfg)
+	}
+	if r.Host != "redis.internal" || *r.Port != 6379 {
+		t.Errorf("address = %v:%v; want redis.internal:6379", r.Host, *r.Port)
+	}
+	if r.Username != "app" || r.Password != "secret" {
+		t.Errorf("credentials = %v/%v; want app/secret", r.Username, r.Password)
+	}
+	if r.PoolSize != 20 || r.ReadTimeout != 2*time.Second {
+		t.Errorf("pool = %v, read timeout = %v; want 20, 2s", r.PoolSize, r.ReadTimeout)
+	}
+	if r.TlsConfig == nil || r.TlsConfig.CaFile != "/etc/ssl/ca.pem" {
+		t.Errorf("TlsConfig = %+v; want ca file /etc/ssl/ca.pem", r.TlsConfig)
+	}
+	if r.Compression != CompressionGzip || r.MaxValueSize != 4096 {
+		t.Errorf("compression = %v, max value size = %v; want gzip, 4096", r.Compression, r.MaxValueSize)
+	}
+}
+
+func TestLoadCacheConfigFromAppSettings_Default(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	viper.Reset()
+
+	if cfg := loadCacheConfigFromAppSettings(); cfg.Kind != Nil {
+		t.Errorf("Kind = %v; want %v", cfg.Kind, Nil)
+	}
+}
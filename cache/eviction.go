@@ -0,0 +1,171 @@
+package cache
+
+import (
+	"container/heap"
+	"container/list"
+	"fmt"
+)
+
+type EvictionPolicyKind string
+
+const (
+	LRU EvictionPolicyKind = "lru" // evict the least recently used entry
+	LFU EvictionPolicyKind = "lfu" // evict the least frequently used entry
+)
+
+// EvictionPolicy tracks key usage in a bounded RamCache & selects the key to evict
+// when the cache is full. The RamCache serializes all calls to the policy, so
+// implementations do not need their own locking
+type EvictionPolicy interface {
+	// a new key was stored in the cache
+	Added(key string)
+
+	// an existing key was read or overwritten
+	Accessed(key string)
+
+	// a key was removed from the cache (deleted, expired or evicted)
+	Removed(key string)
+
+	// the key that should be evicted next, false if no keys are tracked
+	Victim() (string, bool)
+}
+
+// NewEvictionPolicy returns a new EvictionPolicy for the supplied kind
+func NewEvictionPolicy(kind EvictionPolicyKind) (EvictionPolicy, error) {
+	switch kind {
+	case LRU, "":
+		return newLruPolicy(), nil
+	case LFU:
+		return newLfuPolicy(), nil
+	default:
+		return nil, fmt.Errorf("eviction policy %v not supported", kind)
+	}
+}
+
+// lruPolicy is an EvictionPolicy that evicts the least recently used key
+type lruPolicy struct {
+	order *list.List // front is most recently used
+	elems map[string]*list.Element
+}
+
+func newLruPolicy() *lruPolicy {
+	return &lruPolicy{
+		order: list.New(),
+		elems: make(map[string]*list.Element),
+	}
+}
+
+func (p *lruPolicy) Added(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+		return
+	}
+	p.elems[key] = p.order.PushFront(key)
+}
+
+func (p *lruPolicy) Accessed(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.MoveToFront(e)
+	}
+}
+
+func (p *lruPolicy) Removed(key string) {
+	if e, ok := p.elems[key]; ok {
+		p.order.Remove(e)
+		delete(p.elems, key)
+	}
+}
+
+func (p *lruPolicy) Victim() (string, bool) {
+	e := p.order.Back()
+	if e == nil {
+		return "", false
+	}
+	return e.Value.(string), true
+}
+
+// lfuPolicy is an EvictionPolicy that evicts the least frequently used key, ties
+// are broken by evicting the least recently used of those keys
+type lfuPolicy struct {
+	items lfuHeap
+	index map[string]*lfuItem
+	clock uint64
+}
+
+type lfuItem struct {
+	key   string
+	freq  uint64
+	last  uint64 // logical time of last access
+	index int    // position in the heap
+}
+
+func newLfuPolicy() *lfuPolicy {
+	return &lfuPolicy{index: make(map[string]*lfuItem)}
+}
+
+func (p *lfuPolicy) Added(key string) {
+	if _, ok := p.index[key]; ok {
+		p.Accessed(key)
+		return
+	}
+	p.clock++
+	it := &lfuItem{key: key, freq: 1, last: p.clock}
+	p.index[key] = it
+	heap.Push(&p.items, it)
+}
+
+func (p *lfuPolicy) Accessed(key string) {
+	if it, ok := p.index[key]; ok {
+		p.clock++
+		it.freq++
+		it.last = p.clock
+		heap.Fix(&p.items, it.index)
+	}
+}
+
+func (p *lfuPolicy) Removed(key string) {
+	if it, ok := p.index[key]; ok {
+		heap.Remove(&p.items, it.index)
+		delete(p.index, key)
+	}
+}
+
+func (p *lfuPolicy) Victim() (string, bool) {
+	if len(p.items) == 0 {
+		return "", false
+	}
+	return p.items[0].key, true
+}
+
+// lfuHeap is a min-heap of lfuItem ordered by frequency, then by last access
+type lfuHeap []*lfuItem
+
+func (h lfuHeap) Len() int { return len(h) }
+
+func (h lfuHeap) Less(i, j int) bool {
+	if h[i].freq == h[j].freq {
+		return h[i].last < h[j].last
+	}
+	return h[i].freq < h[j].freq
+}
+
+func (h lfuHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *lfuHeap) Push(x any) {
+	it := x.(*lfuItem)
+	it.index = len(*h)
+	*h = append(*h, it)
+}
+
+func (h *lfuHeap) Pop() any {
+	old := *h
+	n := len(old)
+	it := old[n-1]
+	old[n-1] = nil
+	*h = old[:n-1]
+	return it
+}
@@ -0,0 +1,50 @@
+package cache
+
+import "testing"
+
+func TestLruPolicy_Victim(t *testing.T) {
+	p := newLruPolicy()
+	p.Added("a")
+	p.Added("b")
+	p.Added("c")
+	p.Accessed("a")
+
+	if v, ok := p.Victim(); !ok || v != "b" {
+		t.Errorf("Victim = (%q, %v); want (%q, true)", v, ok, "b")
+	}
+
+	p.Removed("b")
+	if v, _ := p.Victim(); v != "c" {
+		t.Errorf("Victim after Removed = %q; want %q", v, "c")
+	}
+}
+
+func TestLfuPolicy_Victim(t *testing.T) {
+	p := newLfuPolicy()
+	p.Added("a")
+	p.Added("b")
+	p.Added("c")
+	p.Accessed("a")
+	p.Accessed("c")
+
+	if v, ok := p.Victim(); !ok || v != "b" {
+		t.Errorf("Victim = (%q, %v); want (%q, true)", v, ok, "b")
+	}
+
+	p.Removed("b")
+	if v, _ := p.Victim(); v != "a" {
+		t.Errorf("Victim after Removed = %q; want %q", v, "a")
+	}
+}
+
+func TestEvictionPolicy_Empty(t *testing.T) {
+	for _, kind := range []EvictionPolicyKind{LRU, LFU} {
+		p, err := NewEvictionPolicy(kind)
+		if err != nil {
+			t.Fatalf("NewEvictionPolicy(%v) returned unexpected error: %v", kind, err)
+		}
+		if _, ok := p.Victim(); ok {
+			t.Errorf("%v Victim on empty policy = (_, true); want (_, false)", kind)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// CacheStructTagKey is the struct tag naming the field of a structured value, e.g.
+// `cache:"roles"`; `cache:"-"` skips the field. Exported fields without the tag are
+// named after the struct field
+const CacheStructTagKey = "cache"
+
+// FieldCache is an extension of MemoryCache for implementations that store structured
+// values field by field (a redis hash), so large cached objects can be partially
+// updated & read. Each field is encoded on its own, like a value stored with Put
+type FieldCache interface {
+	MemoryCache
+
+	// store the fields of val (a struct or pointer to struct) for the key, only the
+	// named fields if any are supplied, leaving the other stored fields unchanged. A
+	// positive TTL is set on the key, NoExpiry keeps the TTL of an existing key
+	PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error
+
+	// fetch the fields for the key into val (a pointer to struct), only the named
+	// fields if any are supplied; fields that are not stored are left unchanged
+	FetchFields(ctx context.Context, key string, val any, fields ...string) error
+
+	// delete the named fields for the key, return the number of fields deleted
+	DeleteFields(ctx context.Context, key string, fields ...string) (int64, error)
+}
+
+// structField is a field of a structured value
+type structField struct {
+	name  string
+	index int
+}
+
+// structFieldsCache caches the fields of each struct type
+var structFieldsCache sync.Map // reflect.Type -> []structField
+
+// structFields returns the fields of the struct type
+func structFields(t reflect.Type) []structField {
+	if fields, ok := structFieldsCache.Load(t); ok {
+		return fields.([]structField)
+	}
+
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		name := f.Tag.Get(CacheStructTagKey)
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name: name, index: i})
+	}
+
+	structFieldsCache.Store(t, fields)
+	return fields
+}
+
+// selectFields returns the named fields of the struct type, all fields if none are
+// named; a type without fields is rejected, as there is nothing to store or fetch
+func selectFields(t reflect.Type, names []string) ([]structField, error) {
+	all := structFields(t)
+	if len(all) == 0 {
+		return nil, fmt.Errorf("type %v has no cache fields", t)
+	}
+	if len(names) == 0 {
+		return all, nil
+	}
+
+	selected := make([]structField, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, f := range all {
+			if f.name == name {
+				selected = append(selected, f)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("type %v has no cache field %v", t, name)
+		}
+	}
+	return selected, nil
+}
+
+// fieldValues returns the values of the named fields of val (a struct or pointer to
+// struct), keyed by field name
+func fieldValues(val any, names []string) (map[string]any, error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() == reflect.Pointer && !v.IsNil() {
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil, &ValueError{fmt.Errorf("fields must be stored from a struct or pointer to struct, got %T", val)}
+	}
+
+	fields, err := selectFields(v.Type(), names)
+	if err != nil {
+		return nil, &ValueError{err}
+	}
+
+	values := make(map[string]any, len(fields))
+	for _, f := range fields {
+		values[f.name] = v.Field(f.index).Interface()
+	}
+	return values, nil
+}
+
+// fieldTargets returns pointers to the named fields of val (a pointer to struct),
+// keyed by field name
+func fieldTargets(val any, names []string) (map[string]any, error) {
+	v := reflect.ValueOf(val)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, &ValueError{fmt.Errorf("fields must be fetched into a pointer to struct, got %T", val)}
+	}
+	v = v.Elem()
+
+	fields, err := selectFields(v.Type(), names)
+	if err != nil {
+		return nil, &ValueError{err}
+	}
+
+	targets := make(map[string]any, len(fields))
+	for _, f := range fields {
+		targets[f.name] = v.Field(f.index).Addr().Interface()
+	}
+	return targets, nil
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fieldsTestValue is a structured value stored by field.
+type fieldsTestValue struct {
+	Id      string   `cache:"id"`
+	Roles   []string `cache:"roles"`
+	Count   int64    `cache:"count"`
+	Skipped string   `cache:"-"`
+	Name    string
+}
+
+// fieldCaches returns the FieldCache implementations under test.
+func fieldCaches(t *testing.T) map[string]FieldCache {
+	r, _ := newTestRedisCache(t)
+	r2, _ := newTestRedisCache(t)
+	return map[string]FieldCache{
+		"ram":    newTestRamCache(t),
+		"redis":  r,
+		"tiered": newTestTieredCache(t, r2, TieredConfig{}),
+	}
+}
+
+func TestFieldCache_PutFetchFields(t *testing.T) {
+	for name, c := range fieldCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			in := fieldsTestValue{Id: "1", Roles: []string{"admin"}, Count: 3, Skipped: "x", Name: "n"}
+			if err := c.PutFields(ctx, "k", &in, time.Minute); err != nil {
+				t.Fatalf("PutFields returned unexpected error: %v", err)
+			}
+
+			var got fieldsTestValue
+			if err := c.FetchFields(ctx, "k", &got); err != nil {
+				t.Fatalf("FetchFields returned unexpected error: %v", err)
+			}
+			want := fieldsTestValue{Id: "1", Roles: []string{"admin"}, Count: 3, Name: "n"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("FetchFields = %+v; want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestFieldCache_PartialUpdate(t *testing.T) {
+	for name, c := range fieldCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_ = c.PutFields(ctx, "k", fieldsTestValue{Id: "1", Roles: []string{"admin"}, Name: "n"}, time.Minute)
+			if err := c.PutFields(ctx, "k", fieldsTestValue{Id: "2", Roles: []string{"user"}}, NoExpiry, "roles"); err != nil {
+				t.Fatalf("PutFields returned unexpected error: %v", err)
+			}
+
+			got := fieldsTestValue{Name: "unchanged"}
+			if err := c.FetchFields(ctx, "k", &got, "id", "roles"); err != nil {
+				t.Fatalf("FetchFields returned unexpected error: %v", err)
+			}
+			want := fieldsTestValue{Id: "1", Roles: []string{"user"}, Name: "unchanged"}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("FetchFields = %+v; want %+v", got, want)
+			}
+
+			if ttl := fieldsTtl(t, c, "k"); ttl <= 0 || ttl > time.Minute {
+				t.Errorf("ttl = %v; want the original ttl", ttl)
+			}
+		})
+	}
+}
+
+func TestFieldCache_DeleteFields(t *testing.T) {
+	for name, c := range fieldCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			_ = c.PutFields(ctx, "k", fieldsTestValue{Id: "1", Count: 3}, NoExpiry, "id", "count")
+
+			if n, err := c.DeleteFields(ctx, "k", "count", "missing"); err != nil || n != 1 {
+				t.Errorf("DeleteFields = (%v, %v); want 1", n, err)
+			}
+			var got fieldsTestValue
+			if err := c.FetchFields(ctx, "k", &got); err != nil || got.Id != "1" || got.Count != 0 {
+				t.Errorf("FetchFields = (%+v, %v); want only id", got, err)
+			}
+
+			_, _ = c.DeleteFields(ctx, "k", "id")
+			if err := c.FetchFields(ctx, "k", &got); !IsCacheMiss(err) {
+				t.Errorf("FetchFields error = %v; want cache miss", err)
+			}
+		})
+	}
+}
+
+func TestFieldCache_Errors(t *testing.T) {
+	for name, c := range fieldCaches(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			if err := c.PutFields(ctx, "k", fieldsTestValue{}, NoExpiry, "unknown"); err == nil {
+				t.Errorf("PutFields(unknown) returned nil error; want error")
+			}
+			if err := c.PutFields(ctx, "k", "text", NoExpiry); err == nil {
+				t.Errorf("PutFields(string) returned nil error; want error")
+			}
+			if err := c.FetchFields(ctx, "k", fieldsTestValue{}); err == nil {
+				t.Errorf("FetchFields(non-pointer) returned nil error; want error")
+			}
+			var empty struct{ hidden string }
+			if err := c.PutFields(ctx, "k", empty, NoExpiry); !IsValueError(err) {
+				t.Errorf("PutFields(no fields) error = %v; want value error", err)
+			}
+			if err := c.FetchFields(ctx, "k", &empty); !IsValueError(err) {
+				t.Errorf("FetchFields(no fields) error = %v; want value error", err)
+			}
+		})
+	}
+}
+
+func TestRamCache_PutFields_MaxBytes(t *testing.T) {
+	r, err := NewRamCache(RamConfig{MaxBytes: 64})
+	if err != nil {
+		t.Fatalf("NewRamCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(r.Stop)
+
+	large := fieldsTestValue{Id: "k", Roles: []string{strings.Repeat("r", 100)}}
+	if err := r.PutFields(context.Background(), "k", large, NoExpiry); err == nil {
+		t.Error("PutFields(over budget) returned nil error; want error")
+	}
+	if n := sizeOf(ramFields{"id": "k", "name": "n"}); n != 8 {
+		t.Errorf("sizeOf(fields) = %v; want 8", n)
+	}
+}
+
+// fieldsTtl returns the remaining TTL of the key stored by field.
+func fieldsTtl(t *testing.T, c FieldCache, key string) time.Duration {
+	t.Helper()
+	switch c := c.(type) {
+	case *RamCache:
+		e, _ := c.lookup(key)
+		return time.Until(e.expiresAt)
+	case *RedisCache:
+		return c.client.PTTL(context.Background(), key).Val()
+	case *TieredCache:
+		return c.l2.client.PTTL(context.Background(), key).Val()
+	}
+	t.Fatalf("unexpected cache %T", c)
+	return 0
+}
@@ -0,0 +1,365 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// entry file layout
+//
+//	byte 0-3:   magic "GTFC"
+//	byte 4-11:  expiry, unix nanoseconds big endian, 0 if the entry never expires
+//	byte 12-15: key length n, big endian
+//	byte 16-:   key (n bytes), followed by the stored value
+var fileEntryMagic = []byte("GTFC")
+
+const (
+	fileEntryHeaderLen = 16
+	fileEntryExt       = ".entry"
+	fileTempPrefix     = ".tmp-"
+
+	// fileTempMaxAge is the age after which temp files, left behind by a crashed
+	// write, are removed by the janitor
+	fileTempMaxAge = time.Hour
+)
+
+// FileCache is a MemoryCache implementation that persists entries to a local directory,
+// one file per key, so entries survive restarts; for CLI tools & local development.
+// Entries are written atomically, so a FileCache is safe for concurrent use, also by
+// several processes sharing the directory (the last write wins). Expired entries are
+// misses when read & are removed by compaction, run periodically by a janitor goroutine
+type FileCache struct {
+	dir      string
+	serde    Serde
+	interval time.Duration
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFileCache returns a new FileCache storing entries in the configured directory,
+// created if needed, with a running janitor goroutine; call Stop to terminate the
+// janitor when the cache is no longer used
+func NewFileCache(cfg FileConfig) (*FileCache, error) {
+	dir, err := cfg.resolveDir()
+	if err != nil {
+		return nil, err
+	}
+	// entries may hold credentials, e.g. principal tokens, so they are private
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating file cache directory %v: %w", dir, err)
+	}
+
+	serde, err := NewSerde(cfg.Serializer)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &FileCache{dir: dir, serde: serde, stop: make(chan struct{})}
+	if cfg.JanitorInterval != nil {
+		f.interval = *cfg.JanitorInterval
+	}
+	if f.interval <= 0 {
+		f.interval = DefaultJanitorInterval
+	}
+	go f.janitor()
+	return f, nil
+}
+
+// resolveDir returns the absolute directory of the entries, the default directory if
+// none is configured
+func (cfg FileConfig) resolveDir() (string, error) {
+	dir := cfg.Dir
+	if dir == "" {
+		base, err := os.UserCacheDir()
+		if err != nil {
+			return "", fmt.Errorf("error finding the user cache directory: %w", err)
+		}
+		dir = filepath.Join(base, "gotham")
+	}
+	return filepath.Abs(dir)
+}
+
+// Dir returns the directory of the entries
+func (f *FileCache) Dir() string {
+	return f.dir
+}
+
+// janitor periodically compacts expired entries until the cache is stopped
+func (f *FileCache) janitor() {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := f.Compact(); err != nil {
+				log.Warnf("error compacting file cache %v: %v", f.dir, err)
+			}
+		case <-f.stop:
+			return
+		}
+	}
+}
+
+// Compact removes the expired entries, & temp files left behind by crashed writes,
+// returning the number of entries removed
+func (f *FileCache) Compact() (int, error) {
+	files, err := os.ReadDir(f.dir)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	n := 0
+	for _, file := range files {
+		name := file.Name()
+		path := filepath.Join(f.dir, name)
+		switch {
+		case strings.HasPrefix(name, fileTempPrefix):
+			if info, err := file.Info(); err == nil && now.Sub(info.ModTime()) > fileTempMaxAge {
+				_ = os.Remove(path)
+			}
+		case strings.HasSuffix(name, fileEntryExt):
+			removed, err := f.compactEntry(path, now)
+			if err != nil {
+				log.Debugf("error compacting file cache entry %v: %v", path, err)
+				continue
+			}
+			if removed {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// compactEntry removes the entry file if it is expired at now. The file is kept open,
+// so its identity can't be reused, until it is removed
+func (f *FileCache) compactEntry(path string, now time.Time) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return false, err
+	}
+	expiresAt, err := readFileEntryExpiry(file)
+	if err != nil {
+		return false, err
+	}
+	if expiresAt.IsZero() || now.Before(expiresAt) {
+		return false, nil
+	}
+	return f.removeEntry(path, info)
+}
+
+// removeEntry removes the entry file, if it is still the file described by info. A
+// write may replace the entry after it was read, so the file is renamed to a tombstone
+// first & put back if it isn't the one that was read, unless it was replaced again
+func (f *FileCache) removeEntry(path string, info os.FileInfo) (bool, error) {
+	tomb, err := os.CreateTemp(f.dir, fileTempPrefix)
+	if err != nil {
+		return false, err
+	}
+	tomb.Close()
+	defer os.Remove(tomb.Name())
+
+	if err := os.Rename(path, tomb.Name()); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	moved, err := os.Stat(tomb.Name())
+	if err != nil || os.SameFile(info, moved) {
+		return err == nil, nil
+	}
+
+	if err := os.Link(tomb.Name(), path); err != nil && !errors.Is(err, fs.ErrExist) {
+		return false, fmt.Errorf("error restoring file cache entry %v: %w", path, err)
+	}
+	return false, nil
+}
+
+// Stop terminates the janitor goroutine; it is safe to call Stop more than once
+func (f *FileCache) Stop() {
+	f.stopOnce.Do(func() {
+		close(f.stop)
+	})
+}
+
+// Close stops the janitor, see Stop
+func (f *FileCache) Close() error {
+	f.Stop()
+	return nil
+}
+
+// Ping checks that the directory of the entries exists
+func (f *FileCache) Ping(ctx context.Context) error {
+	info, err := os.Stat(f.dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("file cache path %v is not a directory", f.dir)
+	}
+	return nil
+}
+
+// path returns the path of the entry file for key
+func (f *FileCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(f.dir, hex.EncodeToString(sum[:])+fileEntryExt)
+}
+
+// read returns the unexpired stored value & expiry for key; expired entries are misses
+// & left for compaction, as a write may replace them at any time
+func (f *FileCache) read(key string) ([]byte, time.Time, error) {
+	path := f.path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, time.Time{}, &CacheMissError{key, err}
+	}
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	entryKey, expiresAt, value, err := parseFileEntry(data)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("error reading file cache entry for key %v: %w", key, err)
+	}
+	if entryKey != key {
+		return nil, time.Time{}, &CacheMissError{key, fmt.Errorf("file cache entry %v holds key %v", path, entryKey)}
+	}
+	if !expiresAt.IsZero() && !time.Now().Before(expiresAt) {
+		return nil, time.Time{}, &CacheMissError{key, errors.New("file cache entry expired")}
+	}
+	return value, expiresAt, nil
+}
+
+// parseFileEntry returns the key, expiry & stored value of an entry file
+func parseFileEntry(data []byte) (string, time.Time, []byte, error) {
+	if len(data) < fileEntryHeaderLen || !bytes.Equal(data[:4], fileEntryMagic) {
+		return "", time.Time{}, nil, errors.New("not a file cache entry")
+	}
+	expiresAt := fileEntryExpiry(data)
+	n := int(binary.BigEndian.Uint32(data[12:16]))
+	if len(data) < fileEntryHeaderLen+n {
+		return "", time.Time{}, nil, errors.New("truncated file cache entry")
+	}
+	return string(data[fileEntryHeaderLen : fileEntryHeaderLen+n]), expiresAt, data[fileEntryHeaderLen+n:], nil
+}
+
+// fileEntryExpiry returns the expiry in the entry header, zero if it never expires
+func fileEntryExpiry(header []byte) time.Time {
+	nanos := int64(binary.BigEndian.Uint64(header[4:12]))
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// readFileEntryExpiry reads only the header of the entry file for its expiry
+func readFileEntryExpiry(file io.Reader) (time.Time, error) {
+	header := make([]byte, fileEntryHeaderLen)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return time.Time{}, err
+	}
+	if !bytes.Equal(header[:4], fileEntryMagic) {
+		return time.Time{}, errors.New("not a file cache entry")
+	}
+	return fileEntryExpiry(header), nil
+}
+
+// write atomically replaces the entry file for key, by renaming a temp file
+func (f *FileCache) write(key string, value []byte, expiry time.Duration) error {
+	entry := make([]byte, fileEntryHeaderLen, fileEntryHeaderLen+len(key)+len(value))
+	copy(entry, fileEntryMagic)
+	if expiry > 0 {
+		binary.BigEndian.PutUint64(entry[4:12], uint64(time.Now().Add(expiry).UnixNano()))
+	}
+	binary.BigEndian.PutUint32(entry[12:16], uint32(len(key)))
+	entry = append(entry, key...)
+	entry = append(entry, value...)
+
+	tmp, err := os.CreateTemp(f.dir, fileTempPrefix)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(entry); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path(key))
+}
+
+// method implementations
+
+func (f *FileCache) Put(ctx context.Context, key string, val any) error {
+	return f.PutWithTtl(ctx, key, val, NoExpiry)
+}
+
+func (f *FileCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	value, err := encodeRaw(f.serde, val)
+	if err != nil {
+		return err
+	}
+	return f.write(key, value, expiry)
+}
+
+func (f *FileCache) Fetch(ctx context.Context, key string, val any) error {
+	_, err := f.FetchWithTtl(ctx, key, val)
+	return err
+}
+
+func (f *FileCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	value, expiresAt, err := f.read(key)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeRaw(value, val); err != nil {
+		return nil, err
+	}
+
+	ttl := PersistentTtl
+	if !expiresAt.IsZero() {
+		ttl = time.Until(expiresAt)
+	}
+	return &ttl, nil
+}
+
+func (f *FileCache) Delete(ctx context.Context, key string) (int64, error) {
+	_, _, err := f.read(key)
+	if IsCacheMiss(err) {
+		return 0, nil
+	}
+	if err := os.Remove(f.path(key)); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return 1, nil
+}
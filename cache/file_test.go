@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+// newTestFileCache returns a FileCache in the supplied directory that is stopped at
+// test cleanup.
+func newTestFileCache(t *testing.T, dir string) *FileCache {
+	t.Helper()
+	f, err := NewFileCache(FileConfig{Dir: dir})
+	if err != nil {
+		t.Fatalf("NewFileCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(f.Stop)
+	return f
+}
+
+func TestFileCache_PutFetch(t *testing.T) {
+	dir := t.TempDir()
+	f := newTestFileCache(t, dir)
+	ctx := context.Background()
+
+	want := serdeTestValue{Name: "n", Count: 3, Tags: []string{"a"}}
+	if err := f.PutWithTtl(ctx, "k", want, time.Minute); err != nil {
+		t.Fatalf("PutWithTtl returned unexpected error: %v", err)
+	}
+	_ = f.Put(ctx, "s", "plain")
+
+	restarted := newTestFileCache(t, dir)
+	var got serdeTestValue
+	ttl, err := restarted.FetchWithTtl(ctx, "k", &got)
+	if err != nil || got.Name != want.Name || got.Count != want.Count {
+		t.Fatalf("FetchWithTtl = (%+v, %v); want %+v", got, err, want)
+	}
+	if *ttl <= 0 || *ttl > time.Minute {
+		t.Errorf("FetchWithTtl ttl = %v; want at most 1m", *ttl)
+	}
+
+	var s string
+	if ttl, err := restarted.FetchWithTtl(ctx, "s", &s); err != nil || s != "plain" || *ttl != PersistentTtl {
+		t.Errorf("FetchWithTtl(s) = (%v, %v, %v); want plain, %v", s, ttl, err, PersistentTtl)
+	}
+}
+
+func TestFileCache_Expiry(t *testing.T) {
+	f := newTestFileCache(t, t.TempDir())
+	ctx := context.Background()
+
+	_ = f.PutWithTtl(ctx, "k", "v", 10*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+
+	var got string
+	if err := f.Fetch(ctx, "k", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch error = %v; want cache miss", err)
+	}
+	if _, err := os.Stat(f.path("k")); err != nil {
+		t.Errorf("Stat returned unexpected error: %v; want the entry file left for compaction", err)
+	}
+}
+
+func TestFileCache_Delete(t *testing.T) {
+	f := newTestFileCache(t, t.TempDir())
+	ctx := context.Background()
+
+	_ = f.Put(ctx, "k", "v")
+	if n, err := f.Delete(ctx, "k"); err != nil || n != 1 {
+		t.Errorf("Delete = (%v, %v); want 1", n, err)
+	}
+	if n, err := f.Delete(ctx, "k"); err != nil || n != 0 {
+		t.Errorf("Delete = (%v, %v); want 0", n, err)
+	}
+}
+
+func TestFileCache_Compact(t *testing.T) {
+	f := newTestFileCache(t, t.TempDir())
+	ctx := context.Background()
+
+	_ = f.PutWithTtl(ctx, "expired", "v", 10*time.Millisecond)
+	_ = f.PutWithTtl(ctx, "live", "v", time.Minute)
+	_ = f.Put(ctx, "persistent", "v")
+	time.Sleep(20 * time.Millisecond)
+
+	if n, err := f.Compact(); err != nil || n != 1 {
+		t.Errorf("Compact = (%v, %v); want 1", n, err)
+	}
+	files, _ := os.ReadDir(f.Dir())
+	if len(files) != 2 {
+		t.Errorf("%v files remain; want 2", len(files))
+	}
+}
+
+func TestFileCache_CompactReplaced(t *testing.T) {
+	f := newTestFileCache(t, t.TempDir())
+	ctx := context.Background()
+
+	_ = f.PutWithTtl(ctx, "k", "old", 10*time.Millisecond)
+	read, err := os.Open(f.path("k"))
+	if err != nil {
+		t.Fatalf("Open returned unexpected error: %v", err)
+	}
+	defer read.Close()
+	info, _ := read.Stat()
+
+	// the expired entry is replaced after compaction read it
+	_ = f.PutWithTtl(ctx, "k", "new", time.Minute)
+	if removed, err := f.removeEntry(f.path("k"), info); err != nil || removed {
+		t.Errorf("removeEntry = (%v, %v); want the replaced entry kept", removed, err)
+	}
+	var got string
+	if err := f.Fetch(ctx, "k", &got); err != nil || got != "new" {
+		t.Errorf("Fetch = (%v, %v); want new", got, err)
+	}
+	files, _ := os.ReadDir(f.Dir())
+	if len(files) != 1 {
+		t.Errorf("%v files remain; want 1", len(files))
+	}
+}
+
+func TestRegistry_InitializeWithConfig_File(t *testing.T) {
+	g := newTestRegistry(t)
+	dir := t.TempDir()
+
+	c1, err := g.InitializeWithConfig(&Config{Kind: File, FileConfig: &FileConfig{Dir: dir}})
+	if err != nil {
+		t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+	}
+	c2, _ := g.InitializeWithConfig(&Config{Kind: File, FileConfig: &FileConfig{Dir: dir}})
+	if c1 != c2 {
+		t.Errorf("InitializeWithConfig returned a new instance; want the shared instance")
+	}
+	if err := g.Ping(context.Background()); err != nil {
+		t.Errorf("Ping returned unexpected error: %v", err)
+	}
+}
+
+func TestRegistry_InitializeWithConfig_FileDefaultDir(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	g := newTestRegistry(t)
+
+	c1, err := g.InitializeWithConfig(&Config{Kind: File})
+	if err != nil {
+		t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+	}
+	c2, _ := g.InitializeWithConfig(&Config{Kind: File, FileConfig: &FileConfig{Dir: c1.(*FileCache).Dir()}})
+	if c1 != c2 {
+		t.Errorf("InitializeWithConfig returned a new instance for the default directory; want the shared instance")
+	}
+}
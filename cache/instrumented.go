@@ -0,0 +1,376 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type Op string
+
+const (
+	OpPut    Op = "put"
+	OpFetch  Op = "fetch"
+	OpDelete Op = "delete"
+
+	// extension interfaces
+	OpFetchMany      Op = "fetch_many"
+	OpPutMany        Op = "put_many"
+	OpDeleteByPrefix Op = "delete_by_prefix"
+	OpIncr           Op = "incr"
+	OpDecr           Op = "decr"
+	OpSetIfAbsent    Op = "set_if_absent"
+	OpCompareAndSwap Op = "compare_and_swap"
+	OpPutFields      Op = "put_fields"
+	OpFetchFields    Op = "fetch_fields"
+	OpDeleteFields   Op = "delete_fields"
+)
+
+type Outcome string
+
+const (
+	OutcomeHit   Outcome = "hit"   // fetch found the key(s), or delete removed it
+	OutcomeMiss  Outcome = "miss"  // fetch or delete did not find the key(s), or a conditional put did not apply
+	OutcomeOk    Outcome = "ok"    // put succeeded
+	OutcomeError Outcome = "error" // the operation failed
+)
+
+// DefaultNamespace is the namespace recorded for keys without a namespace part
+const DefaultNamespace = "default"
+
+// Event is a single instrumented cache operation
+type Event struct {
+	Op        Op
+	Namespace string // key part before the first KeySeparator
+	Outcome   Outcome
+	Duration  time.Duration
+	Bytes     int64 // estimated payload size, 0 if not measured or no payload
+}
+
+// MetricsSink records instrumented cache operations; implementations must be safe
+// for concurrent use
+type MetricsSink interface {
+	Record(Event)
+}
+
+// TraceHook is called when a cache operation starts; it may return a derived context
+// (e.g. carrying a span) & returns a function called with the result of the operation
+type TraceHook func(ctx context.Context, op Op, key string) (context.Context, func(error))
+
+// InstrumentOptions configures an Instrumented cache
+type InstrumentOptions struct {
+	Sink        MetricsSink // metrics are not recorded if nil
+	Trace       TraceHook   // operations are not traced if nil
+	MeasureSize bool        // estimate payload sizes, values other than []byte & string are gob encoded to do so
+}
+
+// Instrumented is a MemoryCache decorator that records metrics for every operation
+// on the wrapped cache & calls the trace hook, if any. The BulkCache, AtomicCache &
+// FieldCache operations are forwarded if the wrapped cache implements them; bulk
+// operations record one event, namespaced by the first key, without payload size
+type Instrumented struct {
+	cache MemoryCache
+	opts  InstrumentOptions
+}
+
+// NewInstrumented returns an Instrumented cache wrapping the supplied cache
+func NewInstrumented(c MemoryCache, opts InstrumentOptions) *Instrumented {
+	return &Instrumented{cache: c, opts: opts}
+}
+
+// Namespace returns the namespace of the supplied key, DefaultNamespace if it has none
+func Namespace(key string) string {
+	if ns, _, ok := strings.Cut(key, KeySeparator); ok && ns != "" {
+		return ns
+	}
+	return DefaultNamespace
+}
+
+// start calls the trace hook & returns the function that ends the operation
+func (i *Instrumented) start(ctx context.Context, op Op, key string) (context.Context, func(Outcome, any, error)) {
+	begin := time.Now()
+
+	var finish func(error)
+	if i.opts.Trace != nil {
+		ctx, finish = i.opts.Trace(ctx, op, key)
+	}
+
+	return ctx, func(outcome Outcome, payload any, err error) {
+		if finish != nil {
+			finish(err)
+		}
+		if i.opts.Sink == nil {
+			return
+		}
+
+		e := Event{
+			Op:        op,
+			Namespace: Namespace(key),
+			Outcome:   outcome,
+			Duration:  time.Since(begin),
+		}
+		if i.opts.MeasureSize && payload != nil {
+			e.Bytes = sizeOf(payload)
+		}
+		i.opts.Sink.Record(e)
+	}
+}
+
+// Unwrap returns the wrapped cache
+func (i *Instrumented) Unwrap() MemoryCache {
+	return i.cache
+}
+
+// Close closes the wrapped cache, if it implements Lifecycle
+func (i *Instrumented) Close() error {
+	if l, ok := i.cache.(Lifecycle); ok {
+		return l.Close()
+	}
+	return nil
+}
+
+// Ping checks the health of the wrapped cache, if it implements Lifecycle
+func (i *Instrumented) Ping(ctx context.Context) error {
+	if l, ok := i.cache.(Lifecycle); ok {
+		return l.Ping(ctx)
+	}
+	return nil
+}
+
+// method implementations
+
+func (i *Instrumented) Put(ctx context.Context, key string, val any) error {
+	return i.PutWithTtl(ctx, key, val, NoExpiry)
+}
+
+func (i *Instrumented) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	ctx, end := i.start(ctx, OpPut, key)
+	err := i.cache.PutWithTtl(ctx, key, val, expiry)
+	if err != nil {
+		end(OutcomeError, nil, err)
+		return err
+	}
+	end(OutcomeOk, val, nil)
+	return nil
+}
+
+func (i *Instrumented) Fetch(ctx context.Context, key string, val any) error {
+	_, err := i.FetchWithTtl(ctx, key, val)
+	return err
+}
+
+func (i *Instrumented) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	ctx, end := i.start(ctx, OpFetch, key)
+	ttl, err := i.cache.FetchWithTtl(ctx, key, val)
+	switch {
+	case IsCacheMiss(err):
+		end(OutcomeMiss, nil, err)
+	case err != nil:
+		end(OutcomeError, nil, err)
+	default:
+		end(OutcomeHit, fetched(val), nil)
+	}
+	return ttl, err
+}
+
+func (i *Instrumented) Delete(ctx context.Context, key string) (int64, error) {
+	ctx, end := i.start(ctx, OpDelete, key)
+	n, err := i.cache.Delete(ctx, key)
+	endDelete(end, n, err)
+	return n, err
+}
+
+// bulk method implementations
+
+func (i *Instrumented) FetchMany(ctx context.Context, keys []string, vals any) error {
+	b, err := bulkOf(i.cache, "FetchMany")
+	if err != nil {
+		return err
+	}
+	ctx, end := i.start(ctx, OpFetchMany, firstKey(keys))
+	err = b.FetchMany(ctx, keys, vals)
+	switch {
+	case err != nil:
+		end(OutcomeError, nil, err)
+	case !foundAll(vals, keys):
+		end(OutcomeMiss, nil, nil)
+	default:
+		end(OutcomeHit, nil, nil)
+	}
+	return err
+}
+
+func (i *Instrumented) PutMany(ctx context.Context, items []Item) error {
+	b, err := bulkOf(i.cache, "PutMany")
+	if err != nil {
+		return err
+	}
+	var key string
+	if len(items) > 0 {
+		key = items[0].Key
+	}
+	ctx, end := i.start(ctx, OpPutMany, key)
+	if err := b.PutMany(ctx, items); err != nil {
+		end(OutcomeError, nil, err)
+		return err
+	}
+	end(OutcomeOk, nil, nil)
+	return nil
+}
+
+func (i *Instrumented) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	b, err := bulkOf(i.cache, "DeleteByPrefix")
+	if err != nil {
+		return 0, err
+	}
+	ctx, end := i.start(ctx, OpDeleteByPrefix, prefix)
+	n, err := b.DeleteByPrefix(ctx, prefix)
+	endDelete(end, n, err)
+	return n, err
+}
+
+// atomic method implementations
+
+func (i *Instrumented) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return i.incr(ctx, OpIncr, key, delta, ttl)
+}
+
+func (i *Instrumented) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return i.incr(ctx, OpDecr, key, -delta, ttl)
+}
+
+// incr adds the delta to the counter, recording the event as op
+func (i *Instrumented) incr(ctx context.Context, op Op, key string, delta int64, ttl time.Duration) (int64, error) {
+	a, err := atomicOf(i.cache, "Incr")
+	if err != nil {
+		return 0, err
+	}
+	ctx, end := i.start(ctx, op, key)
+	n, err := a.Incr(ctx, key, delta, ttl)
+	if err != nil {
+		end(OutcomeError, nil, err)
+		return 0, err
+	}
+	end(OutcomeOk, nil, nil)
+	return n, nil
+}
+
+func (i *Instrumented) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(i.cache, "SetIfAbsent")
+	if err != nil {
+		return false, err
+	}
+	ctx, end := i.start(ctx, OpSetIfAbsent, key)
+	ok, err := a.SetIfAbsent(ctx, key, val, ttl)
+	endConditional(end, ok, val, err)
+	return ok, err
+}
+
+func (i *Instrumented) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(i.cache, "CompareAndSwap")
+	if err != nil {
+		return false, err
+	}
+	ctx, end := i.start(ctx, OpCompareAndSwap, key)
+	ok, err := a.CompareAndSwap(ctx, key, old, new, ttl)
+	endConditional(end, ok, new, err)
+	return ok, err
+}
+
+// field method implementations
+
+func (i *Instrumented) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	f, err := fieldsOf(i.cache, "PutFields")
+	if err != nil {
+		return err
+	}
+	ctx, end := i.start(ctx, OpPutFields, key)
+	if err := f.PutFields(ctx, key, val, ttl, fields...); err != nil {
+		end(OutcomeError, nil, err)
+		return err
+	}
+	end(OutcomeOk, val, nil)
+	return nil
+}
+
+func (i *Instrumented) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	f, err := fieldsOf(i.cache, "FetchFields")
+	if err != nil {
+		return err
+	}
+	ctx, end := i.start(ctx, OpFetchFields, key)
+	err = f.FetchFields(ctx, key, val, fields...)
+	switch {
+	case IsCacheMiss(err):
+		end(OutcomeMiss, nil, err)
+	case err != nil:
+		end(OutcomeError, nil, err)
+	default:
+		end(OutcomeHit, fetched(val), nil)
+	}
+	return err
+}
+
+func (i *Instrumented) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	f, err := fieldsOf(i.cache, "DeleteFields")
+	if err != nil {
+		return 0, err
+	}
+	ctx, end := i.start(ctx, OpDeleteFields, key)
+	n, err := f.DeleteFields(ctx, key, fields...)
+	endDelete(end, n, err)
+	return n, err
+}
+
+// endDelete ends a delete of n entries
+func endDelete(end func(Outcome, any, error), n int64, err error) {
+	switch {
+	case err != nil:
+		end(OutcomeError, nil, err)
+	case n == 0:
+		end(OutcomeMiss, nil, nil)
+	default:
+		end(OutcomeHit, nil, nil)
+	}
+}
+
+// endConditional ends a conditional put of val, a miss if it did not apply
+func endConditional(end func(Outcome, any, error), ok bool, val any, err error) {
+	switch {
+	case err != nil:
+		end(OutcomeError, nil, err)
+	case !ok:
+		end(OutcomeMiss, nil, nil)
+	default:
+		end(OutcomeOk, val, nil)
+	}
+}
+
+// foundAll returns true if the map vals, filled by FetchMany, holds all the keys
+func foundAll(vals any, keys []string) bool {
+	m := reflect.ValueOf(vals)
+	for _, key := range keys {
+		if !m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key())).IsValid() {
+			return false
+		}
+	}
+	return true
+}
+
+// firstKey returns the first of the keys, empty if there are none
+func firstKey(keys []string) string {
+	if len(keys) == 0 {
+		return ""
+	}
+	return keys[0]
+}
+
+// fetched returns the value val points to, nil if val is not a pointer
+func fetched(val any) any {
+	ptr := reflect.ValueOf(val)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return nil
+	}
+	return ptr.Elem().Interface()
+}
@@ -0,0 +1,173 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingCache is a MemoryCache whose operations all fail.
+type failingCache struct{ NilCache }
+
+func (f *failingCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	return errors.New("put failed")
+}
+
+func (f *failingCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	return nil, errors.New("fetch failed")
+}
+
+// plainCache exposes only the MemoryCache methods of the wrapped cache.
+type plainCache struct{ MemoryCache }
+
+func TestInstrumented_Outcomes(t *testing.T) {
+	sink := &MemorySink{}
+	c := NewInstrumented(newTestRamCache(t), InstrumentOptions{Sink: sink})
+	ctx := context.Background()
+
+	_ = c.Put(ctx, "principal::a", "v")
+	var v string
+	_ = c.Fetch(ctx, "principal::a", &v)
+	_ = c.Fetch(ctx, "principal::b", &v)
+	_, _ = c.Delete(ctx, "plain")
+
+	checks := []struct {
+		op        Op
+		namespace string
+		outcome   Outcome
+	}{
+		{OpPut, "principal", OutcomeOk},
+		{OpFetch, "principal", OutcomeHit},
+		{OpFetch, "principal", OutcomeMiss},
+		{OpDelete, DefaultNamespace, OutcomeMiss},
+	}
+	for _, c := range checks {
+		if n := sink.Count(c.op, c.namespace, c.outcome); n != 1 {
+			t.Errorf("Count(%v, %v, %v) = %d; want 1", c.op, c.namespace, c.outcome, n)
+		}
+	}
+}
+
+func TestInstrumented_Extensions(t *testing.T) {
+	sink := &MemorySink{}
+	c := NewInstrumented(newTestRamCache(t), InstrumentOptions{Sink: sink})
+	ctx := context.Background()
+
+	if err := c.PutMany(ctx, []Item{{Key: "ns::a", Val: "1"}, {Key: "ns::b", Val: "2"}}); err != nil {
+		t.Fatalf("PutMany returned unexpected error: %v", err)
+	}
+	_ = c.FetchMany(ctx, []string{"ns::a", "ns::b"}, make(map[string]string))
+	_ = c.FetchMany(ctx, []string{"ns::a", "ns::c"}, make(map[string]string))
+	if n, err := c.Incr(ctx, "ns::n", 2, NoExpiry); err != nil || n != 2 {
+		t.Errorf("Incr = (%d, %v); want (2, nil)", n, err)
+	}
+	_, _ = c.SetIfAbsent(ctx, "ns::a", "3", NoExpiry)
+	_ = c.PutFields(ctx, "ns::f", struct{ Name string }{"jane"}, NoExpiry)
+	if n, err := c.DeleteByPrefix(ctx, "ns::"); err != nil || n != 4 {
+		t.Errorf("DeleteByPrefix = (%d, %v); want (4, nil)", n, err)
+	}
+
+	checks := []struct {
+		op      Op
+		outcome Outcome
+	}{
+		{OpPutMany, OutcomeOk},
+		{OpFetchMany, OutcomeHit},
+		{OpFetchMany, OutcomeMiss},
+		{OpIncr, OutcomeOk},
+		{OpSetIfAbsent, OutcomeMiss},
+		{OpPutFields, OutcomeOk},
+		{OpDeleteByPrefix, OutcomeHit},
+	}
+	for _, c := range checks {
+		if n := sink.Count(c.op, "ns", c.outcome); n != 1 {
+			t.Errorf("Count(%v, ns, %v) = %d; want 1", c.op, c.outcome, n)
+		}
+	}
+}
+
+func TestInstrumented_Unsupported(t *testing.T) {
+	c := NewInstrumented(plainCache{newTestRamCache(t)}, InstrumentOptions{})
+	if _, err := c.Incr(context.Background(), "k", 1, NoExpiry); !IsUnsupported(err) {
+		t.Errorf("Incr error = %v; want unsupported", err)
+	}
+}
+
+func TestInstrumented_Errors(t *testing.T) {
+	sink := &MemorySink{}
+	c := NewInstrumented(&failingCache{}, InstrumentOptions{Sink: sink})
+	ctx := context.Background()
+
+	if err := c.Put(ctx, "k", "v"); err == nil || err.Error() != "put failed" {
+		t.Errorf("Put error = %v; want put failed", err)
+	}
+	var v string
+	if err := c.Fetch(ctx, "k", &v); err == nil || IsCacheMiss(err) {
+		t.Errorf("Fetch error = %v; want fetch failed", err)
+	}
+
+	if n := sink.Count(OpPut, DefaultNamespace, OutcomeError); n != 1 {
+		t.Errorf("put errors = %d; want 1", n)
+	}
+	if n := sink.Count(OpFetch, DefaultNamespace, OutcomeError); n != 1 {
+		t.Errorf("fetch errors = %d; want 1", n)
+	}
+}
+
+func TestInstrumented_MeasureSize(t *testing.T) {
+	sink := &MemorySink{}
+	c := NewInstrumented(newTestRamCache(t), InstrumentOptions{Sink: sink, MeasureSize: true})
+	ctx := context.Background()
+
+	_ = c.Put(ctx, "k", "12345")
+	var v string
+	_ = c.Fetch(ctx, "k", &v)
+
+	for _, e := range sink.Events() {
+		if e.Bytes != 5 {
+			t.Errorf("%v bytes = %d; want 5", e.Op, e.Bytes)
+		}
+	}
+}
+
+func TestInstrumented_Trace(t *testing.T) {
+	type spanKey struct{}
+	var started []string
+	var finished []error
+
+	c := NewInstrumented(newTestRamCache(t), InstrumentOptions{
+		Trace: func(ctx context.Context, op Op, key string) (context.Context, func(error)) {
+			started = append(started, string(op)+" "+key)
+			return context.WithValue(ctx, spanKey{}, key), func(err error) {
+				finished = append(finished, err)
+			}
+		},
+	})
+	ctx := context.Background()
+
+	_ = c.Put(ctx, "k", "v")
+	var v string
+	_ = c.Fetch(ctx, "missing", &v)
+
+	if len(started) != 2 || started[0] != "put k" || started[1] != "fetch missing" {
+		t.Errorf("started = %v; want [put k, fetch missing]", started)
+	}
+	if len(finished) != 2 || finished[0] != nil || !IsCacheMiss(finished[1]) {
+		t.Errorf("finished = %v; want [nil, cache miss]", finished)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	tests := map[string]string{
+		"principal::sub": "principal",
+		"a::b::c":        "a",
+		"plain":          DefaultNamespace,
+		"::x":            DefaultNamespace,
+	}
+	for key, want := range tests {
+		if got := Namespace(key); got != want {
+			t.Errorf("Namespace(%q) = %q; want %q", key, got, want)
+		}
+	}
+}
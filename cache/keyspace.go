@@ -0,0 +1,289 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// MaxKeyPartLen is the length above which a key part is hashed
+const MaxKeyPartLen = 128
+
+// hashedKeyPartPrefix marks a hashed key part; parts starting with it are always
+// hashed, so a hashed part never equals a verbatim one
+const hashedKeyPartPrefix = "~"
+
+// Keyspace builds the cache keys of a service as "service::v<version>::part::part".
+// Bumping the version logically invalidates every key of the keyspace, the old keys
+// are no longer read & expire with their TTL; version 0 omits the version segment.
+// Key parts that are long or unsafe (separators, whitespace, glob & hash tag
+// characters) are replaced by their hash
+type Keyspace struct {
+	Service string
+	Version int
+}
+
+// NewKeyspace returns the Keyspace for the service & schema version
+func NewKeyspace(service string, version int) Keyspace {
+	return Keyspace{Service: service, Version: version}
+}
+
+// Prefix returns the prefix of all keys in the keyspace, e.g. for DeleteByPrefix
+func (k Keyspace) Prefix() string {
+	var b strings.Builder
+	if k.Service != "" {
+		b.WriteString(SafeKeyPart(k.Service))
+		b.WriteString(KeySeparator)
+	}
+	if k.Version > 0 {
+		b.WriteString("v" + strconv.Itoa(k.Version))
+		b.WriteString(KeySeparator)
+	}
+	return b.String()
+}
+
+// Key returns the key for the supplied parts
+func (k Keyspace) Key(parts ...string) string {
+	safe := make([]string, len(parts))
+	for i, part := range parts {
+		safe[i] = SafeKeyPart(part)
+	}
+	return k.Prefix() + strings.Join(safe, KeySeparator)
+}
+
+// Wrap returns a MemoryCache that stores every key in this keyspace
+func (k Keyspace) Wrap(c MemoryCache) *KeyspaceCache {
+	return &KeyspaceCache{cache: c, keyspace: k}
+}
+
+// SafeKeyPart returns the part, or its hash if the part is longer than MaxKeyPartLen
+// or contains unsafe characters
+func SafeKeyPart(part string) string {
+	if !unsafeKeyPart(part) {
+		return part
+	}
+	sum := sha256.Sum256([]byte(part))
+	return hashedKeyPartPrefix + hex.EncodeToString(sum[:16])
+}
+
+// unsafeKeyPart returns true if the part must be hashed
+func unsafeKeyPart(part string) bool {
+	return len(part) > MaxKeyPartLen ||
+		strings.HasPrefix(part, hashedKeyPartPrefix) ||
+		strings.Contains(part, KeySeparator) ||
+		strings.IndexFunc(part, unsafeKeyRune) >= 0
+}
+
+// unsafeKeyRune returns true for whitespace, control, glob (SCAN MATCH) & redis
+// cluster hash tag characters
+func unsafeKeyRune(r rune) bool {
+	switch r {
+	case '*', '?', '[', ']', '\\', '{', '}':
+		return true
+	}
+	return unicode.IsSpace(r) || unicode.IsControl(r)
+}
+
+// KeyspaceCache is a MemoryCache decorator that stores every key in a Keyspace; a
+// key is split on KeySeparator into the parts passed to Keyspace.Key. The BulkCache,
+// AtomicCache & FieldCache operations are forwarded with the keys in the keyspace if
+// the wrapped cache implements them
+type KeyspaceCache struct {
+	cache    MemoryCache
+	keyspace Keyspace
+}
+
+// Keyspace returns the keyspace of the cache
+func (c *KeyspaceCache) Keyspace() Keyspace {
+	return c.keyspace
+}
+
+// Key returns the key stored in the wrapped cache for the supplied key
+func (c *KeyspaceCache) Key(key string) string {
+	return c.keyspace.Key(strings.Split(key, KeySeparator)...)
+}
+
+// prefixKey returns the prefix in the wrapped cache for the supplied key prefix; parts
+// that are stored hashed can't be matched by prefix & are rejected
+func (c *KeyspaceCache) prefixKey(prefix string) (string, error) {
+	if prefix == "" {
+		return c.keyspace.Prefix(), nil
+	}
+	parts := strings.Split(prefix, KeySeparator)
+	for _, part := range parts {
+		if unsafeKeyPart(part) {
+			return "", fmt.Errorf("key prefix part %q is stored hashed & can't be matched by prefix", part)
+		}
+	}
+	return c.keyspace.Prefix() + strings.Join(parts, KeySeparator), nil
+}
+
+// Invalidate deletes every key of the keyspace, the wrapped cache must implement
+// BulkCache
+func (c *KeyspaceCache) Invalidate(ctx context.Context) (int64, error) {
+	return c.DeleteByPrefix(ctx, "")
+}
+
+// Unwrap returns the wrapped cache
+func (c *KeyspaceCache) Unwrap() MemoryCache {
+	return c.cache
+}
+
+// Close closes the wrapped cache, if it implements Lifecycle
+func (c *KeyspaceCache) Close() error {
+	if l, ok := c.cache.(Lifecycle); ok {
+		return l.Close()
+	}
+	return nil
+}
+
+// Ping checks the health of the wrapped cache, if it implements Lifecycle
+func (c *KeyspaceCache) Ping(ctx context.Context) error {
+	if l, ok := c.cache.(Lifecycle); ok {
+		return l.Ping(ctx)
+	}
+	return nil
+}
+
+// method implementations
+
+func (c *KeyspaceCache) Put(ctx context.Context, key string, val any) error {
+	return c.cache.Put(ctx, c.Key(key), val)
+}
+
+func (c *KeyspaceCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	return c.cache.PutWithTtl(ctx, c.Key(key), val, expiry)
+}
+
+func (c *KeyspaceCache) Fetch(ctx context.Context, key string, val any) error {
+	return c.cache.Fetch(ctx, c.Key(key), val)
+}
+
+func (c *KeyspaceCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	return c.cache.FetchWithTtl(ctx, c.Key(key), val)
+}
+
+func (c *KeyspaceCache) Delete(ctx context.Context, key string) (int64, error) {
+	return c.cache.Delete(ctx, c.Key(key))
+}
+
+// bulk method implementations
+
+func (c *KeyspaceCache) FetchMany(ctx context.Context, keys []string, vals any) error {
+	b, err := bulkOf(c.cache, "FetchMany")
+	if err != nil {
+		return err
+	}
+	m, err := mapTarget(vals)
+	if err != nil {
+		return err
+	}
+
+	// fetch into a map of the keys in the keyspace, then map them back
+	stored := make([]string, len(keys))
+	for i, key := range keys {
+		stored[i] = c.Key(key)
+	}
+	found := reflect.MakeMap(m.Type())
+	if err := b.FetchMany(ctx, stored, found.Interface()); err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if v := found.MapIndex(reflect.ValueOf(stored[i]).Convert(m.Type().Key())); v.IsValid() {
+			m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), v)
+		}
+	}
+	return nil
+}
+
+func (c *KeyspaceCache) PutMany(ctx context.Context, items []Item) error {
+	b, err := bulkOf(c.cache, "PutMany")
+	if err != nil {
+		return err
+	}
+	stored := make([]Item, len(items))
+	for i, item := range items {
+		stored[i] = Item{Key: c.Key(item.Key), Val: item.Val, Ttl: item.Ttl}
+	}
+	return b.PutMany(ctx, stored)
+}
+
+// DeleteByPrefix deletes the keys of the keyspace that start with the prefix; the
+// prefix parts must be safe, see SafeKeyPart, as hashed parts can't be matched
+func (c *KeyspaceCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	b, err := bulkOf(c.cache, "DeleteByPrefix")
+	if err != nil {
+		return 0, err
+	}
+	p, err := c.prefixKey(prefix)
+	if err != nil {
+		return 0, err
+	}
+	return b.DeleteByPrefix(ctx, p)
+}
+
+// atomic method implementations
+
+func (c *KeyspaceCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	a, err := atomicOf(c.cache, "Incr")
+	if err != nil {
+		return 0, err
+	}
+	return a.Incr(ctx, c.Key(key), delta, ttl)
+}
+
+func (c *KeyspaceCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	a, err := atomicOf(c.cache, "Decr")
+	if err != nil {
+		return 0, err
+	}
+	return a.Decr(ctx, c.Key(key), delta, ttl)
+}
+
+func (c *KeyspaceCache) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(c.cache, "SetIfAbsent")
+	if err != nil {
+		return false, err
+	}
+	return a.SetIfAbsent(ctx, c.Key(key), val, ttl)
+}
+
+func (c *KeyspaceCache) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(c.cache, "CompareAndSwap")
+	if err != nil {
+		return false, err
+	}
+	return a.CompareAndSwap(ctx, c.Key(key), old, new, ttl)
+}
+
+// field method implementations
+
+func (c *KeyspaceCache) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	f, err := fieldsOf(c.cache, "PutFields")
+	if err != nil {
+		return err
+	}
+	return f.PutFields(ctx, c.Key(key), val, ttl, fields...)
+}
+
+func (c *KeyspaceCache) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	f, err := fieldsOf(c.cache, "FetchFields")
+	if err != nil {
+		return err
+	}
+	return f.FetchFields(ctx, c.Key(key), val, fields...)
+}
+
+func (c *KeyspaceCache) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	f, err := fieldsOf(c.cache, "DeleteFields")
+	if err != nil {
+		return 0, err
+	}
+	return f.DeleteFields(ctx, c.Key(key), fields...)
+}
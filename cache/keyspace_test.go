@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestKeyspace_Key(t *testing.T) {
+	tests := []struct {
+		keyspace Keyspace
+		parts    []string
+		want     string
+	}{
+		{NewKeyspace("principal", 0), []string{"sub-1"}, "principal::sub-1"},
+		{NewKeyspace("orders", 2), []string{"venue", "42"}, "orders::v2::venue::42"},
+		{NewKeyspace("", 0), []string{"key"}, "key"},
+	}
+	for _, tt := range tests {
+		if got := tt.keyspace.Key(tt.parts...); got != tt.want {
+			t.Errorf("Key(%v) = %v; want %v", tt.parts, got, tt.want)
+		}
+	}
+}
+
+func TestSafeKeyPart(t *testing.T) {
+	for _, part := range []string{"sub-1", "jdoe@example.com", "auth0|123"} {
+		if got := SafeKeyPart(part); got != part {
+			t.Errorf("SafeKeyPart(%q) = %v; want the part", part, got)
+		}
+	}
+
+	for _, part := range []string{strings.Repeat("x", MaxKeyPartLen+1), "a::b", "a b", "a*", "{tag}", "~hashed"} {
+		got := SafeKeyPart(part)
+		if !strings.HasPrefix(got, hashedKeyPartPrefix) || len(got) != 33 {
+			t.Errorf("SafeKeyPart(%q) = %v; want a hash", part, got)
+		}
+		if again := SafeKeyPart(part); again != got {
+			t.Errorf("SafeKeyPart(%q) = %v, then %v; want a stable hash", part, got, again)
+		}
+	}
+}
+
+func TestKeyspaceCache_Prefixes(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+
+	v1 := NewKeyspace("svc", 1).Wrap(r)
+	if err := v1.Put(ctx, "user::1", "a"); err != nil {
+		t.Fatalf("Put returned unexpected error: %v", err)
+	}
+	var got string
+	if err := r.Fetch(ctx, "svc::v1::user::1", &got); err != nil || got != "a" {
+		t.Errorf("Fetch(svc::v1::user::1) = (%v, %v); want a", got, err)
+	}
+	if err := v1.Fetch(ctx, "user::1", &got); err != nil || got != "a" {
+		t.Errorf("Fetch(user::1) = (%v, %v); want a", got, err)
+	}
+
+	v2 := NewKeyspace("svc", 2).Wrap(r)
+	if err := v2.Fetch(ctx, "user::1", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch(user::1) in v2 error = %v; want cache miss", err)
+	}
+}
+
+func TestKeyspaceCache_Invalidate(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+
+	c := NewKeyspace("svc", 1).Wrap(r)
+	_ = c.Put(ctx, "a", "a")
+	_ = c.Put(ctx, "b", "b")
+	_ = r.Put(ctx, "other::a", "a")
+
+	if n, err := c.Invalidate(ctx); err != nil || n != 2 {
+		t.Errorf("Invalidate = (%v, %v); want 2", n, err)
+	}
+	var got string
+	if err := r.Fetch(ctx, "other::a", &got); err != nil {
+		t.Errorf("Fetch(other::a) returned unexpected error: %v", err)
+	}
+}
+
+func TestKeyspaceCache_Extensions(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	c := NewKeyspace("svc", 1).Wrap(r)
+
+	if err := c.PutMany(ctx, []Item{{Key: "user::1", Val: "a"}, {Key: "user::2", Val: "b"}, {Key: "org::1", Val: "c"}}); err != nil {
+		t.Fatalf("PutMany returned unexpected error: %v", err)
+	}
+	got := make(map[string]string)
+	if err := c.FetchMany(ctx, []string{"user::1", "user::2", "user::3"}, got); err != nil || len(got) != 2 || got["user::1"] != "a" {
+		t.Errorf("FetchMany = (%v, %v); want user::1 & user::2", got, err)
+	}
+
+	if n, err := c.Incr(ctx, "hits", 2, NoExpiry); err != nil || n != 2 {
+		t.Errorf("Incr = (%d, %v); want (2, nil)", n, err)
+	}
+	var hits int64
+	if err := r.Fetch(ctx, "svc::v1::hits", &hits); err != nil || hits != 2 {
+		t.Errorf("Fetch(svc::v1::hits) = (%d, %v); want 2", hits, err)
+	}
+
+	if n, err := c.DeleteByPrefix(ctx, "user::"); err != nil || n != 2 {
+		t.Errorf("DeleteByPrefix = (%d, %v); want (2, nil)", n, err)
+	}
+	if _, err := c.DeleteByPrefix(ctx, "user {"); err == nil {
+		t.Error("DeleteByPrefix(hashed part) returned nil error; want error")
+	}
+}
+
+func TestKeyspaceCache_Unsupported(t *testing.T) {
+	c := NewKeyspace("svc", 1).Wrap(plainCache{newTestRamCache(t)})
+	if _, err := c.Invalidate(context.Background()); !IsUnsupported(err) {
+		t.Errorf("Invalidate error = %v; want unsupported", err)
+	}
+}
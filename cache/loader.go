@@ -0,0 +1,178 @@
+package cache
+
+import (
+	"context"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// negativeSweepThreshold is the number of remembered loader errors above which
+// expired ones are swept when a new error is remembered
+const negativeSweepThreshold = 1024
+
+// ReadThroughOptions configures the loading behaviour of a ReadThrough cache
+type ReadThroughOptions struct {
+	// reload a value in the background when its remaining TTL drops below this
+	// duration, while still serving the cached value; 0 disables refresh-ahead
+	RefreshAhead time.Duration
+
+	// remember loader errors for this duration & return them without calling the
+	// loader again; 0 disables negative caching
+	NegativeTtl time.Duration
+}
+
+// Expiring is implemented by values that carry their own expiry; ReadThrough caches a
+// loaded Expiring value until it expires, instead of for the supplied TTL, & doesn't
+// cache it once expired
+type Expiring interface {
+	ExpiresAt() time.Time
+}
+
+// ReadThrough loads values into a MemoryCache on a cache miss. Concurrent misses for
+// the same key are deduplicated so the loader runs once, & all callers receive its result
+type ReadThrough struct {
+	cache MemoryCache
+	opts  ReadThroughOptions
+	group singleflight.Group
+
+	mu        sync.Mutex
+	negatives map[string]negativeEntry
+}
+
+// negativeEntry is a remembered loader error
+type negativeEntry struct {
+	err       error
+	expiresAt time.Time
+}
+
+// NewReadThrough returns a ReadThrough that loads values into the supplied cache
+func NewReadThrough(c MemoryCache, opts ReadThroughOptions) *ReadThrough {
+	return &ReadThrough{
+		cache:     c,
+		opts:      opts,
+		negatives: make(map[string]negativeEntry),
+	}
+}
+
+// GetOrLoad fetches the cached value for key into val (a pointer). On a cache miss
+// the value is loaded with the supplied loader, cached with the supplied TTL &
+// assigned to val; the loaded value must be assignable to the type val points to
+func (r *ReadThrough) GetOrLoad(ctx context.Context, key string, ttl time.Duration, val any, loader func(context.Context) (any, error)) error {
+	remaining, err := r.cache.FetchWithTtl(ctx, key, val)
+	if err == nil {
+		if r.opts.RefreshAhead > 0 && *remaining >= 0 && *remaining < r.opts.RefreshAhead {
+			r.refresh(ctx, key, ttl, loader)
+		}
+		return nil
+	}
+	if !IsCacheMiss(err) {
+		return err
+	}
+
+	if err := r.negative(key); err != nil {
+		return err
+	}
+
+	loaded, err, _ := r.group.Do(key, func() (any, error) {
+		return r.load(ctx, key, ttl, loader)
+	})
+	if err != nil {
+		return err
+	}
+	return assign(val, loaded)
+}
+
+// load runs the loader & caches its result; the load is detached from the caller's
+// cancellation as its result is shared with other callers
+func (r *ReadThrough) load(ctx context.Context, key string, ttl time.Duration, loader func(context.Context) (any, error)) (any, error) {
+	ctx = context.WithoutCancel(ctx)
+
+	loaded, err := loader(ctx)
+	if err != nil {
+		r.remember(key, err)
+		return nil, err
+	}
+	if e, ok := loaded.(Expiring); ok {
+		if ttl = time.Until(e.ExpiresAt()); ttl <= 0 {
+			return loaded, nil
+		}
+	}
+
+	if err := r.cache.PutWithTtl(ctx, key, loaded, ttl); err != nil {
+		log.Warnf("error caching loaded value for key %v: %v", key, err)
+	}
+	return loaded, nil
+}
+
+// refresh reloads the value for key in the background, unless a load is in progress
+func (r *ReadThrough) refresh(ctx context.Context, key string, ttl time.Duration, loader func(context.Context) (any, error)) {
+	log.Debugf("refreshing cached value for key %v ahead of expiry", key)
+	r.group.DoChan(key, func() (any, error) {
+		return r.load(ctx, key, ttl, loader)
+	})
+}
+
+// negative returns the remembered loader error for key, if any
+func (r *ReadThrough) negative(key string) error {
+	if r.opts.NegativeTtl <= 0 {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.negatives[key]
+	if !ok {
+		return nil
+	}
+	if !time.Now().Before(e.expiresAt) {
+		delete(r.negatives, key)
+		return nil
+	}
+	return e.err
+}
+
+// remember records a loader error for key for the negative TTL
+func (r *ReadThrough) remember(key string, err error) {
+	if r.opts.NegativeTtl <= 0 {
+		return
+	}
+
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.negatives) >= negativeSweepThreshold {
+		for k, e := range r.negatives {
+			if !now.Before(e.expiresAt) {
+				delete(r.negatives, k)
+			}
+		}
+	}
+	r.negatives[key] = negativeEntry{err: err, expiresAt: now.Add(r.opts.NegativeTtl)}
+}
+
+// assign sets the value pointed to by val to the loaded value
+func assign(val any, loaded any) error {
+	ptr := reflect.ValueOf(val)
+	if ptr.Kind() != reflect.Pointer || ptr.IsNil() {
+		return errors.New("attemp to load into a non-pointer")
+	}
+
+	ele := ptr.Elem()
+	if loaded == nil {
+		ele.Set(reflect.Zero(ele.Type()))
+		return nil
+	}
+	if !reflect.TypeOf(loaded).AssignableTo(ele.Type()) {
+		return errors.Errorf("loaded value of type %v cannot be assigned to type %v", reflect.TypeOf(loaded), ele.Type())
+	}
+	ele.Set(reflect.ValueOf(loaded))
+	return nil
+}
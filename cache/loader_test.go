@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestReadThrough_GetOrLoad_Hit(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.Put(ctx, "k", "cached")
+
+	rt := NewReadThrough(r, ReadThroughOptions{})
+	var got string
+	err := rt.GetOrLoad(ctx, "k", time.Minute, &got, func(ctx context.Context) (any, error) {
+		t.Fatal("loader called on a cache hit")
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrLoad returned unexpected error: %v", err)
+	}
+	if got != "cached" {
+		t.Errorf("GetOrLoad = %q; want %q", got, "cached")
+	}
+}
+
+func TestReadThrough_GetOrLoad_Singleflight(t *testing.T) {
+	rt := NewReadThrough(newTestRamCache(t), ReadThroughOptions{})
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	loader := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		<-release
+		return "loaded", nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := rt.GetOrLoad(ctx, "k", time.Minute, &results[i], loader); err != nil {
+				t.Errorf("GetOrLoad returned unexpected error: %v", err)
+			}
+		}(i)
+	}
+
+	time.Sleep(20 * time.Millisecond) // let all callers reach the load
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader calls = %d; want 1", n)
+	}
+	for i, got := range results {
+		if got != "loaded" {
+			t.Errorf("results[%d] = %q; want %q", i, got, "loaded")
+		}
+	}
+}
+
+func TestReadThrough_GetOrLoad_NegativeTtl(t *testing.T) {
+	rt := NewReadThrough(newTestRamCache(t), ReadThroughOptions{NegativeTtl: 30 * time.Millisecond})
+	ctx := context.Background()
+	errNotFound := errors.New("not found")
+
+	var calls atomic.Int32
+	loader := func(ctx context.Context) (any, error) {
+		calls.Add(1)
+		return nil, errNotFound
+	}
+
+	var got string
+	for i := 0; i < 3; i++ {
+		if err := rt.GetOrLoad(ctx, "k", time.Minute, &got, loader); !errors.Is(err, errNotFound) {
+			t.Fatalf("GetOrLoad error = %v; want %v", err, errNotFound)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader calls within negative TTL = %d; want 1", n)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	_ = rt.GetOrLoad(ctx, "k", time.Minute, &got, loader)
+	if n := calls.Load(); n != 2 {
+		t.Errorf("loader calls after negative TTL = %d; want 2", n)
+	}
+}
+
+func TestReadThrough_GetOrLoad_RefreshAhead(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.PutWithTtl(ctx, "k", "old", 50*time.Millisecond)
+
+	rt := NewReadThrough(r, ReadThroughOptions{RefreshAhead: time.Minute})
+	refreshed := make(chan struct{})
+	var got string
+	err := rt.GetOrLoad(ctx, "k", time.Hour, &got, func(ctx context.Context) (any, error) {
+		defer close(refreshed)
+		return "new", nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrLoad returned unexpected error: %v", err)
+	}
+	if got != "old" {
+		t.Errorf("GetOrLoad = %q; want cached %q", got, "old")
+	}
+
+	select {
+	case <-refreshed:
+	case <-time.After(time.Second):
+		t.Fatal("value was not refreshed within 1s")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if err := r.Fetch(ctx, "k", &got); err == nil && got == "new" {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("cached value = %q; want %q", got, "new")
+}
+
+func TestReadThrough_GetOrLoad_TypeMismatch(t *testing.T) {
+	rt := NewReadThrough(newTestRamCache(t), ReadThroughOptions{})
+
+	var got int
+	err := rt.GetOrLoad(context.Background(), "k", time.Minute, &got, func(ctx context.Context) (any, error) {
+		return "not an int", nil
+	})
+	if err == nil {
+		t.Fatal("GetOrLoad returned nil error; want type mismatch error")
+	}
+}
+
+// expiringValue is a loaded value that carries its own expiry.
+type expiringValue struct {
+	Val       string
+	ExpiresIn time.Duration
+	loadedAt  time.Time
+}
+
+func (v expiringValue) ExpiresAt() time.Time {
+	return v.loadedAt.Add(v.ExpiresIn)
+}
+
+func TestReadThrough_GetOrLoad_Expiring(t *testing.T) {
+	r := newTestRamCache(t)
+	rt := NewReadThrough(r, ReadThroughOptions{})
+	ctx := context.Background()
+
+	var got expiringValue
+	err := rt.GetOrLoad(ctx, "k", time.Hour, &got, func(ctx context.Context) (any, error) {
+		return expiringValue{Val: "v", ExpiresIn: time.Minute, loadedAt: time.Now()}, nil
+	})
+	if err != nil {
+		t.Fatalf("GetOrLoad returned unexpected error: %v", err)
+	}
+	ttl, err := r.FetchWithTtl(ctx, "k", &got)
+	if err != nil || *ttl > time.Minute {
+		t.Errorf("FetchWithTtl = (%v, %v); want a TTL of at most 1m", ttl, err)
+	}
+
+	err = rt.GetOrLoad(ctx, "expired", time.Hour, &got, func(ctx context.Context) (any, error) {
+		return expiringValue{Val: "v", ExpiresIn: -time.Minute, loadedAt: time.Now()}, nil
+	})
+	if err != nil || got.Val != "v" {
+		t.Fatalf("GetOrLoad(expired) = (%v, %v); want v", got, err)
+	}
+	if err := r.Fetch(ctx, "expired", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch(expired) error = %v; want cache miss", err)
+	}
+}
@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrLockHeld is returned by Acquire when the lock is held by another owner
+	ErrLockHeld = errors.New("lock is held by another owner")
+
+	// ErrLockLost is returned when a lock expired or was acquired by another owner
+	// before it was refreshed or released
+	ErrLockLost = errors.New("lock was lost")
+)
+
+// Lock is an acquired lock. The fencing token increases every time the named lock
+// is acquired, so resources protected by the lock can reject writes from an owner
+// whose lock has since expired & been acquired by another owner
+type Lock struct {
+	Name  string
+	Token string // random token identifying the owner
+	Fence int64  // fencing token
+}
+
+// lockBackend implements the atomic lock operations for a cache
+type lockBackend interface {
+	// acquire returns the fencing token, ErrLockHeld if the lock is held
+	acquire(ctx context.Context, name string, token string, ttl time.Duration) (int64, error)
+
+	// refresh extends the lock TTL, ErrLockLost if the token does not own the lock
+	refresh(ctx context.Context, name string, token string, ttl time.Duration) error
+
+	// release frees the lock, ErrLockLost if the token does not own the lock
+	release(ctx context.Context, name string, token string) error
+}
+
+// Locker is a distributed lock built on a cache; with a RedisCache (or TieredCache)
+// locks are shared by all processes using the redis server, with a RamCache they
+// are local to the process
+type Locker struct {
+	backend lockBackend
+}
+
+// NewLocker returns a Locker for the supplied cache; decorators are unwrapped (see
+// Unwrap), so locks bypass them, e.g. lock names are not in the keyspace of a
+// KeyspaceCache
+func NewLocker(c MemoryCache) (*Locker, error) {
+	switch c := Unwrap(c).(type) {
+	case *RedisCache:
+		return &Locker{backend: &redisLocks{c}}, nil
+	case *TieredCache:
+		return &Locker{backend: &redisLocks{c.l2}}, nil
+	case *RamCache:
+		return &Locker{backend: &c.locks}, nil
+	default:
+		return nil, fmt.Errorf("cache %T does not support locking", c)
+	}
+}
+
+// Acquire acquires the named lock for the supplied TTL, or returns ErrLockHeld
+func (l *Locker) Acquire(ctx context.Context, name string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("lock ttl must be positive, got %v", ttl)
+	}
+
+	token, err := lockToken()
+	if err != nil {
+		return nil, err
+	}
+	fence, err := l.backend.acquire(ctx, name, token, ttl)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{Name: name, Token: token, Fence: fence}, nil
+}
+
+// Refresh extends the TTL of the lock, or returns ErrLockLost
+func (l *Locker) Refresh(ctx context.Context, lock *Lock, ttl time.Duration) error {
+	if ttl <= 0 {
+		return fmt.Errorf("lock ttl must be positive, got %v", ttl)
+	}
+	return l.backend.refresh(ctx, lock.Name, lock.Token, ttl)
+}
+
+// Release frees the lock, or returns ErrLockLost if it is no longer owned
+func (l *Locker) Release(ctx context.Context, lock *Lock) error {
+	return l.backend.release(ctx, lock.Name, lock.Token)
+}
+
+// WithLock acquires the named lock, runs fn & releases the lock. The lock is refreshed
+// every ttl/3 while fn runs; if it is lost, the context passed to fn is cancelled &
+// ErrLockLost is returned unless fn returns an error of its own
+func (l *Locker) WithLock(ctx context.Context, name string, ttl time.Duration, fn func(context.Context) error) error {
+	lock, err := l.Acquire(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+
+	fctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		l.renew(fctx, lock, ttl, done, cancel)
+	}()
+
+	err = fn(fctx)
+	close(done)
+	wg.Wait()
+
+	releaseErr := l.Release(context.WithoutCancel(ctx), lock)
+	if err != nil {
+		return err
+	}
+	if cause := context.Cause(fctx); errors.Is(cause, ErrLockLost) {
+		return cause
+	}
+	return releaseErr
+}
+
+// renew refreshes the lock every ttl/3 until done is closed. Transient errors are
+// retried, but the lock is considered lost once it is not refreshed within its TTL
+func (l *Locker) renew(ctx context.Context, lock *Lock, ttl time.Duration, done <-chan struct{}, cancel context.CancelCauseFunc) {
+	interval := ttl / 3
+	if interval <= 0 {
+		interval = ttl
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	refreshed := time.Now()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			err := l.Refresh(context.WithoutCancel(ctx), lock, ttl)
+			switch {
+			case err == nil:
+				refreshed = time.Now()
+			case errors.Is(err, ErrLockLost):
+				cancel(fmt.Errorf("%w: %v", ErrLockLost, lock.Name))
+				return
+			case time.Since(refreshed) >= ttl:
+				cancel(fmt.Errorf("%w: %v not refreshed within its ttl: %v", ErrLockLost, lock.Name, err))
+				return
+			default:
+				log.Warnf("error refreshing lock %v: %v", lock.Name, err)
+			}
+		}
+	}
+}
+
+// lockToken returns a random owner token
+func lockToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+//
+// redis
+//
+
+// the lock & fence keys share a hash tag, so both are in the same cluster slot
+func redisLockKeys(name string) []string {
+	return []string{"lock" + KeySeparator + "{" + name + "}", "lock" + KeySeparator + "{" + name + "}" + KeySeparator + "fence"}
+}
+
+var (
+	redisAcquireScript = redisv9.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0`)
+
+	redisRefreshScript = redisv9.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+
+	redisReleaseScript = redisv9.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// redisLocks implements locks with SET NX PX & compare-and-delete lua scripts
+type redisLocks struct {
+	cache *RedisCache
+}
+
+func (r *redisLocks) acquire(ctx context.Context, name string, token string, ttl time.Duration) (int64, error) {
+	fence, err := redisAcquireScript.Run(ctx, r.cache.client, redisLockKeys(name), token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return 0, err
+	}
+	if fence == 0 {
+		return 0, ErrLockHeld
+	}
+	return fence, nil
+}
+
+func (r *redisLocks) refresh(ctx context.Context, name string, token string, ttl time.Duration) error {
+	return r.run(ctx, redisRefreshScript, name, token, ttl.Milliseconds())
+}
+
+func (r *redisLocks) release(ctx context.Context, name string, token string) error {
+	return r.run(ctx, redisReleaseScript, name, token)
+}
+
+// run runs a compare-and-act script, ErrLockLost if the token does not own the lock
+func (r *redisLocks) run(ctx context.Context, script *redisv9.Script, name string, args ...any) error {
+	n, err := script.Run(ctx, r.cache.client, redisLockKeys(name)[:1], args...).Int64()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+//
+// ram
+//
+
+// ramLocks implements in-process locks for a RamCache; locks are kept apart from
+// the cached entries so they are never evicted
+type ramLocks struct {
+	mu     sync.Mutex
+	owners map[string]ramLockOwner
+	fences map[string]int64
+}
+
+type ramLockOwner struct {
+	token     string
+	expiresAt time.Time
+}
+
+// owner returns the unexpired owner of the lock, forgetting an expired owner; the
+// caller must hold the lock
+func (r *ramLocks) owner(name string) (ramLockOwner, bool) {
+	o, ok := r.owners[name]
+	if !ok {
+		return ramLockOwner{}, false
+	}
+	if !time.Now().Before(o.expiresAt) {
+		delete(r.owners, name)
+		return ramLockOwner{}, false
+	}
+	return o, true
+}
+
+func (r *ramLocks) acquire(ctx context.Context, name string, token string, ttl time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, held := r.owner(name); held {
+		return 0, ErrLockHeld
+	}
+	if r.owners == nil {
+		r.owners = make(map[string]ramLockOwner)
+		r.fences = make(map[string]int64)
+	}
+	r.owners[name] = ramLockOwner{token: token, expiresAt: time.Now().Add(ttl)}
+	r.fences[name]++
+	return r.fences[name], nil
+}
+
+func (r *ramLocks) refresh(ctx context.Context, name string, token string, ttl time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if o, held := r.owner(name); !held || o.token != token {
+		return ErrLockLost
+	}
+	r.owners[name] = ramLockOwner{token: token, expiresAt: time.Now().Add(ttl)}
+	return nil
+}
+
+func (r *ramLocks) release(ctx context.Context, name string, token string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if o, held := r.owner(name); !held || o.token != token {
+		return ErrLockLost
+	}
+	delete(r.owners, name)
+	return nil
+}
@@ -0,0 +1,158 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testLockers returns a Locker for each supported backend.
+func testLockers(t *testing.T) map[string]*Locker {
+	t.Helper()
+	r, _ := newTestRedisCache(t)
+
+	lockers := map[string]*Locker{}
+	for name, c := range map[string]MemoryCache{"ram": newTestRamCache(t), "redis": r} {
+		l, err := NewLocker(c)
+		if err != nil {
+			t.Fatalf("NewLocker(%v) returned unexpected error: %v", name, err)
+		}
+		lockers[name] = l
+	}
+	return lockers
+}
+
+func TestLocker_AcquireRelease(t *testing.T) {
+	for name, l := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			first, err := l.Acquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("Acquire returned unexpected error: %v", err)
+			}
+			if _, err := l.Acquire(ctx, "job", time.Minute); !errors.Is(err, ErrLockHeld) {
+				t.Errorf("second Acquire error = %v; want ErrLockHeld", err)
+			}
+			if err := l.Release(ctx, first); err != nil {
+				t.Fatalf("Release returned unexpected error: %v", err)
+			}
+
+			second, err := l.Acquire(ctx, "job", time.Minute)
+			if err != nil {
+				t.Fatalf("Acquire after Release returned unexpected error: %v", err)
+			}
+			if second.Fence <= first.Fence {
+				t.Errorf("Fence = %d; want > %d", second.Fence, first.Fence)
+			}
+		})
+	}
+}
+
+func TestLocker_Release_NotOwner(t *testing.T) {
+	for name, l := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lock, _ := l.Acquire(ctx, "job", time.Minute)
+
+			other := &Lock{Name: lock.Name, Token: "not-the-owner"}
+			if err := l.Release(ctx, other); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Release by other owner error = %v; want ErrLockLost", err)
+			}
+			if err := l.Refresh(ctx, other, time.Minute); !errors.Is(err, ErrLockLost) {
+				t.Errorf("Refresh by other owner error = %v; want ErrLockLost", err)
+			}
+			if err := l.Refresh(ctx, lock, time.Minute); err != nil {
+				t.Errorf("Refresh by owner returned unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestLocker_Expiry(t *testing.T) {
+	r, m := newTestRedisCache(t)
+	l, _ := NewLocker(r)
+	ctx := context.Background()
+
+	lock, _ := l.Acquire(ctx, "job", time.Second)
+	m.FastForward(2 * time.Second)
+
+	if _, err := l.Acquire(ctx, "job", time.Second); err != nil {
+		t.Fatalf("Acquire after expiry returned unexpected error: %v", err)
+	}
+	if err := l.Release(ctx, lock); !errors.Is(err, ErrLockLost) {
+		t.Errorf("Release of expired lock error = %v; want ErrLockLost", err)
+	}
+}
+
+func TestLocker_WithLock_Renews(t *testing.T) {
+	l, _ := NewLocker(newTestRamCache(t))
+	ctx := context.Background()
+
+	err := l.WithLock(ctx, "job", 30*time.Millisecond, func(ctx context.Context) error {
+		time.Sleep(100 * time.Millisecond)
+		if _, err := l.Acquire(ctx, "job", time.Minute); !errors.Is(err, ErrLockHeld) {
+			t.Errorf("Acquire during WithLock error = %v; want ErrLockHeld", err)
+		}
+		return ctx.Err()
+	})
+	if err != nil {
+		t.Fatalf("WithLock returned unexpected error: %v", err)
+	}
+
+	if _, err := l.Acquire(ctx, "job", time.Minute); err != nil {
+		t.Errorf("Acquire after WithLock returned unexpected error: %v", err)
+	}
+}
+
+func TestLocker_WithLock_Lost(t *testing.T) {
+	c := newTestRamCache(t)
+	l, _ := NewLocker(c)
+
+	var cancelled atomic.Bool
+	err := l.WithLock(context.Background(), "job", 30*time.Millisecond, func(ctx context.Context) error {
+		// another owner steals the lock
+		c.locks.mu.Lock()
+		c.locks.owners["job"] = ramLockOwner{token: "thief", expiresAt: time.Now().Add(time.Minute)}
+		c.locks.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			cancelled.Store(true)
+		case <-time.After(time.Second):
+		}
+		return nil
+	})
+
+	if !errors.Is(err, ErrLockLost) {
+		t.Errorf("WithLock error = %v; want ErrLockLost", err)
+	}
+	if !cancelled.Load() {
+		t.Error("fn context was not cancelled when the lock was lost")
+	}
+}
+
+func TestNewLocker_Unsupported(t *testing.T) {
+	if _, err := NewLocker(&NilCache{}); err == nil {
+		t.Fatal("NewLocker(NilCache) returned nil error; want error")
+	}
+}
+
+func TestNewLocker_Decorated(t *testing.T) {
+	r, _ := newTestRedisCache(t)
+	resilient, err := NewResilient(NewInstrumented(NewKeyspace("svc", 1).Wrap(r), InstrumentOptions{}), ResilienceConfig{})
+	if err != nil {
+		t.Fatalf("NewResilient returned unexpected error: %v", err)
+	}
+	t.Cleanup(resilient.closeLocal)
+
+	l, err := NewLocker(resilient)
+	if err != nil {
+		t.Fatalf("NewLocker returned unexpected error: %v", err)
+	}
+	if _, ok := l.backend.(*redisLocks); !ok {
+		t.Errorf("backend = %T; want *redisLocks", l.backend)
+	}
+}
@@ -3,18 +3,234 @@ package cache
 import (
 	"context"
 	"reflect"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/pkg/errors"
 )
 
-// RamCache a MemoryCache implementation for internal memory
+// DefaultJanitorInterval is the interval at which expired entries are purged
+// from a RamCache when no interval is configured
+const DefaultJanitorInterval = time.Minute
+
+// ramEntry is a value stored in the RamCache along with its deadline
+type ramEntry struct {
+	val       any
+	expiresAt time.Time // zero value means the entry never expires
+	size      int64     // estimated size in bytes, only tracked with a byte budget
+}
+
+// expired returns true if the entry has a deadline that has passed
+func (e ramEntry) expired(now time.Time) bool {
+	return !e.expiresAt.IsZero() && !now.Before(e.expiresAt)
+}
+
+// RamCache a MemoryCache implementation for internal memory. A RamCache is safe for
+// concurrent use; when bounded by entry count or byte budget, entries are evicted
+// using the configured EvictionPolicy
 type RamCache struct {
-	rmap map[string]any
+	mu         sync.RWMutex
+	rmap       map[string]ramEntry
+	interval   time.Duration
+	stop       chan struct{}
+	stopOnce   sync.Once
+	maxEntries int
+	maxBytes   int64
+	bytes      int64
+	policy     EvictionPolicy // nil for an unbounded cache
+	locks      ramLocks       // see NewLocker
+	buckets    ramBuckets     // see NewRateLimiter
+}
+
+// NewRamCache returns a new RamCache with a running janitor goroutine that purges
+// expired entries; call Stop to terminate the janitor when the cache is no longer used
+func NewRamCache(cfg RamConfig) (*RamCache, error) {
+	r := &RamCache{
+		maxEntries: cfg.MaxEntries,
+		maxBytes:   cfg.MaxBytes,
+	}
+	if cfg.JanitorInterval != nil {
+		r.interval = *cfg.JanitorInterval
+	}
+
+	if r.bounded() {
+		r.policy = cfg.Policy
+		if r.policy == nil {
+			var err error
+			if r.policy, err = NewEvictionPolicy(cfg.Eviction); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	r.initialize()
+	return r, nil
+}
+
+// bounded returns true if the cache has a maximum entry count or byte budget
+func (r *RamCache) bounded() bool {
+	return r.maxEntries > 0 || r.maxBytes > 0
 }
 
 func (r *RamCache) initialize() {
-	r.rmap = make(map[string]any)
+	r.rmap = make(map[string]ramEntry)
+	r.stop = make(chan struct{})
+
+	if r.interval <= 0 {
+		r.interval = DefaultJanitorInterval
+	}
+	go r.janitor()
+}
+
+// janitor periodically purges expired entries until the cache is stopped
+func (r *RamCache) janitor() {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			r.purgeExpired()
+		case <-r.stop:
+			return
+		}
+	}
+}
+
+// purgeExpired removes all expired entries from the cache
+func (r *RamCache) purgeExpired() {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for k, e := range r.rmap {
+		if e.expired(now) {
+			r.remove(k)
+		}
+	}
+}
+
+// Stop terminates the janitor goroutine; it is safe to call Stop more than once
+func (r *RamCache) Stop() {
+	r.stopOnce.Do(func() {
+		if r.stop != nil {
+			close(r.stop)
+		}
+	})
+}
+
+// Close stops the janitor, see Stop
+func (r *RamCache) Close() error {
+	r.Stop()
+	return nil
+}
+
+// Ping returns nil, an in-process cache is always healthy
+func (r *RamCache) Ping(ctx context.Context) error {
+	return nil
+}
+
+// Len returns the number of entries in the cache, including expired entries that
+// have not been purged yet
+func (r *RamCache) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.rmap)
+}
+
+// lookup returns the unexpired entry stored for key
+func (r *RamCache) lookup(key string) (ramEntry, bool) {
+	// the eviction policy records accesses, so a bounded cache needs the write lock
+	if r.policy != nil {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	} else {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+	}
+
+	e, ok := r.rmap[key]
+	if !ok || e.expired(time.Now()) {
+		return ramEntry{}, false
+	}
+	if r.policy != nil {
+		r.policy.Accessed(key)
+	}
+	return e, true
+}
+
+// store saves the entry for key, evicting entries first to make room when the cache
+// is bounded; the caller must hold the write lock
+func (r *RamCache) store(key string, e ramEntry) {
+	old, exists := r.rmap[key]
+	if exists {
+		r.bytes -= old.size
+		delete(r.rmap, key)
+	}
+
+	if r.policy != nil {
+		for r.overBudget(e.size) {
+			victim, ok := r.policy.Victim()
+			if !ok {
+				break
+			}
+			if victim == key {
+				// the key being overwritten is the victim, it is re-added with fresh usage
+				r.policy.Removed(key)
+				exists = false
+				continue
+			}
+			r.remove(victim)
+		}
+
+		if exists {
+			r.policy.Accessed(key)
+		} else {
+			r.policy.Added(key)
+		}
+	}
+
+	r.rmap[key] = e
+	r.bytes += e.size
+}
+
+// overBudget returns true if adding an entry of the supplied size would exceed
+// the cache bounds
+func (r *RamCache) overBudget(size int64) bool {
+	return (r.maxEntries > 0 && len(r.rmap)+1 > r.maxEntries) ||
+		(r.maxBytes > 0 && r.bytes+size > r.maxBytes)
+}
+
+// newEntry returns the entry for a value stored with the supplied expiry, or an
+// error if the value exceeds the byte budget
+func (r *RamCache) newEntry(key string, val any, expiry time.Duration) (ramEntry, error) {
+	e := ramEntry{val: val}
+	if expiry > 0 {
+		e.expiresAt = time.Now().Add(expiry)
+	}
+
+	if r.maxBytes > 0 {
+		e.size = sizeOf(val)
+		if e.size > r.maxBytes {
+			return ramEntry{}, &ValueError{errors.Errorf("value of %v bytes for key %v exceeds the ram cache budget of %v bytes", e.size, key, r.maxBytes)}
+		}
+	}
+	return e, nil
+}
+
+// remove deletes the entry for key; the caller must hold the write lock
+func (r *RamCache) remove(key string) (ramEntry, bool) {
+	e, ok := r.rmap[key]
+	if !ok {
+		return ramEntry{}, false
+	}
+	delete(r.rmap, key)
+	r.bytes -= e.size
+	if r.policy != nil {
+		r.policy.Removed(key)
+	}
+	return e, true
 }
 
 // method implementations
@@ -24,40 +240,332 @@ func (r *RamCache) Put(ctx context.Context, key string, val any) error {
 }
 
 func (r *RamCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
-	r.rmap[key] = val
+	e, err := r.newEntry(key, val, expiry)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store(key, e)
 	return nil
 }
 
 func (r *RamCache) Fetch(ctx context.Context, key string, val any) error {
+	_, err := r.fetch(key, val)
+	return err
+}
+
+func (r *RamCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	e, err := r.fetch(key, val)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := PersistentTtl
+	if !e.expiresAt.IsZero() {
+		ttl = time.Until(e.expiresAt)
+	}
+	return &ttl, nil
+}
+
+func (r *RamCache) Delete(ctx context.Context, key string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.remove(key)
+	if !ok || e.expired(time.Now()) {
+		return 0, nil
+	}
+	return 1, nil
+}
+
+// fetch copies the value stored for key into val (a pointer) & returns the entry
+func (r *RamCache) fetch(key string, val any) (ramEntry, error) {
 	// the supplied val must be a pointer
 	value_of_val := reflect.ValueOf(val)
 	if value_of_val.Kind() != reflect.Pointer {
-		return errors.New("attemp to Fetch into a non-pointer")
+		return ramEntry{}, &ValueError{errors.New("attemp to Fetch into a non-pointer")}
 	}
 
 	// let's fetch the value first...
-	if _val, ok := r.rmap[key]; ok {
-		// TODO the value is found, we return it via val (interface{}) by ref...
-		ele := value_of_val.Elem() // value in val (any)
-		if !reflect.TypeOf(_val).AssignableTo(ele.Type()) {
-			return errors.Errorf("value of type %v cannot be assigned to type %v", reflect.TypeOf(_val), ele.Type())
+	e, ok := r.lookup(key)
+	if !ok {
+		return ramEntry{}, &CacheMissError{key, errors.Errorf("key not found in ram cache")}
+	}
+
+	// the value is found, we return it via val (interface{}) by ref...
+	ele := value_of_val.Elem() // value in val (any)
+	if e.val == nil {
+		ele.Set(reflect.Zero(ele.Type()))
+		return e, nil
+	}
+	if !reflect.TypeOf(e.val).AssignableTo(ele.Type()) {
+		return ramEntry{}, &ValueError{errors.Errorf("value of type %v cannot be assigned to type %v", reflect.TypeOf(e.val), ele.Type())}
+	}
+	ele.Set(reflect.ValueOf(e.val))
+	return e, nil
+}
+
+// sizeOf estimates the size in bytes of a cached value; strings & byte slices are
+// measured exactly, fields by their names & values, other values by their gob encoding
+func sizeOf(val any) int64 {
+	switch v := val.(type) {
+	case nil:
+		return 0
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case ramFields:
+		var n int64
+		for name, fv := range v {
+			n += int64(len(name)) + sizeOf(fv)
 		}
-		ele.Set(reflect.ValueOf(_val))
-		return nil
+		return n
+	}
+
+	if b, err := (GobSerde{}).Ser(val); err == nil {
+		return int64(len(b))
 	}
-	return &CacheMissError{key, errors.Errorf("key not found in ram cache")}
+	return int64(reflect.TypeOf(val).Size())
 }
 
-func (r *RamCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
-	if err := r.Fetch(ctx, key, val); err != nil {
-		return nil, err
+// bulk method implementations
+
+func (r *RamCache) FetchMany(ctx context.Context, keys []string, vals any) error {
+	m, err := mapTarget(vals)
+	if err != nil {
+		return err
 	}
 
-	exp := time.Hour * 24 * 365 * 290
-	return &exp, nil
+	for _, key := range keys {
+		e, ok := r.lookup(key)
+		if !ok {
+			continue
+		}
+		if err := setMapValue(m, key, e.val); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *RamCache) Delete(ctx context.Context, key string) (int64, error) {
-	delete(r.rmap, key)
-	return 0, nil
+func (r *RamCache) PutMany(ctx context.Context, items []Item) error {
+	entries := make([]ramEntry, len(items))
+	for i, item := range items {
+		e, err := r.newEntry(item.Key, item.Val, item.Ttl)
+		if err != nil {
+			return err
+		}
+		entries[i] = e
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i, item := range items {
+		r.store(item.Key, entries[i])
+	}
+	return nil
+}
+
+func (r *RamCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var n int64
+	for k, e := range r.rmap {
+		if strings.HasPrefix(k, prefix) {
+			r.remove(k)
+			if !e.expired(now) {
+				n++
+			}
+		}
+	}
+	return n, nil
+}
+
+// atomic method implementations
+
+func (r *RamCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.rmap[key]
+	if !ok || e.expired(time.Now()) {
+		ne, err := r.newEntry(key, delta, ttl)
+		if err != nil {
+			return 0, err
+		}
+		r.store(key, ne)
+		return delta, nil
+	}
+
+	n, ok := e.val.(int64)
+	if !ok {
+		return 0, &ValueError{errors.Errorf("value of type %T for key %v is not an int64 counter", e.val, key)}
+	}
+	e.val = n + delta
+	r.store(key, e)
+	return n + delta, nil
+}
+
+func (r *RamCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return r.Incr(ctx, key, -delta, ttl)
+}
+
+func (r *RamCache) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	e, err := r.newEntry(key, val, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if old, ok := r.rmap[key]; ok && !old.expired(time.Now()) {
+		return false, nil
+	}
+	r.store(key, e)
+	return true, nil
+}
+
+// CompareAndSwap compares the current value to old with reflect.DeepEqual
+func (r *RamCache) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	e, err := r.newEntry(key, new, ttl)
+	if err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	cur, ok := r.rmap[key]
+	if !ok || cur.expired(time.Now()) || !reflect.DeepEqual(cur.val, old) {
+		return false, nil
+	}
+	r.store(key, e)
+	return true, nil
+}
+
+// field method implementations
+
+// ramFields is the value of an entry stored with PutFields, keyed by field name;
+// it is replaced, never modified, so fetched values are not changed concurrently
+type ramFields map[string]any
+
+// fieldsEntry returns the unexpired fields stored for key; the caller must hold the
+// lock
+func (r *RamCache) fieldsEntry(key string) (ramEntry, ramFields, error) {
+	e, ok := r.rmap[key]
+	if !ok || e.expired(time.Now()) {
+		return ramEntry{}, nil, nil
+	}
+	fields, ok := e.val.(ramFields)
+	if !ok {
+		return ramEntry{}, nil, &ValueError{errors.Errorf("value of type %T for key %v is not stored by field", e.val, key)}
+	}
+	return e, fields, nil
+}
+
+func (r *RamCache) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	values, err := fieldValues(val, fields)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, oldFields, err := r.fieldsEntry(key)
+	if err != nil {
+		return err
+	}
+
+	merged := make(ramFields, len(oldFields)+len(values))
+	for name, v := range oldFields {
+		merged[name] = v
+	}
+	for name, v := range values {
+		merged[name] = v
+	}
+
+	e, err := r.newEntry(key, merged, ttl)
+	if err != nil {
+		return err
+	}
+	if ttl <= 0 {
+		e.expiresAt = old.expiresAt
+	}
+	r.store(key, e)
+	return nil
+}
+
+func (r *RamCache) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	targets, err := fieldTargets(val, fields)
+	if err != nil {
+		return err
+	}
+
+	e, ok := r.lookup(key)
+	if !ok {
+		return &CacheMissError{key, errors.Errorf("key not found in ram cache")}
+	}
+	stored, ok := e.val.(ramFields)
+	if !ok {
+		return &ValueError{errors.Errorf("value of type %T for key %v is not stored by field", e.val, key)}
+	}
+
+	for name, target := range targets {
+		v, ok := stored[name]
+		if !ok {
+			continue
+		}
+		ele := reflect.ValueOf(target).Elem()
+		if v == nil {
+			ele.Set(reflect.Zero(ele.Type()))
+			continue
+		}
+		if !reflect.TypeOf(v).AssignableTo(ele.Type()) {
+			return &ValueError{errors.Errorf("field %v of type %T cannot be assigned to type %v", name, v, ele.Type())}
+		}
+		ele.Set(reflect.ValueOf(v))
+	}
+	return nil
+}
+
+func (r *RamCache) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, oldFields, err := r.fieldsEntry(key)
+	if err != nil || oldFields == nil {
+		return 0, err
+	}
+
+	remaining := make(ramFields, len(oldFields))
+	for name, v := range oldFields {
+		remaining[name] = v
+	}
+	var n int64
+	for _, name := range fields {
+		if _, ok := remaining[name]; ok {
+			delete(remaining, name)
+			n++
+		}
+	}
+
+	// like a redis hash, the key is removed with its last field
+	if len(remaining) == 0 {
+		r.remove(key)
+		return n, nil
+	}
+	old.val = remaining
+	if r.maxBytes > 0 {
+		old.size = sizeOf(remaining)
+	}
+	r.store(key, old)
+	return n, nil
 }
@@ -0,0 +1,270 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestRamCache returns a RamCache with a fast janitor that is stopped at test cleanup.
+func newTestRamCache(t *testing.T) *RamCache {
+	return newTestRamCacheWithConfig(t, RamConfig{})
+}
+
+// newTestRamCacheWithConfig returns a RamCache for the supplied config with a fast
+// janitor that is stopped at test cleanup.
+func newTestRamCacheWithConfig(t *testing.T, cfg RamConfig) *RamCache {
+	t.Helper()
+	interval := 10 * time.Millisecond
+	cfg.JanitorInterval = &interval
+	r, err := NewRamCache(cfg)
+	if err != nil {
+		t.Fatalf("NewRamCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(r.Stop)
+	return r
+}
+
+func TestRamCache_PutFetch(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+
+	if err := r.Put(ctx, "k", "v"); err != nil {
+		t.Fatalf("Put returned unexpected error: %v", err)
+	}
+
+	var got string
+	if err := r.Fetch(ctx, "k", &got); err != nil {
+		t.Fatalf("Fetch returned unexpected error: %v", err)
+	}
+	if got != "v" {
+		t.Errorf("Fetch = %q; want %q", got, "v")
+	}
+}
+
+func TestRamCache_Fetch_Miss(t *testing.T) {
+	r := newTestRamCache(t)
+
+	var got string
+	err := r.Fetch(context.Background(), "missing", &got)
+
+	var miss *CacheMissError
+	if !errors.As(err, &miss) {
+		t.Fatalf("Fetch error = %v; want *CacheMissError", err)
+	}
+}
+
+func TestRamCache_Fetch_NonPointer(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.Put(ctx, "k", "v")
+
+	var got string
+	if err := r.Fetch(ctx, "k", got); err == nil {
+		t.Fatal("Fetch into non-pointer returned nil error; want error")
+	}
+}
+
+func TestRamCache_Fetch_TypeMismatch(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.Put(ctx, "k", "v")
+
+	var got int
+	if err := r.Fetch(ctx, "k", &got); err == nil {
+		t.Fatal("Fetch into mismatched type returned nil error; want error")
+	}
+}
+
+func TestRamCache_PutWithTtl_Expires(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+
+	if err := r.PutWithTtl(ctx, "k", "v", 20*time.Millisecond); err != nil {
+		t.Fatalf("PutWithTtl returned unexpected error: %v", err)
+	}
+	time.Sleep(30 * time.Millisecond)
+
+	var got string
+	var miss *CacheMissError
+	if err := r.Fetch(ctx, "k", &got); !errors.As(err, &miss) {
+		t.Fatalf("Fetch after expiry error = %v; want *CacheMissError", err)
+	}
+}
+
+func TestRamCache_FetchWithTtl_Remaining(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.PutWithTtl(ctx, "k", "v", time.Minute)
+
+	var got string
+	ttl, err := r.FetchWithTtl(ctx, "k", &got)
+	if err != nil {
+		t.Fatalf("FetchWithTtl returned unexpected error: %v", err)
+	}
+	if *ttl <= 0 || *ttl > time.Minute {
+		t.Errorf("FetchWithTtl ttl = %v; want (0, 1m]", *ttl)
+	}
+}
+
+func TestRamCache_FetchWithTtl_NoExpiry(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.Put(ctx, "k", "v")
+
+	var got string
+	ttl, err := r.FetchWithTtl(ctx, "k", &got)
+	if err != nil {
+		t.Fatalf("FetchWithTtl returned unexpected error: %v", err)
+	}
+	if *ttl != PersistentTtl {
+		t.Errorf("FetchWithTtl ttl = %v; want %v", *ttl, PersistentTtl)
+	}
+}
+
+func TestRamCache_Janitor_PurgesExpired(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.PutWithTtl(ctx, "k", "v", 5*time.Millisecond)
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if r.Len() == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("janitor did not purge expired entry within 1s")
+}
+
+func TestRamCache_Delete(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+	_ = r.Put(ctx, "k", "v")
+
+	n, err := r.Delete(ctx, "k")
+	if err != nil {
+		t.Fatalf("Delete returned unexpected error: %v", err)
+	}
+	if n != 1 {
+		t.Errorf("Delete = %d; want 1", n)
+	}
+
+	if n, _ = r.Delete(ctx, "k"); n != 0 {
+		t.Errorf("second Delete = %d; want 0", n)
+	}
+}
+
+func TestRamCache_Stop_Idempotent(t *testing.T) {
+	r, _ := NewRamCache(RamConfig{})
+	r.Stop()
+	r.Stop()
+}
+
+func TestNewRamCache_UnknownEviction(t *testing.T) {
+	if _, err := NewRamCache(RamConfig{MaxEntries: 1, Eviction: "fifo"}); err == nil {
+		t.Fatal("NewRamCache with unknown eviction returned nil error; want error")
+	}
+}
+
+func TestRamCache_MaxEntries_LRU(t *testing.T) {
+	r := newTestRamCacheWithConfig(t, RamConfig{MaxEntries: 2, Eviction: LRU})
+	ctx := context.Background()
+
+	_ = r.Put(ctx, "a", 1)
+	_ = r.Put(ctx, "b", 2)
+
+	var v int
+	_ = r.Fetch(ctx, "a", &v) // a is now more recently used than b
+	_ = r.Put(ctx, "c", 3)
+
+	if r.Len() != 2 {
+		t.Fatalf("Len = %d; want 2", r.Len())
+	}
+	if err := r.Fetch(ctx, "b", &v); err == nil {
+		t.Error("Fetch(b) returned nil error; want b evicted")
+	}
+	for _, k := range []string{"a", "c"} {
+		if err := r.Fetch(ctx, k, &v); err != nil {
+			t.Errorf("Fetch(%v) returned unexpected error: %v", k, err)
+		}
+	}
+}
+
+func TestRamCache_MaxEntries_LFU(t *testing.T) {
+	r := newTestRamCacheWithConfig(t, RamConfig{MaxEntries: 2, Eviction: LFU})
+	ctx := context.Background()
+
+	_ = r.Put(ctx, "a", 1)
+	_ = r.Put(ctx, "b", 2)
+
+	var v int
+	for i := 0; i < 3; i++ {
+		_ = r.Fetch(ctx, "a", &v)
+	}
+	_ = r.Fetch(ctx, "b", &v) // b is more recent, but less frequently used
+	_ = r.Put(ctx, "c", 3)
+
+	if err := r.Fetch(ctx, "b", &v); err == nil {
+		t.Error("Fetch(b) returned nil error; want b evicted")
+	}
+	if err := r.Fetch(ctx, "a", &v); err != nil {
+		t.Errorf("Fetch(a) returned unexpected error: %v", err)
+	}
+}
+
+func TestRamCache_MaxBytes(t *testing.T) {
+	r := newTestRamCacheWithConfig(t, RamConfig{MaxBytes: 10})
+	ctx := context.Background()
+
+	_ = r.Put(ctx, "a", "12345")
+	_ = r.Put(ctx, "b", "12345")
+	_ = r.Put(ctx, "c", "12345")
+
+	if r.Len() != 2 {
+		t.Errorf("Len = %d; want 2", r.Len())
+	}
+
+	var v string
+	if err := r.Fetch(ctx, "a", &v); err == nil {
+		t.Error("Fetch(a) returned nil error; want a evicted")
+	}
+}
+
+func TestRamCache_MaxBytes_ValueTooLarge(t *testing.T) {
+	r := newTestRamCacheWithConfig(t, RamConfig{MaxBytes: 4})
+
+	if err := r.Put(context.Background(), "a", "12345"); err == nil {
+		t.Fatal("Put of oversized value returned nil error; want error")
+	}
+}
+
+func TestRamCache_Concurrent(t *testing.T) {
+	r := newTestRamCacheWithConfig(t, RamConfig{MaxEntries: 50})
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				k := fmt.Sprintf("k%d", (g*i)%80)
+				_ = r.PutWithTtl(ctx, k, i, time.Millisecond*time.Duration(i%5))
+				var v int
+				_ = r.Fetch(ctx, k, &v)
+				if i%7 == 0 {
+					_, _ = r.Delete(ctx, k)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if r.Len() > 50 {
+		t.Errorf("Len = %d; want <= 50", r.Len())
+	}
+}
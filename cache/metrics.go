@@ -0,0 +1,219 @@
+package cache
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// DefaultLatencyBuckets are the upper bounds, in seconds, of the latency histogram
+	DefaultLatencyBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1}
+
+	// DefaultSizeBuckets are the upper bounds, in bytes, of the payload size histogram
+	DefaultSizeBuckets = []float64{64, 256, 1024, 4096, 16384, 65536, 262144, 1048576}
+)
+
+// MemorySink is a MetricsSink that keeps all events in memory, for tests
+type MemorySink struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (s *MemorySink) Record(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = append(s.events, e)
+}
+
+// Events returns a copy of the recorded events
+func (s *MemorySink) Events() []Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Event(nil), s.events...)
+}
+
+// Count returns the number of recorded events for the op, namespace & outcome
+func (s *MemorySink) Count(op Op, namespace string, outcome Outcome) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := 0
+	for _, e := range s.events {
+		if e.Op == op && e.Namespace == namespace && e.Outcome == outcome {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset discards all recorded events
+func (s *MemorySink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.events = nil
+}
+
+// PrometheusSink is a MetricsSink that aggregates events into counters & histograms,
+// & serves them in the Prometheus text exposition format. The exported metrics are
+//
+//	gotham_cache_operations_total{op,namespace,outcome}  counter
+//	gotham_cache_duration_seconds{op,namespace}          histogram
+//	gotham_cache_payload_bytes{op,namespace}             histogram, measured payloads only
+type PrometheusSink struct {
+	mu         sync.Mutex
+	operations map[operationLabels]uint64
+	durations  map[histogramLabels]*histogram
+	sizes      map[histogramLabels]*histogram
+
+	latencyBuckets []float64
+	sizeBuckets    []float64
+}
+
+type operationLabels struct {
+	op        Op
+	namespace string
+	outcome   Outcome
+}
+
+type histogramLabels struct {
+	op        Op
+	namespace string
+}
+
+// histogram is a cumulative histogram, counts[i] is the number of observations
+// less than or equal to buckets[i]
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+func (h *histogram) observe(buckets []float64, v float64) {
+	for i, b := range buckets {
+		if v <= b {
+			h.counts[i]++
+		}
+	}
+	h.count++
+	h.sum += v
+}
+
+// NewPrometheusSink returns a PrometheusSink using the default buckets
+func NewPrometheusSink() *PrometheusSink {
+	return NewPrometheusSinkWithBuckets(DefaultLatencyBuckets, DefaultSizeBuckets)
+}
+
+// NewPrometheusSinkWithBuckets returns a PrometheusSink using the supplied, ascending,
+// latency (seconds) & size (bytes) bucket upper bounds
+func NewPrometheusSinkWithBuckets(latency []float64, size []float64) *PrometheusSink {
+	return &PrometheusSink{
+		operations:     make(map[operationLabels]uint64),
+		durations:      make(map[histogramLabels]*histogram),
+		sizes:          make(map[histogramLabels]*histogram),
+		latencyBuckets: latency,
+		sizeBuckets:    size,
+	}
+}
+
+func (s *PrometheusSink) Record(e Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.operations[operationLabels{e.Op, e.Namespace, e.Outcome}]++
+
+	hl := histogramLabels{e.Op, e.Namespace}
+	s.histogramFor(s.durations, hl, s.latencyBuckets).observe(s.latencyBuckets, e.Duration.Seconds())
+	if e.Bytes > 0 {
+		s.histogramFor(s.sizes, hl, s.sizeBuckets).observe(s.sizeBuckets, float64(e.Bytes))
+	}
+}
+
+// histogramFor returns the histogram for the labels, creating it if needed
+func (s *PrometheusSink) histogramFor(m map[histogramLabels]*histogram, l histogramLabels, buckets []float64) *histogram {
+	h, ok := m[l]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(buckets))}
+		m[l] = h
+	}
+	return h
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.Write(w)
+}
+
+// Write writes the metrics in the Prometheus text exposition format
+func (s *PrometheusSink) Write(w io.Writer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP gotham_cache_operations_total Cache operations by outcome.\n")
+	b.WriteString("# TYPE gotham_cache_operations_total counter\n")
+	ops := make([]operationLabels, 0, len(s.operations))
+	for l := range s.operations {
+		ops = append(ops, l)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if ops[i].op != ops[j].op {
+			return ops[i].op < ops[j].op
+		}
+		if ops[i].namespace != ops[j].namespace {
+			return ops[i].namespace < ops[j].namespace
+		}
+		return ops[i].outcome < ops[j].outcome
+	})
+	for _, l := range ops {
+		fmt.Fprintf(&b, "gotham_cache_operations_total{op=\"%v\",namespace=\"%v\",outcome=\"%v\"} %d\n",
+			l.op, escapeLabel(l.namespace), l.outcome, s.operations[l])
+	}
+
+	writeHistograms(&b, "gotham_cache_duration_seconds", "Cache operation latency in seconds.", s.durations, s.latencyBuckets)
+	writeHistograms(&b, "gotham_cache_payload_bytes", "Estimated cache payload size in bytes.", s.sizes, s.sizeBuckets)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeHistograms writes the histograms for a metric, sorted by labels
+func writeHistograms(b *strings.Builder, name string, help string, m map[histogramLabels]*histogram, buckets []float64) {
+	fmt.Fprintf(b, "# HELP %v %v\n", name, help)
+	fmt.Fprintf(b, "# TYPE %v histogram\n", name)
+
+	labels := make([]histogramLabels, 0, len(m))
+	for l := range m {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		if labels[i].op != labels[j].op {
+			return labels[i].op < labels[j].op
+		}
+		return labels[i].namespace < labels[j].namespace
+	})
+
+	for _, l := range labels {
+		h := m[l]
+		ls := fmt.Sprintf("op=\"%v\",namespace=\"%v\"", l.op, escapeLabel(l.namespace))
+		for i, bound := range buckets {
+			fmt.Fprintf(b, "%v_bucket{%v,le=\"%v\"} %d\n", name, ls, bound, h.counts[i])
+		}
+		fmt.Fprintf(b, "%v_bucket{%v,le=\"+Inf\"} %d\n", name, ls, h.count)
+		fmt.Fprintf(b, "%v_sum{%v} %v\n", name, ls, h.sum)
+		fmt.Fprintf(b, "%v_count{%v} %d\n", name, ls, h.count)
+	}
+}
+
+// labelEscaper escapes label values for the text exposition format
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel returns the escaped label value
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPrometheusSink_ServeHTTP(t *testing.T) {
+	s := NewPrometheusSinkWithBuckets([]float64{.01, .1}, []float64{100})
+	s.Record(Event{Op: OpFetch, Namespace: "principal", Outcome: OutcomeHit, Duration: 5 * time.Millisecond, Bytes: 50})
+	s.Record(Event{Op: OpFetch, Namespace: "principal", Outcome: OutcomeMiss, Duration: 50 * time.Millisecond})
+	s.Record(Event{Op: OpFetch, Namespace: "principal", Outcome: OutcomeHit, Duration: time.Second, Bytes: 500})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q; want text/plain; version=0.0.4", ct)
+	}
+	for _, want := range []string{
+		"# TYPE gotham_cache_operations_total counter\n",
+		`gotham_cache_operations_total{op="fetch",namespace="principal",outcome="hit"} 2`,
+		`gotham_cache_operations_total{op="fetch",namespace="principal",outcome="miss"} 1`,
+		"# TYPE gotham_cache_duration_seconds histogram\n",
+		`gotham_cache_duration_seconds_bucket{op="fetch",namespace="principal",le="0.01"} 1`,
+		`gotham_cache_duration_seconds_bucket{op="fetch",namespace="principal",le="0.1"} 2`,
+		`gotham_cache_duration_seconds_bucket{op="fetch",namespace="principal",le="+Inf"} 3`,
+		`gotham_cache_duration_seconds_count{op="fetch",namespace="principal"} 3`,
+		`gotham_cache_payload_bytes_bucket{op="fetch",namespace="principal",le="100"} 1`,
+		`gotham_cache_payload_bytes_count{op="fetch",namespace="principal"} 2`,
+		`gotham_cache_payload_bytes_sum{op="fetch",namespace="principal"} 550`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("exposition missing %q, got:\n%v", want, body)
+		}
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if got := escapeLabel("a\"b\\c\nd"); got != `a\"b\\c\nd` {
+		t.Errorf("escapeLabel = %q; want %q", got, `a\"b\\c\nd`)
+	}
+}
@@ -10,6 +10,16 @@ import (
 // NilCache a MemoryCache implementation for no-op cache
 type NilCache struct{}
 
+// Close is a no-op
+func (r *NilCache) Close() error {
+	return nil
+}
+
+// Ping returns nil, the nil cache is always healthy
+func (r *NilCache) Ping(ctx context.Context) error {
+	return nil
+}
+
 // method implementations
 
 func (r *NilCache) Put(ctx context.Context, key string, val any) error {
@@ -39,3 +49,54 @@ func (r *NilCache) FetchWithTtl(ctx context.Context, key string, val any) (*time
 func (r *NilCache) Delete(ctx context.Context, key string) (int64, error) {
 	return 0, nil
 }
+
+// bulk method implementations
+
+func (r *NilCache) FetchMany(ctx context.Context, keys []string, vals any) error {
+	_, err := mapTarget(vals)
+	return err
+}
+
+func (r *NilCache) PutMany(ctx context.Context, items []Item) error {
+	return nil
+}
+
+func (r *NilCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	return 0, nil
+}
+
+// atomic method implementations
+
+// Incr returns the delta, as if the counter was created
+func (r *NilCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return delta, nil
+}
+
+// Decr returns the negated delta, as if the counter was created
+func (r *NilCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return -delta, nil
+}
+
+// SetIfAbsent returns true, no key is ever present
+func (r *NilCache) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	return true, nil
+}
+
+// CompareAndSwap returns false, a missing key never equals old
+func (r *NilCache) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	return false, nil
+}
+
+// field method implementations
+
+func (r *NilCache) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	return nil
+}
+
+func (r *NilCache) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	return &CacheMissError{key, errors.Errorf("cache miss, nil cache in use")}
+}
+
+func (r *NilCache) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	return 0, nil
+}
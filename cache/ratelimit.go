@@ -0,0 +1,217 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+)
+
+// rateBucketSweepThreshold is the number of in-process buckets above which full
+// buckets are swept when a new bucket is created
+const rateBucketSweepThreshold = 4096
+
+// RateLimit is a token bucket limit: the bucket holds at most Burst tokens & is
+// refilled with Limit tokens every Period; each request takes one token
+type RateLimit struct {
+	Limit  int           `json:"limit"`
+	Period time.Duration `json:"period"`
+	Burst  int           `json:"burst"` // bucket capacity, defaults to Limit
+}
+
+// RateLimitResult is the outcome of a rate limited request
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int           // bucket capacity
+	Remaining  int           // whole tokens left in the bucket
+	RetryAfter time.Duration // time until the request would be allowed, 0 if allowed
+}
+
+// rateBackend implements the atomic token bucket for a cache
+type rateBackend interface {
+	take(ctx context.Context, key string, capacity float64, perMs float64, n int) (RateLimitResult, error)
+}
+
+// RateLimiter is a token bucket rate limiter built on a cache; with a RedisCache (or
+// TieredCache) the buckets are shared by all processes using the redis server, with
+// a RamCache they are local to the process
+type RateLimiter struct {
+	backend  rateBackend
+	name     string
+	capacity float64
+	perMs    float64 // tokens added per millisecond
+}
+
+// NewRateLimiter returns a RateLimiter enforcing the supplied limit on the cache; the
+// name namespaces the buckets, so limiters with different limits can share a cache.
+// Decorators are unwrapped (see Unwrap), so the buckets bypass them
+func NewRateLimiter(c MemoryCache, name string, limit RateLimit) (*RateLimiter, error) {
+	if limit.Limit <= 0 || limit.Period < time.Millisecond {
+		return nil, fmt.Errorf("rate limit must have a positive limit & a period of at least 1ms, got %v per %v", limit.Limit, limit.Period)
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Limit
+	}
+
+	l := &RateLimiter{
+		name:     name,
+		capacity: float64(limit.Burst),
+		perMs:    float64(limit.Limit) / (float64(limit.Period) / float64(time.Millisecond)),
+	}
+
+	switch c := Unwrap(c).(type) {
+	case *RedisCache:
+		l.backend = &redisBuckets{c}
+	case *TieredCache:
+		l.backend = &redisBuckets{c.l2}
+	case *RamCache:
+		l.backend = &c.buckets
+	default:
+		return nil, fmt.Errorf("cache %T does not support rate limiting", c)
+	}
+	return l, nil
+}
+
+// Allow takes a token from the bucket for key
+func (l *RateLimiter) Allow(ctx context.Context, key string) (RateLimitResult, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n tokens from the bucket for key; no tokens are taken if fewer than
+// n are left
+func (l *RateLimiter) AllowN(ctx context.Context, key string, n int) (RateLimitResult, error) {
+	return l.backend.take(ctx, l.name+KeySeparator+key, l.capacity, l.perMs, n)
+}
+
+// retryAfter returns the time needed to refill the missing tokens
+func retryAfter(missing float64, perMs float64) time.Duration {
+	return time.Duration(math.Ceil(missing/perMs)) * time.Millisecond
+}
+
+//
+// redis
+//
+
+func redisBucketKey(key string) string {
+	return "ratelimit" + KeySeparator + key
+}
+
+// redisTakeScript refills & takes tokens from a bucket stored as a hash of the token
+// count & last refill time; the redis server clock is used so all clients agree
+var redisTakeScript = redisv9.NewScript(`
+local capacity = tonumber(ARGV[1])
+local rate = tonumber(ARGV[2])
+local n = tonumber(ARGV[3])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(b[1]) or capacity
+local ts = tonumber(b[2]) or now
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+local allowed = 0
+if tokens >= n then
+	tokens = tokens - n
+	allowed = 1
+end
+tokens = string.format('%.6f', tokens)
+redis.call('HSET', KEYS[1], 'tokens', tokens, 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(capacity / rate))
+return {allowed, tokens}`)
+
+// redisBuckets implements token buckets with a lua script
+type redisBuckets struct {
+	cache *RedisCache
+}
+
+func (r *redisBuckets) take(ctx context.Context, key string, capacity float64, perMs float64, n int) (RateLimitResult, error) {
+	res, err := redisTakeScript.Run(ctx, r.cache.client, []string{redisBucketKey(key)},
+		capacity, strconv.FormatFloat(perMs, 'f', -1, 64), n).Slice()
+	if err != nil {
+		return RateLimitResult{}, err
+	}
+	if len(res) != 2 {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit script result %v", res)
+	}
+
+	allowed, _ := res[0].(int64)
+	s, _ := res[1].(string)
+	tokens, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("unexpected rate limit token count %v: %w", res[1], err)
+	}
+	return bucketResult(allowed == 1, tokens, capacity, perMs, n), nil
+}
+
+// bucketResult returns the result for a bucket with the supplied tokens left
+func bucketResult(allowed bool, tokens float64, capacity float64, perMs float64, n int) RateLimitResult {
+	r := RateLimitResult{
+		Allowed:   allowed,
+		Limit:     int(capacity),
+		Remaining: int(math.Floor(tokens)),
+	}
+	if !allowed {
+		r.RetryAfter = retryAfter(float64(n)-tokens, perMs)
+	}
+	return r
+}
+
+//
+// ram
+//
+
+// ramBuckets implements in-process token buckets for a RamCache; buckets are kept
+// apart from the cached entries so they are never evicted
+type ramBuckets struct {
+	mu      sync.Mutex
+	buckets map[string]*ramBucket
+}
+
+type ramBucket struct {
+	tokens   float64
+	refilled time.Time
+	full     time.Time // time the bucket is refilled completely
+}
+
+func (r *ramBuckets) take(ctx context.Context, key string, capacity float64, perMs float64, n int) (RateLimitResult, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok {
+		if r.buckets == nil {
+			r.buckets = make(map[string]*ramBucket)
+		}
+		if len(r.buckets) >= rateBucketSweepThreshold {
+			r.sweep(now)
+		}
+		b = &ramBucket{tokens: capacity, refilled: now}
+		r.buckets[key] = b
+	}
+
+	elapsed := float64(now.Sub(b.refilled)) / float64(time.Millisecond)
+	b.tokens = math.Min(capacity, b.tokens+math.Max(0, elapsed)*perMs)
+	b.refilled = now
+
+	allowed := b.tokens >= float64(n)
+	if allowed {
+		b.tokens -= float64(n)
+	}
+	b.full = now.Add(retryAfter(capacity-b.tokens, perMs))
+	return bucketResult(allowed, b.tokens, capacity, perMs, n), nil
+}
+
+// sweep removes the buckets that have refilled completely, they are equivalent to a
+// new bucket; the caller must hold the lock
+func (r *ramBuckets) sweep(now time.Time) {
+	for key, b := range r.buckets {
+		if !now.Before(b.full) {
+			delete(r.buckets, key)
+		}
+	}
+}
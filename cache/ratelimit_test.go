@@ -0,0 +1,98 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testRateLimiters returns a RateLimiter with the supplied limit for each supported backend.
+func testRateLimiters(t *testing.T, limit RateLimit) map[string]*RateLimiter {
+	t.Helper()
+	r, _ := newTestRedisCache(t)
+
+	limiters := map[string]*RateLimiter{}
+	for name, c := range map[string]MemoryCache{"ram": newTestRamCache(t), "redis": r} {
+		l, err := NewRateLimiter(c, "test", limit)
+		if err != nil {
+			t.Fatalf("NewRateLimiter(%v) returned unexpected error: %v", name, err)
+		}
+		limiters[name] = l
+	}
+	return limiters
+}
+
+func TestRateLimiter_Burst(t *testing.T) {
+	for name, l := range testRateLimiters(t, RateLimit{Limit: 3, Period: time.Minute}) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			for i := 0; i < 3; i++ {
+				res, err := l.Allow(ctx, "alias")
+				if err != nil {
+					t.Fatalf("Allow returned unexpected error: %v", err)
+				}
+				if !res.Allowed || res.Remaining != 2-i {
+					t.Errorf("Allow #%d = %+v; want allowed with %d remaining", i, res, 2-i)
+				}
+			}
+
+			res, err := l.Allow(ctx, "alias")
+			if err != nil {
+				t.Fatalf("Allow returned unexpected error: %v", err)
+			}
+			if res.Allowed {
+				t.Error("Allow over the limit = allowed; want rejected")
+			}
+			if res.RetryAfter <= 0 || res.RetryAfter > 20*time.Second {
+				t.Errorf("RetryAfter = %v; want (0, 20s]", res.RetryAfter)
+			}
+
+			// other keys have their own bucket
+			if res, _ := l.Allow(ctx, "other"); !res.Allowed {
+				t.Error("Allow for another key = rejected; want allowed")
+			}
+		})
+	}
+}
+
+func TestRateLimiter_Refill(t *testing.T) {
+	l, _ := NewRateLimiter(newTestRamCache(t), "test", RateLimit{Limit: 1, Period: 20 * time.Millisecond})
+	ctx := context.Background()
+
+	if res, _ := l.Allow(ctx, "k"); !res.Allowed {
+		t.Fatal("first Allow = rejected; want allowed")
+	}
+	if res, _ := l.Allow(ctx, "k"); res.Allowed {
+		t.Fatal("second Allow = allowed; want rejected")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if res, _ := l.Allow(ctx, "k"); !res.Allowed {
+		t.Error("Allow after refill = rejected; want allowed")
+	}
+}
+
+func TestNewRateLimiter_Invalid(t *testing.T) {
+	if _, err := NewRateLimiter(newTestRamCache(t), "test", RateLimit{Limit: 1}); err == nil {
+		t.Error("NewRateLimiter without period returned nil error; want error")
+	}
+	if _, err := NewRateLimiter(&NilCache{}, "test", RateLimit{Limit: 1, Period: time.Second}); err == nil {
+		t.Error("NewRateLimiter(NilCache) returned nil error; want error")
+	}
+}
+
+func TestNewRateLimiter_Decorated(t *testing.T) {
+	r, _ := newTestRedisCache(t)
+	resilient, err := NewResilient(NewInstrumented(r, InstrumentOptions{}), ResilienceConfig{})
+	if err != nil {
+		t.Fatalf("NewResilient returned unexpected error: %v", err)
+	}
+	t.Cleanup(resilient.closeLocal)
+
+	l, err := NewRateLimiter(resilient, "test", RateLimit{Limit: 1, Period: time.Second})
+	if err != nil {
+		t.Fatalf("NewRateLimiter returned unexpected error: %v", err)
+	}
+	if _, ok := l.backend.(*redisBuckets); !ok {
+		t.Errorf("backend = %T; want *redisBuckets", l.backend)
+	}
+}
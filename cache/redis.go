@@ -4,27 +4,145 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	redisv9 "github.com/redis/go-redis/v9"
 )
 
+type RedisMode string
+
+const (
+	RedisSingle   RedisMode = "single"   // a single redis node
+	RedisSentinel RedisMode = "sentinel" // a master/replica set monitored by redis sentinel
+	RedisCluster  RedisMode = "cluster"  // a redis cluster
+)
+
 // RedisCache is a MemoryCache implementation for Redis database
 type RedisCache struct {
-	client   *redisv9.Client
-	host     string
-	port     int
-	password string
-	db       int
+	client redisv9.UniversalClient
+	config RedisConfig // config with defaults applied
+	serde  Serde       // serde used to encode values other than []byte & string
+}
+
+// newRedisCache returns a RedisCache for the supplied config with defaults applied,
+// the cache must be connected before use
+func newRedisCache(cfg *RedisConfig) (*RedisCache, error) {
+	// ultimate defaults
+	port := 6379
+	c := RedisConfig{
+		Host:       "localhost",
+		Port:       &port,
+		Mode:       RedisSingle,
+		Serializer: SerdeGob,
+	}
+
+	// now check config
+	if cfg != nil {
+		c = *cfg
+		if c.Host == "" {
+			c.Host = "localhost"
+		}
+		if c.Port == nil {
+			c.Port = &port
+		}
+		if c.Mode == "" {
+			c.Mode = RedisSingle
+		}
+	}
+
+	switch c.Mode {
+	case RedisSingle:
+	case RedisSentinel:
+		if c.MasterName == "" || len(c.SentinelAddrs) == 0 {
+			return nil, fmt.Errorf("redis sentinel mode requires a master name & sentinel addresses")
+		}
+	case RedisCluster:
+		if len(c.ClusterAddrs) == 0 {
+			return nil, fmt.Errorf("redis cluster mode requires cluster seed addresses")
+		}
+		if c.Db != 0 {
+			return nil, fmt.Errorf("redis cluster mode only supports db 0")
+		}
+	default:
+		return nil, fmt.Errorf("redis mode %v not supported", c.Mode)
+	}
+
+	serde, err := NewSerde(c.Serializer)
+	if err != nil {
+		return nil, err
+	}
+
+	switch c.Compression {
+	case "", CompressionNone:
+	case CompressionGzip, CompressionZstd:
+		if c.CompressThreshold <= 0 {
+			c.CompressThreshold = DefaultCompressThreshold
+		}
+	default:
+		return nil, fmt.Errorf("redis compression %v not supported", c.Compression)
+	}
+
+	return &RedisCache{config: c, serde: serde}, nil
 }
 
 // connect to redis, or return error
 func (r *RedisCache) connect() error {
-	r.client = redisv9.NewClient(&redisv9.Options{
-		Addr:     fmt.Sprintf("%v:%v", r.host, r.port), // host:port
-		Password: r.password,                           // no password set
-		DB:       r.db,                                 // use default DB, 0
-	})
+	c := r.config
+
+	tlsConfig, err := c.TlsConfig.load()
+	if err != nil {
+		return err
+	}
+
+	switch c.Mode {
+	case RedisSentinel:
+		r.client = redisv9.NewFailoverClient(&redisv9.FailoverOptions{
+			MasterName:       c.MasterName,
+			SentinelAddrs:    c.SentinelAddrs,
+			SentinelPassword: c.SentinelPassword,
+			Username:         c.Username,
+			Password:         c.Password,
+			DB:               c.Db,
+			TLSConfig:        tlsConfig,
+			PoolSize:         c.PoolSize,
+			MinIdleConns:     c.MinIdleConns,
+			DialTimeout:      c.DialTimeout,
+			ReadTimeout:      c.ReadTimeout,
+			WriteTimeout:     c.WriteTimeout,
+			MaxRetries:       c.MaxRetries,
+		})
+	case RedisCluster:
+		r.client = redisv9.NewClusterClient(&redisv9.ClusterOptions{
+			Addrs:        c.ClusterAddrs,
+			Username:     c.Username,
+			Password:     c.Password,
+			TLSConfig:    tlsConfig,
+			PoolSize:     c.PoolSize,
+			MinIdleConns: c.MinIdleConns,
+			DialTimeout:  c.DialTimeout,
+			ReadTimeout:  c.ReadTimeout,
+			WriteTimeout: c.WriteTimeout,
+			MaxRetries:   c.MaxRetries,
+		})
+	default:
+		r.client = redisv9.NewClient(&redisv9.Options{
+			Addr:         fmt.Sprintf("%v:%v", c.Host, *c.Port), // host:port
+			Username:     c.Username,                            // acl user, if any
+			Password:     c.Password,                            // no password set
+			DB:           c.Db,                                  // use default DB, 0
+			TLSConfig:    tlsConfig,
+			PoolSize:     c.PoolSize,
+			MinIdleConns: c.MinIdleConns,
+			DialTimeout:  c.DialTimeout,
+			ReadTimeout:  c.ReadTimeout,
+			WriteTimeout: c.WriteTimeout,
+			MaxRetries:   c.MaxRetries,
+		})
+	}
 
 	if _, err := r.client.Ping(context.Background()).Result(); err != nil {
 		return err
@@ -32,8 +150,76 @@ func (r *RedisCache) connect() error {
 	return nil
 }
 
+// Close closes the connections to redis
+func (r *RedisCache) Close() error {
+	if r.client == nil {
+		return nil
+	}
+	return r.client.Close()
+}
+
+// Ping checks that redis is reachable
+func (r *RedisCache) Ping(ctx context.Context) error {
+	if r.client == nil {
+		return errors.New("redis cache is not connected")
+	}
+	return r.client.Ping(ctx).Err()
+}
+
 func (r RedisCache) internalSingletonKey() string {
-	return fmt.Sprintf("%v:%v/%v", r.host, r.port, r.db)
+	c := r.config
+	switch c.Mode {
+	case RedisSentinel:
+		return fmt.Sprintf("sentinel:%v%v@%v/%v?serde=%v", c.userPrefix(), c.MasterName, strings.Join(c.SentinelAddrs, ","), c.Db, r.serde.Kind()) + c.authOptions() + c.valueOptions()
+	case RedisCluster:
+		return fmt.Sprintf("cluster:%v%v?serde=%v", c.userPrefix(), strings.Join(c.ClusterAddrs, ","), r.serde.Kind()) + c.authOptions() + c.valueOptions()
+	default:
+		return fmt.Sprintf("%v%v:%v/%v?serde=%v", c.userPrefix(), c.Host, *c.Port, c.Db, r.serde.Kind()) + c.authOptions() + c.valueOptions()
+	}
+}
+
+// encode converts the value to the bytes stored in redis, []byte & string values are
+// stored as-is, everything else is encoded with the configured serde & compressed
+func (r *RedisCache) encode(val any) ([]byte, error) {
+	data, err := encodeRaw(r.serde, val)
+	if err != nil {
+		return nil, err
+	}
+	return CompressValue(data, r.config.Compression, r.config.CompressThreshold)
+}
+
+// encodeFor encodes the value to store for key, or returns a *ValueTooLargeError if
+// the encoded value exceeds the maximum value size
+func (r *RedisCache) encodeFor(key string, val any) ([]byte, error) {
+	bytes, err := r.encode(val)
+	if err != nil {
+		return nil, err
+	}
+	if r.config.MaxValueSize > 0 && len(bytes) > r.config.MaxValueSize {
+		return nil, &ValueTooLargeError{Key: key, Size: len(bytes), Max: r.config.MaxValueSize}
+	}
+	return bytes, nil
+}
+
+// decode converts the bytes stored in redis into val (a pointer)
+func (r *RedisCache) decode(bytes []byte, val any) error {
+	switch rval := val.(type) {
+	case *int64:
+		// counters written by Incr are stored as decimal strings without a header
+		if _, ok := DetectSerde(bytes); !ok {
+			n, err := strconv.ParseInt(string(bytes), 10, 64)
+			if err == nil {
+				*rval = n
+				return nil
+			}
+		}
+		if err := DecodeValue(bytes, val); err != nil {
+			return &ValueError{err}
+		}
+		return nil
+	default:
+		return decodeRaw(bytes, val)
+	}
 }
 
 // method implementations
@@ -43,18 +229,9 @@ func (r *RedisCache) Put(ctx context.Context, key string, val any) error {
 }
 
 func (r *RedisCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
-	var bytes []byte
-	switch rval := val.(type) {
-	case []byte:
-		bytes = rval //val.([]byte)
-	case string:
-		bytes = []byte(rval)
-	default:
-		var err error
-		g := GobSerde{}
-		if bytes, err = g.ser(val); err != nil {
-			return err
-		}
+	bytes, err := r.encodeFor(key, val)
+	if err != nil {
+		return err
 	}
 
 	cmd := r.client.Set(ctx, key, bytes, expiry)
@@ -72,22 +249,7 @@ func (r *RedisCache) Fetch(ctx context.Context, key string, val any) error {
 		}
 		return err
 	} else {
-		switch rval := val.(type) {
-		// case []byte: // todo remove
-		// 	val = bytes
-		// 	return nil
-		case *[]byte:
-			*rval = bytes
-			return nil
-		// case string: // todo remove
-		// 	val = string(bytes)
-		// 	return nil
-		case *string:
-			*rval = string(bytes)
-			return nil
-		default:
-			return GobSerde{}.de(bytes, val) //TODO to be improved into a more generic SerDe impl
-		}
+		return r.decode(bytes, val)
 	}
 }
 
@@ -116,3 +278,280 @@ func (r *RedisCache) Delete(ctx context.Context, key string) (int64, error) {
 		return n, nil
 	}
 }
+
+// scanBatchSize is the number of keys requested per SCAN call by DeleteByPrefix
+const scanBatchSize = 500
+
+// bulk method implementations
+
+// FetchMany fetches the values with a single MGET, or a pipeline of GETs in cluster
+// mode where the keys may live on different nodes
+func (r *RedisCache) FetchMany(ctx context.Context, keys []string, vals any) error {
+	m, err := mapTarget(vals)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	raw := make([]*string, len(keys))
+	if r.config.Mode == RedisCluster {
+		cmds := make([]*redisv9.StringCmd, len(keys))
+		_, err := r.client.Pipelined(ctx, func(p redisv9.Pipeliner) error {
+			for i, key := range keys {
+				cmds[i] = p.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redisv9.Nil) {
+			return err
+		}
+		for i, cmd := range cmds {
+			if s, err := cmd.Result(); err == nil {
+				raw[i] = &s
+			}
+		}
+	} else {
+		res, err := r.client.MGet(ctx, keys...).Result()
+		if err != nil {
+			return err
+		}
+		for i, v := range res {
+			if s, ok := v.(string); ok {
+				raw[i] = &s
+			}
+		}
+	}
+
+	elemType := m.Type().Elem()
+	for i, s := range raw {
+		if s == nil {
+			continue
+		}
+		ptr := reflect.New(elemType)
+		if err := r.decode([]byte(*s), ptr.Interface()); err != nil {
+			return err
+		}
+		if err := setMapValue(m, keys[i], ptr.Elem().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PutMany stores the items with a single pipeline of SETs
+func (r *RedisCache) PutMany(ctx context.Context, items []Item) error {
+	data := make([][]byte, len(items))
+	for i, item := range items {
+		var err error
+		if data[i], err = r.encodeFor(item.Key, item.Val); err != nil {
+			return err
+		}
+	}
+
+	_, err := r.client.Pipelined(ctx, func(p redisv9.Pipeliner) error {
+		for i, item := range items {
+			p.Set(ctx, item.Key, data[i], item.Ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+// DeleteByPrefix deletes the keys found with SCAN in batches, on every master node
+// in cluster mode. Keys written while the scan is running may not be deleted
+func (r *RedisCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	match := globEscape(prefix) + "*"
+
+	if c, ok := r.client.(*redisv9.ClusterClient); ok {
+		var mu sync.Mutex
+		var total int64
+		err := c.ForEachMaster(ctx, func(ctx context.Context, node *redisv9.Client) error {
+			n, err := deleteByScan(ctx, node, match)
+			mu.Lock()
+			total += n
+			mu.Unlock()
+			return err
+		})
+		return total, err
+	}
+	return deleteByScan(ctx, r.client, match)
+}
+
+// deleteByScan deletes the keys matching the pattern on a single node; keys are deleted
+// one per command in a pipeline, as keys on a cluster node may be in different slots
+func deleteByScan(ctx context.Context, c redisv9.Cmdable, match string) (int64, error) {
+	var total int64
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(ctx, cursor, match, scanBatchSize).Result()
+		if err != nil {
+			return total, err
+		}
+		if len(keys) > 0 {
+			cmds, err := c.Pipelined(ctx, func(p redisv9.Pipeliner) error {
+				for _, key := range keys {
+					p.Del(ctx, key)
+				}
+				return nil
+			})
+			for _, cmd := range cmds {
+				if del, ok := cmd.(*redisv9.IntCmd); ok {
+					total += del.Val()
+				}
+			}
+			if err != nil {
+				return total, err
+			}
+		}
+		if next == 0 {
+			return total, nil
+		}
+		cursor = next
+	}
+}
+
+// atomic method implementations
+
+var (
+	// redisIncrScript increments the counter, setting the TTL only when it is created
+	redisIncrScript = redisv9.NewScript(`
+local created = redis.call('EXISTS', KEYS[1]) == 0
+local n = redis.call('INCRBY', KEYS[1], ARGV[1])
+if created and tonumber(ARGV[2]) > 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return n`)
+
+	// redisCasScript replaces the value if the current value matches one of the
+	// encodings of the expected value
+	redisCasScript = redisv9.NewScript(`
+local cur = redis.call('GET', KEYS[1])
+if not cur then
+	return 0
+end
+for i = 3, #ARGV do
+	if cur == ARGV[i] then
+		if tonumber(ARGV[2]) > 0 then
+			redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+		else
+			redis.call('SET', KEYS[1], ARGV[1])
+		end
+		return 1
+	end
+end
+return 0`)
+)
+
+func (r *RedisCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return redisIncrScript.Run(ctx, r.client, []string{key}, delta, ttl.Milliseconds()).Int64()
+}
+
+func (r *RedisCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return r.Incr(ctx, key, -delta, ttl)
+}
+
+func (r *RedisCache) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	bytes, err := r.encodeFor(key, val)
+	if err != nil {
+		return false, err
+	}
+	return r.client.SetNX(ctx, key, bytes, ttl).Result()
+}
+
+// CompareAndSwap compares the encoded values; an int64 old value also matches a
+// counter written by Incr
+func (r *RedisCache) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	newBytes, err := r.encodeFor(key, new)
+	if err != nil {
+		return false, err
+	}
+	oldBytes, err := r.encode(old)
+	if err != nil {
+		return false, err
+	}
+
+	args := []any{newBytes, ttl.Milliseconds(), oldBytes}
+	if n, ok := old.(int64); ok {
+		args = append(args, strconv.FormatInt(n, 10))
+	}
+	n, err := redisCasScript.Run(ctx, r.client, []string{key}, args...).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// field method implementations
+
+// PutFields stores the fields in a redis hash with HSET, & sets the TTL in the same
+// transaction
+func (r *RedisCache) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	values, err := fieldValues(val, fields)
+	if err != nil {
+		return err
+	}
+
+	hash := make(map[string]any, len(values))
+	for name, v := range values {
+		bytes, err := r.encodeFor(key, v)
+		if err != nil {
+			return fmt.Errorf("error encoding field %v: %w", name, err)
+		}
+		hash[name] = bytes
+	}
+
+	_, err = r.client.TxPipelined(ctx, func(p redisv9.Pipeliner) error {
+		p.HSet(ctx, key, hash)
+		if ttl > 0 {
+			p.PExpire(ctx, key, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisCache) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	targets, err := fieldTargets(val, fields)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(targets))
+	for name := range targets {
+		names = append(names, name)
+	}
+
+	var exists *redisv9.IntCmd
+	var hmget *redisv9.SliceCmd
+	_, err = r.client.Pipelined(ctx, func(p redisv9.Pipeliner) error {
+		exists = p.Exists(ctx, key)
+		hmget = p.HMGet(ctx, key, names...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if exists.Val() == 0 {
+		return &CacheMissError{key, redisv9.Nil}
+	}
+
+	for i, v := range hmget.Val() {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if err := r.decode([]byte(s), targets[names[i]]); err != nil {
+			return fmt.Errorf("error decoding field %v: %w", names[i], err)
+		}
+	}
+	return nil
+}
+
+func (r *RedisCache) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	if len(fields) == 0 {
+		return 0, nil
+	}
+	return r.client.HDel(ctx, key, fields...).Result()
+}
@@ -0,0 +1,180 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRedisCache returns a RedisCache connected to an in-process miniredis server.
+func newTestRedisCache(t *testing.T) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+	return newTestRedisCacheWithConfig(t, RedisConfig{})
+}
+
+// newTestRedisCacheWithConfig returns a connected RedisCache for the supplied config,
+// backed by miniredis.
+func newTestRedisCacheWithConfig(t *testing.T, cfg RedisConfig) (*RedisCache, *miniredis.Miniredis) {
+	t.Helper()
+
+	m := miniredis.RunT(t)
+	port, _ := strconv.Atoi(m.Port())
+	cfg.Host, cfg.Port = m.Host(), &port
+	r, err := newRedisCache(&cfg)
+	if err != nil {
+		t.Fatalf("newRedisCache returned unexpected error: %v", err)
+	}
+	if err := r.connect(); err != nil {
+		t.Fatalf("connect returned unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = r.client.Close() })
+	return r, m
+}
+
+func TestRedisCache_PutFetch(t *testing.T) {
+	r, _ := newTestRedisCache(t)
+	ctx := context.Background()
+
+	want := serdeTestValue{Name: "n", Count: 2}
+	if err := r.PutWithTtl(ctx, "k", want, time.Minute); err != nil {
+		t.Fatalf("PutWithTtl returned unexpected error: %v", err)
+	}
+
+	var got serdeTestValue
+	ttl, err := r.FetchWithTtl(ctx, "k", &got)
+	if err != nil {
+		t.Fatalf("FetchWithTtl returned unexpected error: %v", err)
+	}
+	if got.Name != want.Name || got.Count != want.Count {
+		t.Errorf("FetchWithTtl = %+v; want %+v", got, want)
+	}
+	if *ttl <= 0 || *ttl > time.Minute {
+		t.Errorf("FetchWithTtl ttl = %v; want (0, 1m]", *ttl)
+	}
+
+	if _, err := r.FetchWithTtl(ctx, "missing", &got); !IsCacheMiss(err) {
+		t.Errorf("FetchWithTtl(missing) error = %v; want cache miss", err)
+	}
+}
+
+func TestNewRedisCache_Defaults(t *testing.T) {
+	r, err := newRedisCache(nil)
+	if err != nil {
+		t.Fatalf("newRedisCache returned unexpected error: %v", err)
+	}
+
+	if r.config.Host != "localhost" || *r.config.Port != 6379 || r.config.Mode != RedisSingle {
+		t.Errorf("config = %v:%v (%v); want localhost:6379 (single)", r.config.Host, *r.config.Port, r.config.Mode)
+	}
+	if r.serde.Kind() != SerdeGob {
+		t.Errorf("serde = %v; want %v", r.serde.Kind(), SerdeGob)
+	}
+}
+
+func TestNewRedisCache_Validation(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  RedisConfig
+	}{
+		{"sentinel without master", RedisConfig{Mode: RedisSentinel, SentinelAddrs: []string{"s:26379"}}},
+		{"sentinel without addrs", RedisConfig{Mode: RedisSentinel, MasterName: "mymaster"}},
+		{"cluster without addrs", RedisConfig{Mode: RedisCluster}},
+		{"cluster with db", RedisConfig{Mode: RedisCluster, ClusterAddrs: []string{"n:6379"}, Db: 1}},
+		{"unknown mode", RedisConfig{Mode: "ring"}},
+		{"unknown serializer", RedisConfig{Serializer: "xml"}},
+		{"unknown compression", RedisConfig{Compression: "lz4"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newRedisCache(&tt.cfg); err == nil {
+				t.Error("newRedisCache returned nil error; want error")
+			}
+		})
+	}
+}
+
+func TestRedisCache_InternalSingletonKey(t *testing.T) {
+	single, _ := newRedisCache(&RedisConfig{Host: "h", Db: 2})
+	sentinel, _ := newRedisCache(&RedisConfig{Mode: RedisSentinel, MasterName: "m", SentinelAddrs: []string{"s1:26379", "s2:26379"}})
+	cluster, _ := newRedisCache(&RedisConfig{Mode: RedisCluster, ClusterAddrs: []string{"n1:6379"}})
+
+	if got := single.internalSingletonKey(); got != "h:6379/2?serde=gob" {
+		t.Errorf("single key = %q; want %q", got, "h:6379/2?serde=gob")
+	}
+	if got := sentinel.internalSingletonKey(); !strings.HasPrefix(got, "sentinel:m@s1:26379,s2:26379") {
+		t.Errorf("sentinel key = %q; want sentinel:m@s1:26379,s2:26379 prefix", got)
+	}
+	if got := cluster.internalSingletonKey(); !strings.HasPrefix(got, "cluster:n1:6379") {
+		t.Errorf("cluster key = %q; want cluster:n1:6379 prefix", got)
+	}
+}
+
+func TestRedisCache_InternalSingletonKeyAuth(t *testing.T) {
+	keyOf := func(cfg RedisConfig) string {
+		cfg.Host = "h"
+		c, err := newRedisCache(&cfg)
+		if err != nil {
+			t.Fatalf("newRedisCache returned unexpected error: %v", err)
+		}
+		return c.internalSingletonKey()
+	}
+
+	alice := keyOf(RedisConfig{Username: "app", Password: "alice-secret"})
+	bob := keyOf(RedisConfig{Username: "app", Password: "bob-secret"})
+	tls := keyOf(RedisConfig{Username: "app", Password: "alice-secret", TlsConfig: &RedisTlsConfig{Enabled: true}})
+
+	if alice == bob || alice == tls {
+		t.Errorf("keys = %q, %q, %q; want distinct keys", alice, bob, tls)
+	}
+	if strings.Contains(alice, "alice-secret") {
+		t.Errorf("key = %q; want the password hashed", alice)
+	}
+	if alice != keyOf(RedisConfig{Username: "app", Password: "alice-secret"}) {
+		t.Error("key of the same config is not stable")
+	}
+}
+
+func TestRedisCache_Compression(t *testing.T) {
+	r, m := newTestRedisCacheWithConfig(t, RedisConfig{Compression: CompressionGzip, CompressThreshold: 256})
+	ctx := context.Background()
+
+	want := serdeTestValue{Name: strings.Repeat("n", 4096)}
+	if err := r.Put(ctx, "k", want); err != nil {
+		t.Fatalf("Put returned unexpected error: %v", err)
+	}
+	raw, _ := m.Get("k")
+	if len(raw) >= 4096 || raw[3]&compressionMask == 0 {
+		t.Errorf("stored %v bytes, flags %x; want a compressed value", len(raw), raw[3])
+	}
+
+	var got serdeTestValue
+	if err := r.Fetch(ctx, "k", &got); err != nil || got.Name != want.Name {
+		t.Errorf("Fetch = (%v bytes, %v); want the value", len(got.Name), err)
+	}
+}
+
+func TestRedisCache_MaxValueSize(t *testing.T) {
+	r, m := newTestRedisCacheWithConfig(t, RedisConfig{MaxValueSize: 64})
+	ctx := context.Background()
+
+	err := r.Put(ctx, "k", strings.Repeat("x", 65))
+	if !IsValueTooLarge(err) {
+		t.Fatalf("Put error = %v; want value too large", err)
+	}
+	var tooLarge *ValueTooLargeError
+	if errors.As(err, &tooLarge); tooLarge.Key != "k" || tooLarge.Size != 65 || tooLarge.Max != 64 {
+		t.Errorf("ValueTooLargeError = %+v; want k, 65, 64", tooLarge)
+	}
+	if m.Exists("k") {
+		t.Errorf("key k exists; want the value not written")
+	}
+	if err := r.Put(ctx, "k", strings.Repeat("x", 64)); err != nil {
+		t.Errorf("Put returned unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,368 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+// Registry owns the cache instances initialized from a Config. Instances are shared:
+// each kind is created once (redis once per connection) & returned for subsequent
+// calls until the registry is closed. A Registry is safe for concurrent use; the lock
+// is not held while connecting, so lookups of existing instances never wait on the
+// network
+type Registry struct {
+	mu       sync.Mutex
+	nilCache *NilCache
+	ram      *RamCache
+	redis    map[string]*RedisCache  // keyed by RedisCache.internalSingletonKey
+	tiered   map[string]*TieredCache // keyed by tieredKey
+	file     map[string]*FileCache   // keyed by the resolved directory
+
+	resilient map[resilientKey]*Resilient // keyed by the wrapped instance & config
+
+	connects singleflight.Group // connections in progress, keyed by instance
+}
+
+// NewRegistry returns an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{
+		redis:     make(map[string]*RedisCache),
+		tiered:    make(map[string]*TieredCache),
+		file:      make(map[string]*FileCache),
+		resilient: make(map[resilientKey]*Resilient),
+	}
+}
+
+// resilientKey identifies a Resilient decorator, the wrapped instance & its config
+// after defaults are applied
+type resilientKey struct {
+	cache  MemoryCache
+	config ResilienceConfig
+}
+
+// Initialize returns the MemoryCache configured in app settings, see InitializeWithConfig
+func (g *Registry) Initialize() (MemoryCache, error) {
+	return g.InitializeWithConfig(nil)
+}
+
+// InitializeWithConfig returns the MemoryCache for the supplied config, creating it if
+// the registry has no instance yet. If nil config is supplied, the configuration is
+// read from app settings; see the package level InitializeWithConfig for the keys
+func (g *Registry) InitializeWithConfig(cfg *Config) (MemoryCache, error) {
+	config := cfg
+	if config == nil {
+		c := loadCacheConfigFromAppSettings()
+		config = &c
+	}
+
+	c, err := g.cacheFor(config)
+	if err != nil {
+		return nil, err
+	}
+	if rc := config.ResilienceConfig; rc != nil && rc.Enabled {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		r, err := g.resilientFor(c, *rc)
+		if err != nil {
+			return nil, err
+		}
+		return r, nil
+	}
+	return c, nil
+}
+
+// cacheFor returns the MemoryCache for the supplied config, the caller must not hold
+// the lock
+func (g *Registry) cacheFor(config *Config) (MemoryCache, error) {
+	log.Debugf("cache kind is %v", config.Kind)
+	switch config.Kind {
+
+	//
+	// nil
+	//
+	case Nil:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.nilCache == nil {
+			g.nilCache = new(NilCache)
+		}
+		return g.nilCache, nil
+
+	//
+	// internal memory (RAM)
+	//
+	case InternalMemory:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if g.ram == nil {
+			ramConfig := RamConfig{}
+			if config.RamConfig != nil {
+				ramConfig = *config.RamConfig
+			}
+			r, err := NewRamCache(ramConfig)
+			if err != nil {
+				return nil, err
+			}
+			g.ram = r
+		}
+		return g.ram, nil
+
+	//
+	// redis
+	//
+	case Redis:
+		return g.redisCacheFor(config.RedisConfig)
+
+	//
+	// tiered, ram L1 & redis L2
+	//
+	case Tiered:
+		return g.tieredCacheFor(config)
+
+	//
+	// file
+	//
+	case File:
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		fileConfig := FileConfig{}
+		if config.FileConfig != nil {
+			fileConfig = *config.FileConfig
+		}
+		dir, err := fileConfig.resolveDir()
+		if err != nil {
+			return nil, err
+		}
+		if f, ok := g.file[dir]; ok {
+			return f, nil
+		}
+		fileConfig.Dir = dir
+		f, err := NewFileCache(fileConfig)
+		if err != nil {
+			return nil, err
+		}
+		g.file[dir] = f
+		return f, nil
+
+	// default
+	default:
+		return nil, fmt.Errorf("cache type %v not supported", config.Kind)
+
+	}
+}
+
+// redisCacheFor returns the connected RedisCache for the supplied config, the caller
+// must not hold the lock. Concurrent callers for the same connection share one connect
+func (g *Registry) redisCacheFor(cfg *RedisConfig) (*RedisCache, error) {
+	c, err := newRedisCache(cfg)
+	if err != nil {
+		return nil, err
+	}
+	key := c.internalSingletonKey()
+
+	// if an instance exists for the connection, then return it
+	if c, ok := g.lookupRedis(key); ok {
+		return c, nil
+	}
+
+	v, err, _ := g.connects.Do("redis:"+key, func() (any, error) {
+		// an earlier connect may have completed since the lookup
+		if c, ok := g.lookupRedis(key); ok {
+			return c, nil
+		}
+
+		log.Debugf("initializing redis cache %v", key)
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.redis[key] = c
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*RedisCache), nil
+}
+
+// lookupRedis returns the RedisCache for the singleton key, if any
+func (g *Registry) lookupRedis(key string) (*RedisCache, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	c, ok := g.redis[key]
+	return c, ok
+}
+
+// tieredCacheFor returns the TieredCache for the supplied config, the caller must not
+// hold the lock. Instances are shared per L2 connection & L1/tiered config
+func (g *Registry) tieredCacheFor(config *Config) (*TieredCache, error) {
+	l2, err := g.redisCacheFor(config.RedisConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	ramConfig := RamConfig{}
+	if config.RamConfig != nil {
+		ramConfig = *config.RamConfig
+	}
+	if ramConfig.MaxEntries <= 0 && ramConfig.MaxBytes <= 0 {
+		ramConfig.MaxEntries = DefaultL1MaxEntries // l1 is always bounded
+	}
+	tieredConfig := TieredConfig{}
+	if config.TieredConfig != nil {
+		tieredConfig = *config.TieredConfig
+	}
+	key := tieredKey(l2, ramConfig, tieredConfig)
+
+	if t, ok := g.lookupTiered(key); ok {
+		return t, nil
+	}
+
+	v, err, _ := g.connects.Do("tiered:"+key, func() (any, error) {
+		// an earlier connect may have completed since the lookup
+		if t, ok := g.lookupTiered(key); ok {
+			return t, nil
+		}
+
+		l1, err := NewRamCache(ramConfig)
+		if err != nil {
+			return nil, err
+		}
+		t, err := NewTieredCache(l1, l2, tieredConfig)
+		if err != nil {
+			l1.Stop()
+			return nil, err
+		}
+
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		g.tiered[key] = t
+		return t, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*TieredCache), nil
+}
+
+// lookupTiered returns the TieredCache for the key, if any
+func (g *Registry) lookupTiered(key string) (*TieredCache, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	t, ok := g.tiered[key]
+	return t, ok
+}
+
+// tieredKey returns the key of a TieredCache, the L2 singleton key with the L1 & tiered
+// config after defaults are applied; a custom eviction policy is keyed by identity
+func tieredKey(l2 *RedisCache, ram RamConfig, tiered TieredConfig) string {
+	var janitor time.Duration
+	if ram.JanitorInterval != nil {
+		janitor = *ram.JanitorInterval
+	}
+	if tiered.L1Ttl <= 0 {
+		tiered.L1Ttl = DefaultL1Ttl
+	}
+	if tiered.Channel == "" {
+		tiered.Channel = DefaultInvalidationChannel
+	}
+
+	key := fmt.Sprintf("%v|l1:janitor=%v&max-entries=%v&max-bytes=%v&eviction=%v|l1-ttl=%v&invalidation=%v&channel=%v",
+		l2.internalSingletonKey(), janitor, ram.MaxEntries, ram.MaxBytes, ram.Eviction, tiered.L1Ttl, tiered.Invalidation, tiered.Channel)
+	if ram.Policy != nil {
+		key += fmt.Sprintf("&policy=%T@%p", ram.Policy, ram.Policy)
+	}
+	return key
+}
+
+// resilientFor returns the Resilient decorator of the supplied cache for the config,
+// the caller must hold the lock
+func (g *Registry) resilientFor(c MemoryCache, cfg ResilienceConfig) (*Resilient, error) {
+	key := resilientKey{c, cfg.withDefaults()}
+	if r, ok := g.resilient[key]; ok {
+		return r, nil
+	}
+
+	r, err := NewResilient(c, cfg)
+	if err != nil {
+		return nil, err
+	}
+	g.resilient[key] = r
+	return r, nil
+}
+
+// Close closes all caches owned by the registry & forgets them, so the next
+// Initialize creates new instances. All close errors are returned
+func (g *Registry) Close() error {
+	// take the instances, so the lock is not held during network calls
+	g.mu.Lock()
+	ram, redis, tiered, files, resilient := g.ram, g.redis, g.tiered, g.file, g.resilient
+	g.nilCache = nil
+	g.ram = nil
+	g.tiered = make(map[string]*TieredCache)
+	g.redis = make(map[string]*RedisCache)
+	g.file = make(map[string]*FileCache)
+	g.resilient = make(map[resilientKey]*Resilient)
+	g.mu.Unlock()
+
+	var errs []error
+	for _, r := range resilient {
+		r.closeLocal() // the wrapped caches are closed below
+	}
+	for _, t := range tiered {
+		errs = append(errs, t.Close())
+	}
+	if ram != nil {
+		errs = append(errs, ram.Close())
+	}
+	for key, c := range redis {
+		if err := c.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("error closing redis cache %v: %w", key, err))
+		}
+	}
+	for _, f := range files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Ping checks the health of all caches owned by the registry, returning the errors
+// of the caches that are unhealthy
+func (g *Registry) Ping(ctx context.Context) error {
+	// snapshot the instances, so the lock is not held during network calls
+	g.mu.Lock()
+	ram := g.ram
+	redis := make(map[string]*RedisCache, len(g.redis))
+	for key, c := range g.redis {
+		redis[key] = c
+	}
+	files := make([]*FileCache, 0, len(g.file))
+	for _, f := range g.file {
+		files = append(files, f)
+	}
+	g.mu.Unlock()
+
+	var errs []error
+	if ram != nil {
+		errs = append(errs, ram.Ping(ctx))
+	}
+	for key, c := range redis {
+		if err := c.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("redis cache %v is unhealthy: %w", key, err))
+		}
+	}
+	for _, f := range files {
+		if err := f.Ping(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("file cache %v is unhealthy: %w", f.Dir(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
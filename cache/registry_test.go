@@ -0,0 +1,183 @@
+package cache
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestRegistry returns a Registry that is closed at test cleanup.
+func newTestRegistry(t *testing.T) *Registry {
+	g := NewRegistry()
+	t.Cleanup(func() { _ = g.Close() })
+	return g
+}
+
+// testRedisConfig returns a RedisConfig for the supplied miniredis server.
+func testRedisConfig(m *miniredis.Miniredis) *RedisConfig {
+	port, _ := strconv.Atoi(m.Port())
+	return &RedisConfig{Host: m.Host(), Port: &port}
+}
+
+func TestRegistry_InitializeWithConfig_Shared(t *testing.T) {
+	g := newTestRegistry(t)
+	m := miniredis.RunT(t)
+
+	for _, cfg := range []*Config{
+		{Kind: Nil},
+		{Kind: InternalMemory},
+		{Kind: Redis, RedisConfig: testRedisConfig(m)},
+	} {
+		a, err := g.InitializeWithConfig(cfg)
+		if err != nil {
+			t.Fatalf("InitializeWithConfig(%v) returned unexpected error: %v", cfg.Kind, err)
+		}
+		b, _ := g.InitializeWithConfig(cfg)
+		if a != b {
+			t.Errorf("InitializeWithConfig(%v) returned different instances", cfg.Kind)
+		}
+	}
+}
+
+func TestRegistry_InitializeWithConfig_Tiered(t *testing.T) {
+	g := newTestRegistry(t)
+	m1 := miniredis.RunT(t)
+	m2 := miniredis.RunT(t)
+
+	initialize := func(m *miniredis.Miniredis, ram *RamConfig) MemoryCache {
+		c, err := g.InitializeWithConfig(&Config{Kind: Tiered, RedisConfig: testRedisConfig(m), RamConfig: ram})
+		if err != nil {
+			t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+		}
+		return c
+	}
+
+	a := initialize(m1, nil)
+	if b := initialize(m1, &RamConfig{MaxEntries: DefaultL1MaxEntries}); a != b {
+		t.Error("InitializeWithConfig returned a new instance for the default config; want the shared instance")
+	}
+	if b := initialize(m2, nil); a == b {
+		t.Error("InitializeWithConfig returned the shared instance for another redis; want a new instance")
+	}
+	if b := initialize(m1, &RamConfig{MaxEntries: 10}); a == b {
+		t.Error("InitializeWithConfig returned the shared instance for another l1 config; want a new instance")
+	}
+}
+
+func TestRegistry_InitializeWithConfig_Unsupported(t *testing.T) {
+	g := newTestRegistry(t)
+	if _, err := g.InitializeWithConfig(&Config{Kind: "memcached"}); err == nil {
+		t.Fatal("InitializeWithConfig with unknown kind returned nil error; want error")
+	}
+}
+
+func TestRegistry_InitializeWithConfig_Concurrent(t *testing.T) {
+	g := newTestRegistry(t)
+
+	var wg sync.WaitGroup
+	caches := make([]MemoryCache, 16)
+	for i := range caches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			caches[i], _ = g.InitializeWithConfig(&Config{Kind: InternalMemory})
+		}(i)
+	}
+	wg.Wait()
+
+	for _, c := range caches[1:] {
+		if c != caches[0] {
+			t.Fatal("concurrent InitializeWithConfig returned different instances")
+		}
+	}
+}
+
+func TestRegistry_InitializeWithConfig_ConnectUnlocked(t *testing.T) {
+	g := newTestRegistry(t)
+
+	// a server that accepts connections but never replies
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen returned unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { _ = conn.Close() })
+		}
+	}()
+
+	port := l.Addr().(*net.TCPAddr).Port
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = g.InitializeWithConfig(&Config{Kind: Redis, RedisConfig: &RedisConfig{Host: "127.0.0.1", Port: &port, ReadTimeout: time.Second, MaxRetries: -1}})
+	}()
+	time.Sleep(50 * time.Millisecond) // the connect is waiting on the server
+
+	start := time.Now()
+	if _, err := g.InitializeWithConfig(&Config{Kind: InternalMemory}); err != nil {
+		t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("InitializeWithConfig took %v during a redis connect; want no wait", elapsed)
+	}
+	<-done
+}
+
+func TestRegistry_Close(t *testing.T) {
+	g := newTestRegistry(t)
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+	cfg := &Config{Kind: Redis, RedisConfig: testRedisConfig(m)}
+
+	before, _ := g.InitializeWithConfig(cfg)
+	ram, _ := g.InitializeWithConfig(&Config{Kind: InternalMemory})
+	if err := g.Close(); err != nil {
+		t.Fatalf("Close returned unexpected error: %v", err)
+	}
+
+	if err := before.(*RedisCache).Ping(ctx); err == nil {
+		t.Error("Ping on closed redis cache returned nil error; want error")
+	}
+	select {
+	case <-ram.(*RamCache).stop:
+	default:
+		t.Error("ram cache janitor not stopped by Close")
+	}
+
+	after, err := g.InitializeWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("InitializeWithConfig after Close returned unexpected error: %v", err)
+	}
+	if after == before {
+		t.Error("InitializeWithConfig after Close returned the closed instance")
+	}
+}
+
+func TestRegistry_Ping(t *testing.T) {
+	g := newTestRegistry(t)
+	m := miniredis.RunT(t)
+	ctx := context.Background()
+
+	if _, err := g.InitializeWithConfig(&Config{Kind: Tiered, RedisConfig: testRedisConfig(m)}); err != nil {
+		t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+	}
+	if err := g.Ping(ctx); err != nil {
+		t.Errorf("Ping returned unexpected error: %v", err)
+	}
+
+	m.Close()
+	if err := g.Ping(ctx); err == nil {
+		t.Error("Ping with redis down returned nil error; want error")
+	}
+}
@@ -0,0 +1,566 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultFailureThreshold = 5                // consecutive backend errors that open the breaker
+	DefaultOpenTimeout      = 30 * time.Second // time the breaker stays open before a trial call
+)
+
+// ErrBreakerOpen is returned by Resilient deletes while the circuit breaker is open
+var ErrBreakerOpen = errors.New("cache circuit breaker is open")
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // calls go to the backend
+	BreakerOpen     BreakerState = "open"      // calls go to the fallback
+	BreakerHalfOpen BreakerState = "half-open" // a trial call goes to the backend
+)
+
+// Resilient is a MemoryCache decorator that keeps a cache usable while its backend
+// (e.g. redis) is unreachable. Backend errors, other than cache misses & cancelled
+// contexts, are counted by a circuit breaker; once it opens, calls go to the fallback
+// cache until a trial call succeeds. Errors caused by the caller, such as values that
+// can't be encoded or are too large, are returned as-is & not counted. When a fetch fails, a copy of the value read or
+// written within its TTL plus the stale grace is served instead.
+//
+// Puts & fetches fail open: a put that fails is written to the fallback & a fetch that
+// fails is served from the stale copies or the fallback. Deletes clear the local copies
+// but return the backend error (ErrBreakerOpen while open), as the shared value remains.
+//
+// The BulkCache, AtomicCache & FieldCache operations are forwarded through the breaker
+// if the wrapped cache implements them. PutMany & FetchMany fail open like Put & Fetch;
+// atomic & field operations act on shared state, so like deletes they clear the local
+// copies of the key they may change & return the backend error
+type Resilient struct {
+	cache    MemoryCache
+	fallback MemoryCache
+	stale    *RamCache // nil if the stale grace is disabled
+	grace    time.Duration
+	breaker  breaker
+}
+
+// NewResilient returns a Resilient cache wrapping the supplied cache
+func NewResilient(c MemoryCache, cfg ResilienceConfig) (*Resilient, error) {
+	cfg = cfg.withDefaults()
+
+	r := &Resilient{
+		cache:   c,
+		grace:   cfg.StaleGrace,
+		breaker: breaker{state: BreakerClosed, threshold: cfg.FailureThreshold, timeout: cfg.OpenTimeout},
+	}
+
+	switch cfg.Fallback {
+	case Nil:
+		r.fallback = new(NilCache)
+	case InternalMemory:
+		ram, err := NewRamCache(RamConfig{MaxEntries: cfg.StaleMaxEntries})
+		if err != nil {
+			return nil, err
+		}
+		r.fallback = ram
+	default:
+		return nil, fmt.Errorf("fallback cache type %v not supported", cfg.Fallback)
+	}
+
+	if cfg.StaleGrace > 0 {
+		stale, err := NewRamCache(RamConfig{MaxEntries: cfg.StaleMaxEntries})
+		if err != nil {
+			r.closeLocal()
+			return nil, err
+		}
+		r.stale = stale
+	}
+	return r, nil
+}
+
+// withDefaults returns the config with the defaults applied to unset fields
+func (cfg ResilienceConfig) withDefaults() ResilienceConfig {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultFailureThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultOpenTimeout
+	}
+	if cfg.StaleMaxEntries <= 0 {
+		cfg.StaleMaxEntries = DefaultL1MaxEntries
+	}
+	if cfg.Fallback == "" {
+		cfg.Fallback = Nil
+	}
+	return cfg
+}
+
+// State returns the circuit breaker state
+func (r *Resilient) State() BreakerState {
+	return r.breaker.current()
+}
+
+// Unwrap returns the wrapped cache
+func (r *Resilient) Unwrap() MemoryCache {
+	return r.cache
+}
+
+// Close closes the stale copies, the fallback & the wrapped cache, if it implements
+// Lifecycle
+func (r *Resilient) Close() error {
+	r.closeLocal()
+	if l, ok := r.cache.(Lifecycle); ok {
+		return l.Close()
+	}
+	return nil
+}
+
+// closeLocal stops the stale copies & the fallback, leaving the wrapped cache open
+func (r *Resilient) closeLocal() {
+	if r.stale != nil {
+		r.stale.Stop()
+	}
+	if ram, ok := r.fallback.(*RamCache); ok {
+		ram.Stop()
+	}
+}
+
+// Ping checks the health of the wrapped cache, if it implements Lifecycle
+func (r *Resilient) Ping(ctx context.Context) error {
+	if l, ok := r.cache.(Lifecycle); ok {
+		return l.Ping(ctx)
+	}
+	return nil
+}
+
+// call runs fn against the backend if the breaker allows it & records the result;
+// false if the backend was not called or failed. Caller errors are returned with true,
+// as there is nothing to fall back to
+func (r *Resilient) call(ctx context.Context, op Op, key string, fn func() error) (bool, error) {
+	if !r.breaker.allow() {
+		return false, ErrBreakerOpen
+	}
+
+	err := fn()
+	if err == nil || IsCacheMiss(err) {
+		r.breaker.success()
+		return true, err
+	}
+	if isCallerError(err) {
+		// the value or the call is invalid, which says nothing about the backend
+		r.breaker.abort()
+		return true, err
+	}
+	if errors.Is(ctx.Err(), context.Canceled) {
+		// the caller gave up, which says nothing about the backend
+		r.breaker.abort()
+		return false, err
+	}
+
+	r.breaker.failure()
+	log.Warnf("error on cache %v of key %v, breaker is %v: %v", op, key, r.breaker.current(), err)
+	return false, err
+}
+
+// isCallerError returns true if the error is caused by the caller rather than the
+// backend, e.g. a value that can't be encoded
+func isCallerError(err error) bool {
+	return IsValueError(err) || IsValueTooLarge(err) || IsUnsupported(err)
+}
+
+// keepStale keeps a copy of the value to serve if the backend fails; the copy lives
+// for the remaining TTL plus the grace, or the grace if the TTL is unknown
+func (r *Resilient) keepStale(ctx context.Context, key string, val any, ttl time.Duration) {
+	if r.stale == nil {
+		return
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := r.stale.PutWithTtl(ctx, key, val, ttl+r.grace); err != nil {
+		log.Debugf("error keeping stale copy of key %v: %v", key, err)
+	}
+}
+
+// fetchStale fetches the stale copy of the value, & its TTL less the grace
+func (r *Resilient) fetchStale(ctx context.Context, key string, val any) (*time.Duration, bool) {
+	if r.stale == nil {
+		return nil, false
+	}
+	ttl, err := r.stale.FetchWithTtl(ctx, key, val)
+	if err != nil {
+		return nil, false
+	}
+
+	log.Debugf("serving stale copy of key %v", key)
+	remaining := max(*ttl-r.grace, 0)
+	return &remaining, true
+}
+
+// method implementations
+
+func (r *Resilient) Put(ctx context.Context, key string, val any) error {
+	return r.PutWithTtl(ctx, key, val, NoExpiry)
+}
+
+func (r *Resilient) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	ok, err := r.call(ctx, OpPut, key, func() error {
+		return r.cache.PutWithTtl(ctx, key, val, expiry)
+	})
+	if ok && err != nil {
+		return err
+	}
+	r.keepStale(ctx, key, val, expiry)
+	if ok {
+		return nil
+	}
+	return r.fallback.PutWithTtl(ctx, key, val, expiry)
+}
+
+func (r *Resilient) Fetch(ctx context.Context, key string, val any) error {
+	_, err := r.FetchWithTtl(ctx, key, val)
+	return err
+}
+
+func (r *Resilient) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	var ttl *time.Duration
+	ok, err := r.call(ctx, OpFetch, key, func() error {
+		var err error
+		ttl, err = r.cache.FetchWithTtl(ctx, key, val)
+		return err
+	})
+	switch {
+	case ok && err == nil:
+		if ptr := reflect.ValueOf(val); ptr.Kind() == reflect.Pointer && !ptr.IsNil() && ttl != nil {
+			r.keepStale(ctx, key, ptr.Elem().Interface(), *ttl)
+		}
+		return ttl, nil
+	case ok:
+		// a miss from a healthy backend is authoritative
+		if r.stale != nil && IsCacheMiss(err) {
+			_, _ = r.stale.Delete(ctx, key)
+		}
+		return nil, err
+	}
+
+	if ttl, ok := r.fetchStale(ctx, key, val); ok {
+		return ttl, nil
+	}
+	return r.fallback.FetchWithTtl(ctx, key, val)
+}
+
+func (r *Resilient) Delete(ctx context.Context, key string) (int64, error) {
+	r.clearLocal(ctx, key)
+
+	var n int64
+	ok, err := r.call(ctx, OpDelete, key, func() error {
+		var err error
+		n, err = r.cache.Delete(ctx, key)
+		return err
+	})
+	if !ok {
+		return 0, err
+	}
+	return n, err
+}
+
+// clearLocal deletes the stale copy & the fallback entry of key
+func (r *Resilient) clearLocal(ctx context.Context, key string) {
+	if r.stale != nil {
+		_, _ = r.stale.Delete(ctx, key)
+	}
+	_, _ = r.fallback.Delete(ctx, key)
+}
+
+// bulk method implementations
+
+func (r *Resilient) FetchMany(ctx context.Context, keys []string, vals any) error {
+	b, err := bulkOf(r.cache, "FetchMany")
+	if err != nil {
+		return err
+	}
+	m, err := mapTarget(vals)
+	if err != nil {
+		return err
+	}
+
+	ok, err := r.call(ctx, OpFetchMany, firstKey(keys), func() error {
+		return b.FetchMany(ctx, keys, vals)
+	})
+	if ok {
+		for _, key := range keys {
+			if v := m.MapIndex(reflect.ValueOf(key).Convert(m.Type().Key())); v.IsValid() {
+				r.keepStale(ctx, key, v.Interface(), 0)
+			}
+		}
+		return err
+	}
+
+	// serve the stale copies, & the fallback for the keys without one
+	var rest []string
+	for _, key := range keys {
+		ptr := reflect.New(m.Type().Elem())
+		if _, ok := r.fetchStale(ctx, key, ptr.Interface()); ok {
+			m.SetMapIndex(reflect.ValueOf(key).Convert(m.Type().Key()), ptr.Elem())
+			continue
+		}
+		rest = append(rest, key)
+	}
+	if len(rest) == 0 {
+		return nil
+	}
+	fallback, err := bulkOf(r.fallback, "FetchMany")
+	if err != nil {
+		return err
+	}
+	return fallback.FetchMany(ctx, rest, vals)
+}
+
+func (r *Resilient) PutMany(ctx context.Context, items []Item) error {
+	b, err := bulkOf(r.cache, "PutMany")
+	if err != nil {
+		return err
+	}
+
+	var key string
+	if len(items) > 0 {
+		key = items[0].Key
+	}
+	ok, err := r.call(ctx, OpPutMany, key, func() error {
+		return b.PutMany(ctx, items)
+	})
+	if ok && err != nil {
+		return err
+	}
+	for _, item := range items {
+		r.keepStale(ctx, item.Key, item.Val, item.Ttl)
+	}
+	if ok {
+		return nil
+	}
+	fallback, err := bulkOf(r.fallback, "PutMany")
+	if err != nil {
+		return err
+	}
+	return fallback.PutMany(ctx, items)
+}
+
+func (r *Resilient) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	b, err := bulkOf(r.cache, "DeleteByPrefix")
+	if err != nil {
+		return 0, err
+	}
+	if r.stale != nil {
+		_, _ = r.stale.DeleteByPrefix(ctx, prefix)
+	}
+	if fallback, err := bulkOf(r.fallback, "DeleteByPrefix"); err == nil {
+		_, _ = fallback.DeleteByPrefix(ctx, prefix)
+	}
+
+	var n int64
+	ok, err := r.call(ctx, OpDeleteByPrefix, prefix, func() error {
+		var err error
+		n, err = b.DeleteByPrefix(ctx, prefix)
+		return err
+	})
+	if !ok {
+		return 0, err
+	}
+	return n, err
+}
+
+// atomic method implementations
+
+func (r *Resilient) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return r.incr(ctx, OpIncr, key, delta, ttl)
+}
+
+func (r *Resilient) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return r.incr(ctx, OpDecr, key, -delta, ttl)
+}
+
+// incr adds the delta to the counter, recording the call as op
+func (r *Resilient) incr(ctx context.Context, op Op, key string, delta int64, ttl time.Duration) (int64, error) {
+	a, err := atomicOf(r.cache, "Incr")
+	if err != nil {
+		return 0, err
+	}
+	r.clearLocal(ctx, key)
+
+	var n int64
+	ok, err := r.call(ctx, op, key, func() error {
+		var err error
+		n, err = a.Incr(ctx, key, delta, ttl)
+		return err
+	})
+	if !ok {
+		return 0, err
+	}
+	return n, err
+}
+
+func (r *Resilient) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(r.cache, "SetIfAbsent")
+	if err != nil {
+		return false, err
+	}
+	var stored bool
+	ok, err := r.call(ctx, OpSetIfAbsent, key, func() error {
+		var err error
+		stored, err = a.SetIfAbsent(ctx, key, val, ttl)
+		return err
+	})
+	if !ok || stored {
+		// the value changed, or may have
+		r.clearLocal(ctx, key)
+	}
+	if !ok {
+		return false, err
+	}
+	return stored, err
+}
+
+func (r *Resilient) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	a, err := atomicOf(r.cache, "CompareAndSwap")
+	if err != nil {
+		return false, err
+	}
+	var swapped bool
+	ok, err := r.call(ctx, OpCompareAndSwap, key, func() error {
+		var err error
+		swapped, err = a.CompareAndSwap(ctx, key, old, new, ttl)
+		return err
+	})
+	if !ok || swapped {
+		// the value changed, or may have
+		r.clearLocal(ctx, key)
+	}
+	if !ok {
+		return false, err
+	}
+	return swapped, err
+}
+
+// field method implementations
+
+func (r *Resilient) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	f, err := fieldsOf(r.cache, "PutFields")
+	if err != nil {
+		return err
+	}
+	r.clearLocal(ctx, key)
+
+	_, err = r.call(ctx, OpPutFields, key, func() error {
+		return f.PutFields(ctx, key, val, ttl, fields...)
+	})
+	return err
+}
+
+func (r *Resilient) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	f, err := fieldsOf(r.cache, "FetchFields")
+	if err != nil {
+		return err
+	}
+	_, err = r.call(ctx, OpFetchFields, key, func() error {
+		return f.FetchFields(ctx, key, val, fields...)
+	})
+	return err
+}
+
+func (r *Resilient) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	f, err := fieldsOf(r.cache, "DeleteFields")
+	if err != nil {
+		return 0, err
+	}
+	r.clearLocal(ctx, key)
+
+	var n int64
+	ok, err := r.call(ctx, OpDeleteFields, key, func() error {
+		var err error
+		n, err = f.DeleteFields(ctx, key, fields...)
+		return err
+	})
+	if !ok {
+		return 0, err
+	}
+	return n, err
+}
+
+// breaker is a consecutive-failure circuit breaker
+type breaker struct {
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	timeout   time.Duration
+}
+
+func (b *breaker) current() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// allow returns true if a call may go to the backend; once the open timeout passes,
+// a single trial call is allowed
+func (b *breaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.timeout {
+			return false
+		}
+		b.transition(BreakerHalfOpen)
+		return true
+	default:
+		return false // a trial call is in flight
+	}
+}
+
+func (b *breaker) success() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures = 0
+	if b.state != BreakerClosed {
+		b.transition(BreakerClosed)
+	}
+}
+
+func (b *breaker) failure() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.failures++
+	if b.state == BreakerHalfOpen || (b.state == BreakerClosed && b.failures >= b.threshold) {
+		b.openedAt = time.Now()
+		b.transition(BreakerOpen)
+	}
+}
+
+// abort ends a call without a result, a trial call is allowed again
+func (b *breaker) abort() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		b.transition(BreakerOpen)
+	}
+}
+
+// transition changes the state; the caller must hold the lock
+func (b *breaker) transition(state BreakerState) {
+	log.Warnf("cache circuit breaker is %v, was %v", state, b.state)
+	b.state = state
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// newTestResilient returns a Resilient cache wrapping a miniredis backed RedisCache.
+func newTestResilient(t *testing.T, cfg ResilienceConfig) (*Resilient, *miniredis.Miniredis) {
+	t.Helper()
+	c, m := newTestRedisCache(t)
+	r, err := NewResilient(c, cfg)
+	if err != nil {
+		t.Fatalf("NewResilient returned unexpected error: %v", err)
+	}
+	t.Cleanup(r.closeLocal)
+	return r, m
+}
+
+func TestResilient_ServesStale(t *testing.T) {
+	r, m := newTestResilient(t, ResilienceConfig{StaleGrace: time.Minute})
+	ctx := context.Background()
+
+	_ = r.PutWithTtl(ctx, "k", "v", time.Minute)
+	var got string
+	if err := r.Fetch(ctx, "k", &got); err != nil {
+		t.Fatalf("Fetch returned unexpected error: %v", err)
+	}
+
+	m.SetError("LOADING redis is loading the dataset in memory")
+	got = ""
+	if err := r.Fetch(ctx, "k", &got); err != nil || got != "v" {
+		t.Errorf("Fetch = (%v, %v); want stale v", got, err)
+	}
+	if err := r.Fetch(ctx, "unknown", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch(unknown) error = %v; want cache miss", err)
+	}
+}
+
+func TestResilient_MissClearsStale(t *testing.T) {
+	r, m := newTestResilient(t, ResilienceConfig{StaleGrace: time.Minute})
+	ctx := context.Background()
+
+	_ = r.Put(ctx, "k", "v")
+	m.Del("k")
+	var got string
+	if err := r.Fetch(ctx, "k", &got); !IsCacheMiss(err) {
+		t.Fatalf("Fetch error = %v; want cache miss", err)
+	}
+
+	m.SetError("LOADING redis is loading the dataset in memory")
+	if err := r.Fetch(ctx, "k", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch error = %v; want cache miss", err)
+	}
+}
+
+func TestResilient_Breaker(t *testing.T) {
+	r, m := newTestResilient(t, ResilienceConfig{FailureThreshold: 2, OpenTimeout: 50 * time.Millisecond, Fallback: InternalMemory})
+	ctx := context.Background()
+
+	m.SetError("LOADING redis is loading the dataset in memory")
+	var got string
+	for i := 0; i < 2; i++ {
+		_ = r.Fetch(ctx, "k", &got)
+	}
+	if s := r.State(); s != BreakerOpen {
+		t.Fatalf("State = %v; want %v", s, BreakerOpen)
+	}
+
+	// while open, puts & fetches use the fallback & deletes report the open breaker
+	if err := r.Put(ctx, "k", "fallback"); err != nil {
+		t.Errorf("Put returned unexpected error: %v", err)
+	}
+	if err := r.Fetch(ctx, "k", &got); err != nil || got != "fallback" {
+		t.Errorf("Fetch = (%v, %v); want fallback", got, err)
+	}
+	if _, err := r.Delete(ctx, "k"); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("Delete error = %v; want %v", err, ErrBreakerOpen)
+	}
+
+	m.SetError("")
+	time.Sleep(60 * time.Millisecond)
+	_ = m.Set("k", "redis")
+	if err := r.Fetch(ctx, "k", &got); err != nil || got != "redis" {
+		t.Errorf("Fetch = (%v, %v); want redis", got, err)
+	}
+	if s := r.State(); s != BreakerClosed {
+		t.Errorf("State = %v; want %v", s, BreakerClosed)
+	}
+}
+
+func TestResilient_CallerErrors(t *testing.T) {
+	c, _ := newTestRedisCacheWithConfig(t, RedisConfig{MaxValueSize: 64})
+	r, err := NewResilient(c, ResilienceConfig{FailureThreshold: 1, Fallback: InternalMemory})
+	if err != nil {
+		t.Fatalf("NewResilient returned unexpected error: %v", err)
+	}
+	t.Cleanup(r.closeLocal)
+	ctx := context.Background()
+
+	if err := r.Put(ctx, "large", strings.Repeat("v", 65)); !IsValueTooLarge(err) {
+		t.Errorf("Put(large) error = %v; want value too large", err)
+	}
+	if err := r.Put(ctx, "func", func() {}); !IsValueError(err) {
+		t.Errorf("Put(func) error = %v; want value error", err)
+	}
+	if err := r.PutMany(ctx, []Item{{Key: "large", Val: strings.Repeat("v", 65)}}); !IsValueTooLarge(err) {
+		t.Errorf("PutMany(large) error = %v; want value too large", err)
+	}
+	if s := r.State(); s != BreakerClosed {
+		t.Errorf("State = %v; want %v", s, BreakerClosed)
+	}
+
+	var got string
+	if err := r.fallback.Fetch(ctx, "large", &got); !IsCacheMiss(err) {
+		t.Errorf("fallback Fetch(large) error = %v; want cache miss", err)
+	}
+}
+
+func TestResilient_FailedTrialReopens(t *testing.T) {
+	r, m := newTestResilient(t, ResilienceConfig{FailureThreshold: 1, OpenTimeout: 10 * time.Millisecond})
+	ctx := context.Background()
+
+	m.SetError("LOADING redis is loading the dataset in memory")
+	var got string
+	_ = r.Fetch(ctx, "k", &got)
+	time.Sleep(20 * time.Millisecond)
+	_ = r.Fetch(ctx, "k", &got)
+	if s := r.State(); s != BreakerOpen {
+		t.Errorf("State = %v; want %v", s, BreakerOpen)
+	}
+}
+
+func TestResilient_Extensions(t *testing.T) {
+	r, m := newTestResilient(t, ResilienceConfig{StaleGrace: time.Minute, FailureThreshold: 2})
+	ctx := context.Background()
+
+	if err := r.PutMany(ctx, []Item{{Key: "a", Val: "1"}, {Key: "b", Val: "2"}}); err != nil {
+		t.Fatalf("PutMany returned unexpected error: %v", err)
+	}
+	if n, err := r.Incr(ctx, "n", 3, NoExpiry); err != nil || n != 3 {
+		t.Errorf("Incr = (%d, %v); want (3, nil)", n, err)
+	}
+	if ok, err := r.SetIfAbsent(ctx, "a", "3", NoExpiry); err != nil || ok {
+		t.Errorf("SetIfAbsent = (%v, %v); want (false, nil)", ok, err)
+	}
+
+	m.SetError("LOADING redis is loading the dataset in memory")
+	got := make(map[string]string)
+	if err := r.FetchMany(ctx, []string{"a", "b"}, got); err != nil || got["a"] != "1" || got["b"] != "2" {
+		t.Errorf("FetchMany = (%v, %v); want the stale copies", got, err)
+	}
+	if _, err := r.Incr(ctx, "n", 1, NoExpiry); err == nil {
+		t.Error("Incr returned nil error with a failing backend; want error")
+	}
+	if r.State() != BreakerOpen {
+		t.Errorf("State = %v; want %v", r.State(), BreakerOpen)
+	}
+	if _, err := r.CompareAndSwap(ctx, "a", "1", "2", NoExpiry); !errors.Is(err, ErrBreakerOpen) {
+		t.Errorf("CompareAndSwap error = %v; want %v", err, ErrBreakerOpen)
+	}
+}
+
+func TestRegistry_InitializeWithConfig_Resilient(t *testing.T) {
+	g := newTestRegistry(t)
+	m := miniredis.RunT(t)
+	cfg := &Config{Kind: Redis, RedisConfig: testRedisConfig(m), ResilienceConfig: &ResilienceConfig{Enabled: true}}
+
+	c1, err := g.InitializeWithConfig(cfg)
+	if err != nil {
+		t.Fatalf("InitializeWithConfig returned unexpected error: %v", err)
+	}
+	if _, ok := c1.(*Resilient); !ok {
+		t.Fatalf("InitializeWithConfig = %T; want *Resilient", c1)
+	}
+	c2, _ := g.InitializeWithConfig(cfg)
+	if c1 != c2 {
+		t.Errorf("InitializeWithConfig returned a new instance; want the shared instance")
+	}
+
+	defaults := &Config{Kind: Redis, RedisConfig: testRedisConfig(m), ResilienceConfig: &ResilienceConfig{Enabled: true, FailureThreshold: DefaultFailureThreshold, Fallback: Nil}}
+	if c3, _ := g.InitializeWithConfig(defaults); c3 != c1 {
+		t.Errorf("InitializeWithConfig(explicit defaults) returned a new instance; want the shared instance")
+	}
+	other := &Config{Kind: Redis, RedisConfig: testRedisConfig(m), ResilienceConfig: &ResilienceConfig{Enabled: true, FailureThreshold: 1}}
+	c4, err := g.InitializeWithConfig(other)
+	if err != nil {
+		t.Fatalf("InitializeWithConfig(other) returned unexpected error: %v", err)
+	}
+	if c4 == c1 {
+		t.Error("InitializeWithConfig(other resilience config) returned the shared instance; want a new instance")
+	}
+	if c4.(*Resilient).Unwrap() != c1.(*Resilient).Unwrap() {
+		t.Error("InitializeWithConfig(other resilience config) wrapped a new backend; want the shared backend")
+	}
+}
@@ -2,12 +2,58 @@ package cache
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
 )
 
+type SerdeKind string
+
+const (
+	SerdeGob     SerdeKind = "gob"
+	SerdeJson    SerdeKind = "json"
+	SerdeMsgpack SerdeKind = "msgpack"
+)
+
+// Serde serializes values to bytes & deserializes bytes back to values
+type Serde interface {
+	// the kind of this serde, recorded in the header of each encoded value
+	Kind() SerdeKind
+
+	// serialize the supplied value
+	Ser(any) ([]byte, error)
+
+	// deserialize the data into the supplied pointer
+	De([]byte, any) error
+}
+
+// NewSerde returns the Serde for the supplied kind, gob is used if no kind is supplied
+func NewSerde(kind SerdeKind) (Serde, error) {
+	switch kind {
+	case SerdeGob, "":
+		return GobSerde{}, nil
+	case SerdeJson:
+		return JsonSerde{}, nil
+	case SerdeMsgpack:
+		return MsgpackSerde{}, nil
+	default:
+		return nil, fmt.Errorf("serde %v not supported", kind)
+	}
+}
+
 type GobSerde struct{}
 
-func (r GobSerde) ser(o any) ([]byte, error) {
+func (r GobSerde) Kind() SerdeKind {
+	return SerdeGob
+}
+
+func (r GobSerde) Ser(o any) ([]byte, error) {
 	var buf bytes.Buffer
 	enc := gob.NewEncoder(&buf)
 	err := enc.Encode(o)
@@ -17,8 +63,251 @@ func (r GobSerde) ser(o any) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (r GobSerde) de(data []byte, obj any) error {
+func (r GobSerde) De(data []byte, obj any) error {
 	buf := bytes.NewBuffer(data)
 	dec := gob.NewDecoder(buf)
 	return dec.Decode(obj)
 }
+
+// JsonSerde encodes values as JSON, readable from non-Go services
+type JsonSerde struct{}
+
+func (r JsonSerde) Kind() SerdeKind {
+	return SerdeJson
+}
+
+func (r JsonSerde) Ser(o any) ([]byte, error) {
+	return json.Marshal(o)
+}
+
+func (r JsonSerde) De(data []byte, obj any) error {
+	return json.Unmarshal(data, obj)
+}
+
+// MsgpackSerde encodes values with the compact MessagePack binary format
+type MsgpackSerde struct{}
+
+func (r MsgpackSerde) Kind() SerdeKind {
+	return SerdeMsgpack
+}
+
+func (r MsgpackSerde) Ser(o any) ([]byte, error) {
+	return msgpack.Marshal(o)
+}
+
+func (r MsgpackSerde) De(data []byte, obj any) error {
+	return msgpack.Unmarshal(data, obj)
+}
+
+// header written in front of each encoded value
+//
+//	byte 0-1: magic 0xC7 0x5E
+//	byte 2:   serde id (1: gob, 2: json, 3: msgpack)
+//	byte 3:   flags, bits 0-3 compression (0: none, 1: gzip, 2: zstd), bits 4-7 reserved
+//
+// values without the header were written before serdes were pluggable & are
+// always gob encoded
+var headerMagic = [2]byte{0xC7, 0x5E}
+
+const headerLen = 4
+
+var serdeIds = map[SerdeKind]byte{
+	SerdeGob:     1,
+	SerdeJson:    2,
+	SerdeMsgpack: 3,
+}
+
+// EncodeValue serializes the value with the supplied serde & prefixes the
+// header identifying the serde
+func EncodeValue(s Serde, val any) ([]byte, error) {
+	id, ok := serdeIds[s.Kind()]
+	if !ok {
+		return nil, fmt.Errorf("serde %v has no header id", s.Kind())
+	}
+
+	data, err := s.Ser(val)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]byte, 0, headerLen+len(data))
+	out = append(out, headerMagic[0], headerMagic[1], id, 0)
+	return append(out, data...), nil
+}
+
+// DecodeValue deserializes the data into val (a pointer) using the serde recorded
+// in the header, so values written by any supported serde can be read. Compressed
+// data is decompressed first. Data without a header is decoded with gob
+func DecodeValue(data []byte, val any) error {
+	kind, ok := DetectSerde(data)
+	if !ok {
+		return GobSerde{}.De(data, val)
+	}
+
+	s, err := NewSerde(kind)
+	if err != nil {
+		return err
+	}
+	payload, err := decompress(data[3], data[headerLen:])
+	if err != nil {
+		return err
+	}
+	return s.De(payload, val)
+}
+
+// encodeRaw converts the value to the bytes stored by a cache, []byte & string values
+// are stored as-is, everything else is encoded with the supplied serde
+func encodeRaw(s Serde, val any) ([]byte, error) {
+	switch rval := val.(type) {
+	case []byte:
+		return rval, nil
+	case string:
+		return []byte(rval), nil
+	default:
+		data, err := EncodeValue(s, val)
+		if err != nil {
+			return nil, &ValueError{err}
+		}
+		return data, nil
+	}
+}
+
+// decodeRaw converts the bytes stored by a cache into val (a pointer), see encodeRaw
+func decodeRaw(data []byte, val any) error {
+	switch rval := val.(type) {
+	case *[]byte:
+		*rval = data
+		return nil
+	case *string:
+		*rval = string(data)
+		return nil
+	default:
+		if err := DecodeValue(data, val); err != nil {
+			return &ValueError{err}
+		}
+		return nil
+	}
+}
+
+// DetectSerde returns the kind of serde that encoded the data, false if the data
+// has no header
+func DetectSerde(data []byte) (SerdeKind, bool) {
+	if len(data) < headerLen || data[0] != headerMagic[0] || data[1] != headerMagic[1] {
+		return "", false
+	}
+	for kind, id := range serdeIds {
+		if data[2] == id {
+			return kind, true
+		}
+	}
+	return "", false
+}
+
+type Compression string
+
+const (
+	CompressionNone Compression = "none"
+	CompressionGzip Compression = "gzip"
+	CompressionZstd Compression = "zstd"
+)
+
+// DefaultCompressThreshold is the payload size, in bytes, from which values are compressed
+const DefaultCompressThreshold = 1024
+
+// MaxDecompressedSize is the maximum size, in bytes, of a decompressed payload; larger
+// payloads fail to decode, so a small compressed value can't exhaust memory
+const MaxDecompressedSize = 64 << 20
+
+// compressionMask selects the compression bits of the header flags
+const compressionMask byte = 0x0F
+
+var compressionFlags = map[Compression]byte{
+	CompressionGzip: 1,
+	CompressionZstd: 2,
+}
+
+// the zstd encoder & decoder are safe for concurrent use of EncodeAll & DecodeAll
+var (
+	zstdEncoder = sync.OnceValues(func() (*zstd.Encoder, error) { return zstd.NewWriter(nil) })
+	zstdDecoder = sync.OnceValues(func() (*zstd.Decoder, error) {
+		return zstd.NewReader(nil, zstd.WithDecoderMaxMemory(MaxDecompressedSize))
+	})
+)
+
+// CompressValue compresses the payload of the encoded value with the supplied
+// compression if it is at least threshold bytes, & records the compression in the
+// header flags. Data without a header, data that is already compressed & payloads
+// that do not shrink are returned as-is
+func CompressValue(data []byte, c Compression, threshold int) ([]byte, error) {
+	if c == "" || c == CompressionNone {
+		return data, nil
+	}
+	flag, ok := compressionFlags[c]
+	if !ok {
+		return nil, fmt.Errorf("compression %v not supported", c)
+	}
+	if _, ok := DetectSerde(data); !ok || data[3]&compressionMask != 0 || len(data)-headerLen < threshold {
+		return data, nil
+	}
+
+	out := append(make([]byte, 0, len(data)), data[:headerLen]...)
+	switch c {
+	case CompressionGzip:
+		buf := bytes.NewBuffer(out)
+		w := gzip.NewWriter(buf)
+		if _, err := w.Write(data[headerLen:]); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		out = buf.Bytes()
+	case CompressionZstd:
+		enc, err := zstdEncoder()
+		if err != nil {
+			return nil, err
+		}
+		out = enc.EncodeAll(data[headerLen:], out)
+	}
+	if len(out) >= len(data) {
+		return data, nil
+	}
+
+	out[3] |= flag
+	return out, nil
+}
+
+// decompress returns the payload decompressed as recorded in the header flags, or an
+// error if it exceeds MaxDecompressedSize
+func decompress(flags byte, payload []byte) ([]byte, error) {
+	switch flags & compressionMask {
+	case 0:
+		return payload, nil
+	case compressionFlags[CompressionGzip]:
+		r, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing value: %w", err)
+		}
+		defer r.Close()
+		out, err := io.ReadAll(io.LimitReader(r, MaxDecompressedSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing value: %w", err)
+		}
+		if len(out) > MaxDecompressedSize {
+			return nil, fmt.Errorf("error decompressing value: exceeds the maximum size of %v bytes", MaxDecompressedSize)
+		}
+		return out, nil
+	case compressionFlags[CompressionZstd]:
+		dec, err := zstdDecoder()
+		if err != nil {
+			return nil, err
+		}
+		out, err := dec.DecodeAll(payload, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error decompressing value: %w", err)
+		}
+		return out, nil
+	default:
+		return nil, fmt.Errorf("value compression flag %v not supported", flags&compressionMask)
+	}
+}
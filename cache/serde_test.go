@@ -0,0 +1,169 @@
+package cache
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type serdeTestValue struct {
+	Name  string
+	Count int
+	Tags  []string
+}
+
+func TestSerde_RoundTrip(t *testing.T) {
+	want := serdeTestValue{Name: "n", Count: 3, Tags: []string{"a", "b"}}
+
+	for _, kind := range []SerdeKind{SerdeGob, SerdeJson, SerdeMsgpack} {
+		s, err := NewSerde(kind)
+		if err != nil {
+			t.Fatalf("NewSerde(%v) returned unexpected error: %v", kind, err)
+		}
+
+		data, err := EncodeValue(s, want)
+		if err != nil {
+			t.Fatalf("%v EncodeValue returned unexpected error: %v", kind, err)
+		}
+
+		if got, ok := DetectSerde(data); !ok || got != kind {
+			t.Errorf("DetectSerde = (%v, %v); want (%v, true)", got, ok, kind)
+		}
+
+		var got serdeTestValue
+		if err := DecodeValue(data, &got); err != nil {
+			t.Fatalf("%v DecodeValue returned unexpected error: %v", kind, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v DecodeValue = %+v; want %+v", kind, got, want)
+		}
+	}
+}
+
+func TestNewSerde_Default(t *testing.T) {
+	s, err := NewSerde("")
+	if err != nil {
+		t.Fatalf("NewSerde returned unexpected error: %v", err)
+	}
+	if s.Kind() != SerdeGob {
+		t.Errorf("Kind = %v; want %v", s.Kind(), SerdeGob)
+	}
+}
+
+func TestNewSerde_Unknown(t *testing.T) {
+	if _, err := NewSerde("xml"); err == nil {
+		t.Fatal("NewSerde(xml) returned nil error; want error")
+	}
+}
+
+func TestDecodeValue_LegacyGob(t *testing.T) {
+	want := serdeTestValue{Name: "legacy", Count: 1}
+	data, err := GobSerde{}.Ser(want)
+	if err != nil {
+		t.Fatalf("GobSerde.Ser returned unexpected error: %v", err)
+	}
+
+	if _, ok := DetectSerde(data); ok {
+		t.Error("DetectSerde on legacy data = (_, true); want (_, false)")
+	}
+
+	var got serdeTestValue
+	if err := DecodeValue(data, &got); err != nil {
+		t.Fatalf("DecodeValue returned unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("DecodeValue = %+v; want %+v", got, want)
+	}
+}
+
+func TestRedisCache_EncodeDecode(t *testing.T) {
+	r := &RedisCache{serde: JsonSerde{}}
+
+	raw, err := r.encode("plain")
+	if err != nil {
+		t.Fatalf("encode returned unexpected error: %v", err)
+	}
+	if string(raw) != "plain" {
+		t.Errorf("encode(string) = %q; want %q", raw, "plain")
+	}
+
+	data, err := r.encode(serdeTestValue{Name: "j"})
+	if err != nil {
+		t.Fatalf("encode returned unexpected error: %v", err)
+	}
+	if kind, _ := DetectSerde(data); kind != SerdeJson {
+		t.Errorf("DetectSerde = %v; want %v", kind, SerdeJson)
+	}
+
+	var got serdeTestValue
+	if err := r.decode(data, &got); err != nil {
+		t.Fatalf("decode returned unexpected error: %v", err)
+	}
+	if got.Name != "j" {
+		t.Errorf("decode Name = %q; want %q", got.Name, "j")
+	}
+}
+
+func TestCompressValue_RoundTrip(t *testing.T) {
+	want := serdeTestValue{Name: strings.Repeat("n", 4096)}
+	data, _ := EncodeValue(JsonSerde{}, want)
+
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		compressed, err := CompressValue(data, c, 1024)
+		if err != nil {
+			t.Fatalf("%v CompressValue returned unexpected error: %v", c, err)
+		}
+		if len(compressed) >= len(data) || compressed[3]&compressionMask != compressionFlags[c] {
+			t.Fatalf("%v CompressValue = %v bytes, flags %x; want fewer than %v bytes & the compression flag", c, len(compressed), compressed[3], len(data))
+		}
+		if kind, ok := DetectSerde(compressed); !ok || kind != SerdeJson {
+			t.Errorf("%v DetectSerde = (%v, %v); want (%v, true)", c, kind, ok, SerdeJson)
+		}
+
+		var got serdeTestValue
+		if err := DecodeValue(compressed, &got); err != nil {
+			t.Fatalf("%v DecodeValue returned unexpected error: %v", c, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%v DecodeValue = %+v; want %+v", c, got, want)
+		}
+	}
+}
+
+func TestCompressValue_BelowThreshold(t *testing.T) {
+	data, _ := EncodeValue(JsonSerde{}, serdeTestValue{Name: "n"})
+	for _, in := range [][]byte{data, []byte(strings.Repeat("raw", 1024))} {
+		out, err := CompressValue(in, CompressionGzip, 1024)
+		if err != nil || !bytes.Equal(out, in) {
+			t.Errorf("CompressValue = (%v bytes, %v); want the value as-is", len(out), err)
+		}
+	}
+}
+
+func TestDecodeValue_UnknownCompression(t *testing.T) {
+	data, _ := EncodeValue(JsonSerde{}, serdeTestValue{Name: "n"})
+	data[3] = 0x0F
+
+	var got serdeTestValue
+	if err := DecodeValue(data, &got); err == nil {
+		t.Errorf("DecodeValue returned nil error; want error")
+	}
+}
+
+func TestDecodeValue_DecompressedTooLarge(t *testing.T) {
+	header, _ := EncodeValue(JsonSerde{}, "x")
+	data := append(header[:headerLen:headerLen], make([]byte, MaxDecompressedSize+1)...)
+
+	for _, c := range []Compression{CompressionGzip, CompressionZstd} {
+		compressed, err := CompressValue(data, c, 1024)
+		if err != nil {
+			t.Fatalf("%v CompressValue returned unexpected error: %v", c, err)
+		}
+
+		var got []byte
+		if err := DecodeValue(compressed, &got); err == nil || !strings.Contains(err.Error(), "decompressing") {
+			t.Errorf("%v DecodeValue error = %v; want a decompression error", c, err)
+		}
+	}
+}
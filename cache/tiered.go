@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"reflect"
+	"strings"
+	"time"
+
+	redisv9 "github.com/redis/go-redis/v9"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// DefaultL1Ttl is the maximum TTL of L1 entries in a TieredCache when none is configured
+	DefaultL1Ttl = time.Minute
+
+	// DefaultL1MaxEntries bounds the L1 of a TieredCache when no bound is configured
+	DefaultL1MaxEntries = 10000
+
+	// DefaultInvalidationChannel is the redis pub/sub channel used for L1 invalidations
+	DefaultInvalidationChannel = "gotham:cache:invalidate"
+
+	// prefixInvalidationMark follows the instance id in invalidations of a key prefix
+	prefixInvalidationMark = "*"
+)
+
+// TieredCache is a MemoryCache implementation that keeps an in-process RamCache (L1)
+// in front of a RedisCache (L2). Reads are served from L1 when possible, & L2 hits fill
+// L1 with a TTL capped at both the configured L1 TTL & the remaining L2 TTL. Writes &
+// deletes go to both tiers; with invalidation enabled they are published over redis
+// pub/sub so other instances evict their L1 copy
+type TieredCache struct {
+	l1      *RamCache
+	l2      *RedisCache
+	l1Ttl   time.Duration
+	channel string // empty if invalidation is disabled
+	id      string // instance id, used to ignore our own invalidations
+	pubsub  *redisv9.PubSub
+}
+
+// NewTieredCache returns a TieredCache composing the supplied L1 & L2 caches. If
+// invalidation is enabled in the config, the cache subscribes to the invalidation
+// channel; call Close to unsubscribe & stop the L1 janitor
+func NewTieredCache(l1 *RamCache, l2 *RedisCache, cfg TieredConfig) (*TieredCache, error) {
+	t := &TieredCache{
+		l1:    l1,
+		l2:    l2,
+		l1Ttl: cfg.L1Ttl,
+	}
+	if t.l1Ttl <= 0 {
+		t.l1Ttl = DefaultL1Ttl
+	}
+
+	if cfg.Invalidation {
+		t.channel = cfg.Channel
+		if t.channel == "" {
+			t.channel = DefaultInvalidationChannel
+		}
+
+		id := make([]byte, 8)
+		if _, err := rand.Read(id); err != nil {
+			return nil, err
+		}
+		t.id = hex.EncodeToString(id)
+
+		t.pubsub = l2.client.Subscribe(context.Background(), t.channel)
+		if _, err := t.pubsub.Receive(context.Background()); err != nil {
+			_ = t.pubsub.Close()
+			return nil, err
+		}
+		go t.listen(t.pubsub.Channel())
+	}
+	return t, nil
+}
+
+// listen evicts L1 entries for invalidations published by other instances
+func (t *TieredCache) listen(messages <-chan *redisv9.Message) {
+	for msg := range messages {
+		id, key, ok := strings.Cut(msg.Payload, "|")
+		if !ok {
+			continue
+		}
+		id, prefix := strings.CutSuffix(id, prefixInvalidationMark)
+		if id == t.id {
+			continue
+		}
+		if prefix {
+			log.Tracef("evicting prefix %v from l1 cache, invalidated by %v", key, id)
+			_, _ = t.l1.DeleteByPrefix(context.Background(), key)
+			continue
+		}
+		log.Tracef("evicting %v from l1 cache, invalidated by %v", key, id)
+		_, _ = t.l1.Delete(context.Background(), key)
+	}
+}
+
+// invalidate publishes an invalidation for key to the other instances
+func (t *TieredCache) invalidate(ctx context.Context, key string) {
+	if t.channel == "" {
+		return
+	}
+	if err := t.l2.client.Publish(ctx, t.channel, t.id+"|"+key).Err(); err != nil {
+		log.Warnf("error publishing l1 cache invalidation for key %v: %v", key, err)
+	}
+}
+
+// invalidatePrefix publishes an invalidation for all keys with prefix to the other
+// instances
+func (t *TieredCache) invalidatePrefix(ctx context.Context, prefix string) {
+	if t.channel == "" {
+		return
+	}
+	if err := t.l2.client.Publish(ctx, t.channel, t.id+prefixInvalidationMark+"|"+prefix).Err(); err != nil {
+		log.Warnf("error publishing l1 cache invalidation for prefix %v: %v", prefix, err)
+	}
+}
+
+// l1TtlFor returns the TTL for an L1 entry, given the remaining TTL in L2
+func (t *TieredCache) l1TtlFor(l2Ttl time.Duration) time.Duration {
+	if l2Ttl > 0 && l2Ttl < t.l1Ttl {
+		return l2Ttl
+	}
+	return t.l1Ttl
+}
+
+// Close unsubscribes from invalidations & stops the L1 janitor; the L2 cache is not closed
+func (t *TieredCache) Close() error {
+	t.l1.Stop()
+	if t.pubsub != nil {
+		return t.pubsub.Close()
+	}
+	return nil
+}
+
+// Ping checks the health of the L2 cache
+func (t *TieredCache) Ping(ctx context.Context) error {
+	return t.l2.Ping(ctx)
+}
+
+// method implementations
+
+func (t *TieredCache) Put(ctx context.Context, key string, val any) error {
+	return t.PutWithTtl(ctx, key, val, NoExpiry)
+}
+
+func (t *TieredCache) PutWithTtl(ctx context.Context, key string, val any, expiry time.Duration) error {
+	if err := t.l2.PutWithTtl(ctx, key, val, expiry); err != nil {
+		return err
+	}
+	if err := t.l1.PutWithTtl(ctx, key, val, t.l1TtlFor(expiry)); err != nil {
+		log.Debugf("error caching key %v in l1 cache: %v", key, err)
+	}
+	t.invalidate(ctx, key)
+	return nil
+}
+
+func (t *TieredCache) Fetch(ctx context.Context, key string, val any) error {
+	_, err := t.FetchWithTtl(ctx, key, val)
+	return err
+}
+
+// FetchWithTtl fetches the value & TTL for key; an L1 hit reports the remaining L1 TTL,
+// which never exceeds the remaining L2 TTL
+func (t *TieredCache) FetchWithTtl(ctx context.Context, key string, val any) (*time.Duration, error) {
+	if ttl, err := t.l1.FetchWithTtl(ctx, key, val); err == nil {
+		return ttl, nil
+	}
+
+	ttl, err := t.l2.FetchWithTtl(ctx, key, val)
+	if err != nil {
+		return nil, err
+	}
+
+	// fill l1 with the value fetched from l2
+	if ptr := reflect.ValueOf(val); ptr.Kind() == reflect.Pointer && !ptr.IsNil() {
+		if err := t.l1.PutWithTtl(ctx, key, ptr.Elem().Interface(), t.l1TtlFor(*ttl)); err != nil {
+			log.Debugf("error caching key %v in l1 cache: %v", key, err)
+		}
+	}
+	return ttl, nil
+}
+
+func (t *TieredCache) Delete(ctx context.Context, key string) (int64, error) {
+	_, _ = t.l1.Delete(ctx, key)
+	n, err := t.l2.Delete(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	t.invalidate(ctx, key)
+	return n, nil
+}
+
+// bulk method implementations, applied to L2; written & deleted keys are evicted from
+// L1 on every instance
+
+// FetchMany fetches the values from L2 only, as L1 may hold a subset of the keys
+func (t *TieredCache) FetchMany(ctx context.Context, keys []string, vals any) error {
+	return t.l2.FetchMany(ctx, keys, vals)
+}
+
+func (t *TieredCache) PutMany(ctx context.Context, items []Item) error {
+	if err := t.l2.PutMany(ctx, items); err != nil {
+		return err
+	}
+	for _, item := range items {
+		t.evict(ctx, item.Key)
+	}
+	return nil
+}
+
+func (t *TieredCache) DeleteByPrefix(ctx context.Context, prefix string) (int64, error) {
+	_, _ = t.l1.DeleteByPrefix(ctx, prefix)
+	n, err := t.l2.DeleteByPrefix(ctx, prefix)
+	if err != nil {
+		return 0, err
+	}
+	t.invalidatePrefix(ctx, prefix)
+	return n, nil
+}
+
+// atomic method implementations, applied to L2; the L1 entry is evicted on every
+// instance so the next fetch reads the new value
+
+func (t *TieredCache) Incr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	n, err := t.l2.Incr(ctx, key, delta, ttl)
+	if err != nil {
+		return 0, err
+	}
+	t.evict(ctx, key)
+	return n, nil
+}
+
+func (t *TieredCache) Decr(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return t.Incr(ctx, key, -delta, ttl)
+}
+
+func (t *TieredCache) SetIfAbsent(ctx context.Context, key string, val any, ttl time.Duration) (bool, error) {
+	ok, err := t.l2.SetIfAbsent(ctx, key, val, ttl)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		t.evict(ctx, key)
+	}
+	return ok, nil
+}
+
+func (t *TieredCache) CompareAndSwap(ctx context.Context, key string, old any, new any, ttl time.Duration) (bool, error) {
+	ok, err := t.l2.CompareAndSwap(ctx, key, old, new, ttl)
+	if err != nil {
+		return false, err
+	}
+	if ok {
+		t.evict(ctx, key)
+	}
+	return ok, nil
+}
+
+// evict deletes the L1 entry for key & publishes an invalidation
+func (t *TieredCache) evict(ctx context.Context, key string) {
+	_, _ = t.l1.Delete(ctx, key)
+	t.invalidate(ctx, key)
+}
+
+// field method implementations, applied to L2 only as L1 holds whole values
+
+func (t *TieredCache) PutFields(ctx context.Context, key string, val any, ttl time.Duration, fields ...string) error {
+	if err := t.l2.PutFields(ctx, key, val, ttl, fields...); err != nil {
+		return err
+	}
+	t.evict(ctx, key)
+	return nil
+}
+
+func (t *TieredCache) FetchFields(ctx context.Context, key string, val any, fields ...string) error {
+	return t.l2.FetchFields(ctx, key, val, fields...)
+}
+
+func (t *TieredCache) DeleteFields(ctx context.Context, key string, fields ...string) (int64, error) {
+	n, err := t.l2.DeleteFields(ctx, key, fields...)
+	if err != nil {
+		return 0, err
+	}
+	t.evict(ctx, key)
+	return n, nil
+}
@@ -0,0 +1,162 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestTieredCache returns a TieredCache over a fresh L1 & the supplied L2, closed at test cleanup.
+func newTestTieredCache(t *testing.T, l2 *RedisCache, cfg TieredConfig) *TieredCache {
+	t.Helper()
+	l1 := newTestRamCacheWithConfig(t, RamConfig{MaxEntries: 100})
+	tc, err := NewTieredCache(l1, l2, cfg)
+	if err != nil {
+		t.Fatalf("NewTieredCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(func() { _ = tc.Close() })
+	return tc
+}
+
+func TestTieredCache_FillsL1OnL2Hit(t *testing.T) {
+	l2, _ := newTestRedisCache(t)
+	tc := newTestTieredCache(t, l2, TieredConfig{L1Ttl: time.Hour})
+	ctx := context.Background()
+
+	_ = l2.PutWithTtl(ctx, "k", "v", time.Minute)
+
+	var got string
+	if err := tc.Fetch(ctx, "k", &got); err != nil {
+		t.Fatalf("Fetch returned unexpected error: %v", err)
+	}
+	if got != "v" {
+		t.Errorf("Fetch = %q; want %q", got, "v")
+	}
+
+	var l1Val string
+	ttl, err := tc.l1.FetchWithTtl(ctx, "k", &l1Val)
+	if err != nil {
+		t.Fatalf("l1 FetchWithTtl returned unexpected error: %v", err)
+	}
+	if *ttl > time.Minute {
+		t.Errorf("l1 ttl = %v; want <= 1m (the l2 ttl)", *ttl)
+	}
+}
+
+func TestTieredCache_ServesFromL1(t *testing.T) {
+	l2, m := newTestRedisCache(t)
+	tc := newTestTieredCache(t, l2, TieredConfig{})
+	ctx := context.Background()
+
+	if err := tc.PutWithTtl(ctx, "k", "v", time.Minute); err != nil {
+		t.Fatalf("PutWithTtl returned unexpected error: %v", err)
+	}
+	m.Del("k") // only l1 holds the value now
+
+	var got string
+	if err := tc.Fetch(ctx, "k", &got); err != nil || got != "v" {
+		t.Errorf("Fetch = (%q, %v); want (%q, nil)", got, err, "v")
+	}
+}
+
+func TestTieredCache_Delete(t *testing.T) {
+	l2, m := newTestRedisCache(t)
+	tc := newTestTieredCache(t, l2, TieredConfig{})
+	ctx := context.Background()
+	_ = tc.Put(ctx, "k", "v")
+
+	if n, err := tc.Delete(ctx, "k"); err != nil || n != 1 {
+		t.Errorf("Delete = (%d, %v); want (1, nil)", n, err)
+	}
+	if m.Exists("k") {
+		t.Error("key exists in l2 after Delete")
+	}
+
+	var got string
+	if err := tc.Fetch(ctx, "k", &got); !IsCacheMiss(err) {
+		t.Errorf("Fetch after Delete error = %v; want cache miss", err)
+	}
+}
+
+func TestTieredCache_Invalidation(t *testing.T) {
+	l2, _ := newTestRedisCache(t)
+	a := newTestTieredCache(t, l2, TieredConfig{Invalidation: true})
+	b := newTestTieredCache(t, l2, TieredConfig{Invalidation: true})
+	ctx := context.Background()
+
+	_ = a.Put(ctx, "k", "v")
+	var got string
+	if err := b.Fetch(ctx, "k", &got); err != nil { // fills b's l1
+		t.Fatalf("Fetch returned unexpected error: %v", err)
+	}
+
+	if _, err := a.Delete(ctx, "k"); err != nil {
+		t.Fatalf("Delete returned unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if err := b.l1.Fetch(ctx, "k", &got); IsCacheMiss(err) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("l1 entry of other instance was not invalidated within 1s")
+}
+
+func TestTieredCache_Bulk(t *testing.T) {
+	l2, m := newTestRedisCache(t)
+	tc := newTestTieredCache(t, l2, TieredConfig{})
+	ctx := context.Background()
+
+	_ = tc.Put(ctx, "a:1", "old") // fills l1
+	if err := tc.PutMany(ctx, []Item{{Key: "a:1", Val: "v1"}, {Key: "a:2", Val: "v2"}, {Key: "b:1", Val: "v3"}}); err != nil {
+		t.Fatalf("PutMany returned unexpected error: %v", err)
+	}
+
+	got := make(map[string]string)
+	if err := tc.FetchMany(ctx, []string{"a:1", "a:2", "b:1"}, got); err != nil {
+		t.Fatalf("FetchMany returned unexpected error: %v", err)
+	}
+	if got["a:1"] != "v1" || got["a:2"] != "v2" || got["b:1"] != "v3" {
+		t.Errorf("FetchMany = %v; want the values of PutMany", got)
+	}
+
+	var v string
+	_ = tc.Fetch(ctx, "a:1", &v) // fills l1
+	if n, err := tc.DeleteByPrefix(ctx, "a:"); err != nil || n != 2 {
+		t.Errorf("DeleteByPrefix = (%d, %v); want (2, nil)", n, err)
+	}
+	if m.Exists("a:1") || !m.Exists("b:1") {
+		t.Error("DeleteByPrefix did not delete exactly the prefixed keys from l2")
+	}
+	if err := tc.Fetch(ctx, "a:1", &v); !IsCacheMiss(err) {
+		t.Errorf("Fetch after DeleteByPrefix error = %v; want cache miss", err)
+	}
+}
+
+func TestTieredCache_PrefixInvalidation(t *testing.T) {
+	l2, _ := newTestRedisCache(t)
+	a := newTestTieredCache(t, l2, TieredConfig{Invalidation: true})
+	b := newTestTieredCache(t, l2, TieredConfig{Invalidation: true})
+	ctx := context.Background()
+
+	_ = a.Put(ctx, "svc:k", "v")
+	var got string
+	if err := b.Fetch(ctx, "svc:k", &got); err != nil { // fills b's l1
+		t.Fatalf("Fetch returned unexpected error: %v", err)
+	}
+
+	if _, err := a.DeleteByPrefix(ctx, "svc:"); err != nil {
+		t.Fatalf("DeleteByPrefix returned unexpected error: %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if err := b.l1.Fetch(ctx, "svc:k", &got); IsCacheMiss(err) {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("l1 entry of other instance was not invalidated within 1s")
+}
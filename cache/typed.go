@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"time"
+)
+
+// KeySeparator separates the namespace & key parts of a namespaced cache key
+const KeySeparator = "::"
+
+// LoaderFunc loads the value for a key when it's not found in the cache
+type LoaderFunc[V any] func(ctx context.Context) (V, error)
+
+// Typed is a type-safe wrapper over a MemoryCache for values of type V. All keys
+// are namespaced as "namespace::key", or used as-is if the namespace is empty
+type Typed[V any] struct {
+	cache     MemoryCache
+	namespace string
+	loader    *ReadThrough
+}
+
+// NewTyped returns a Typed cache for values of type V, stored in the supplied
+// MemoryCache under the supplied namespace
+func NewTyped[V any](c MemoryCache, namespace string) *Typed[V] {
+	return NewTypedWithOptions[V](c, namespace, ReadThroughOptions{})
+}
+
+// NewTypedWithOptions returns a Typed cache for values of type V, with the supplied
+// options used by GetOrLoad
+func NewTypedWithOptions[V any](c MemoryCache, namespace string, opts ReadThroughOptions) *Typed[V] {
+	return &Typed[V]{
+		cache:     c,
+		namespace: namespace,
+		loader:    NewReadThrough(c, opts),
+	}
+}
+
+// Key returns the namespaced cache key for the supplied key
+func (t *Typed[V]) Key(key string) string {
+	if t.namespace == "" {
+		return key
+	}
+	return t.namespace + KeySeparator + key
+}
+
+// Get returns the cached value for key, or a *CacheMissError if there is none
+func (t *Typed[V]) Get(ctx context.Context, key string) (V, error) {
+	var val V
+	if err := t.cache.Fetch(ctx, t.Key(key), &val); err != nil {
+		var zero V
+		return zero, err
+	}
+	return val, nil
+}
+
+// GetWithTtl returns the cached value for key along with its remaining TTL
+func (t *Typed[V]) GetWithTtl(ctx context.Context, key string) (V, *time.Duration, error) {
+	var val V
+	ttl, err := t.cache.FetchWithTtl(ctx, t.Key(key), &val)
+	if err != nil {
+		var zero V
+		return zero, nil, err
+	}
+	return val, ttl, nil
+}
+
+// Set stores the value for key with the supplied TTL, NoExpiry stores it without expiry
+func (t *Typed[V]) Set(ctx context.Context, key string, val V, ttl time.Duration) error {
+	return t.cache.PutWithTtl(ctx, t.Key(key), val, ttl)
+}
+
+// GetOrLoad returns the cached value for key; on a cache miss the value is loaded
+// with the supplied loader & cached with the supplied TTL. Concurrent misses for the
+// same key share a single load, see ReadThrough
+func (t *Typed[V]) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc[V]) (V, error) {
+	var val V
+	err := t.loader.GetOrLoad(ctx, t.Key(key), ttl, &val, func(ctx context.Context) (any, error) {
+		return loader(ctx)
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return val, nil
+}
+
+// Delete removes the cached value for key
+func (t *Typed[V]) Delete(ctx context.Context, key string) (int64, error) {
+	return t.cache.Delete(ctx, t.Key(key))
+}
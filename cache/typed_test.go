@@ -0,0 +1,132 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+type typedTestValue struct {
+	Id   string
+	Tags []string
+}
+
+func TestTyped_Key(t *testing.T) {
+	if got := NewTyped[int](nil, "ns").Key("k"); got != "ns::k" {
+		t.Errorf("Key = %q; want %q", got, "ns::k")
+	}
+	if got := NewTyped[int](nil, "").Key("k"); got != "k" {
+		t.Errorf("Key without namespace = %q; want %q", got, "k")
+	}
+}
+
+func TestTyped_SetGet(t *testing.T) {
+	r := newTestRamCache(t)
+	typed := NewTyped[typedTestValue](r, "ns")
+	ctx := context.Background()
+
+	want := typedTestValue{Id: "1", Tags: []string{"a"}}
+	if err := typed.Set(ctx, "1", want, time.Minute); err != nil {
+		t.Fatalf("Set returned unexpected error: %v", err)
+	}
+
+	got, err := typed.Get(ctx, "1")
+	if err != nil {
+		t.Fatalf("Get returned unexpected error: %v", err)
+	}
+	if got.Id != want.Id || len(got.Tags) != 1 {
+		t.Errorf("Get = %+v; want %+v", got, want)
+	}
+
+	// the value is stored under the namespaced key
+	var raw typedTestValue
+	if err := r.Fetch(ctx, "ns::1", &raw); err != nil {
+		t.Errorf("Fetch(ns::1) returned unexpected error: %v", err)
+	}
+}
+
+func TestTyped_GetWithTtl(t *testing.T) {
+	typed := NewTyped[string](newTestRamCache(t), "ns")
+	ctx := context.Background()
+	_ = typed.Set(ctx, "k", "v", time.Minute)
+
+	got, ttl, err := typed.GetWithTtl(ctx, "k")
+	if err != nil {
+		t.Fatalf("GetWithTtl returned unexpected error: %v", err)
+	}
+	if got != "v" || *ttl <= 0 {
+		t.Errorf("GetWithTtl = (%q, %v); want (%q, > 0)", got, *ttl, "v")
+	}
+}
+
+func TestTyped_Get_Miss(t *testing.T) {
+	typed := NewTyped[string](newTestRamCache(t), "ns")
+
+	if _, err := typed.Get(context.Background(), "missing"); !IsCacheMiss(err) {
+		t.Fatalf("Get error = %v; want cache miss", err)
+	}
+}
+
+func TestTyped_GetOrLoad(t *testing.T) {
+	typed := NewTyped[string](newTestRamCache(t), "ns")
+	ctx := context.Background()
+
+	calls := 0
+	loader := func(ctx context.Context) (string, error) {
+		calls++
+		return "loaded", nil
+	}
+
+	for i := 0; i < 2; i++ {
+		got, err := typed.GetOrLoad(ctx, "k", time.Minute, loader)
+		if err != nil {
+			t.Fatalf("GetOrLoad returned unexpected error: %v", err)
+		}
+		if got != "loaded" {
+			t.Errorf("GetOrLoad = %q; want %q", got, "loaded")
+		}
+	}
+	if calls != 1 {
+		t.Errorf("loader calls = %d; want 1", calls)
+	}
+}
+
+func TestTyped_GetOrLoad_LoaderError(t *testing.T) {
+	typed := NewTyped[string](newTestRamCache(t), "ns")
+	ctx := context.Background()
+	errLoad := errors.New("load failed")
+
+	_, err := typed.GetOrLoad(ctx, "k", time.Minute, func(ctx context.Context) (string, error) {
+		return "", errLoad
+	})
+	if !errors.Is(err, errLoad) {
+		t.Fatalf("GetOrLoad error = %v; want %v", err, errLoad)
+	}
+	if _, err := typed.Get(ctx, "k"); !IsCacheMiss(err) {
+		t.Errorf("Get after failed load error = %v; want cache miss", err)
+	}
+}
+
+func TestTyped_Delete(t *testing.T) {
+	typed := NewTyped[string](newTestRamCache(t), "ns")
+	ctx := context.Background()
+	_ = typed.Set(ctx, "k", "v", NoExpiry)
+
+	if n, err := typed.Delete(ctx, "k"); err != nil || n != 1 {
+		t.Errorf("Delete = (%d, %v); want (1, nil)", n, err)
+	}
+}
+
+func TestIsCacheMiss(t *testing.T) {
+	miss := &CacheMissError{"k", errors.New("not found")}
+	if !IsCacheMiss(miss) {
+		t.Error("IsCacheMiss(miss) = false; want true")
+	}
+	if !IsCacheMiss(errors.Join(errors.New("ctx"), miss)) {
+		t.Error("IsCacheMiss(wrapped miss) = false; want true")
+	}
+	if IsCacheMiss(errors.New("other")) {
+		t.Error("IsCacheMiss(other) = true; want false")
+	}
+}
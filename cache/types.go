@@ -2,12 +2,17 @@ package cache
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
 
 const (
 	NoExpiry time.Duration = 0
+
+	// PersistentTtl is the TTL reported by FetchWithTtl for keys stored without
+	// an expiry, the same value Redis reports for such keys
+	PersistentTtl time.Duration = -1
 )
 
 // MemoryCache interface definition for a cache abstraction
@@ -28,6 +33,30 @@ type MemoryCache interface {
 	Delete(context.Context, string) (int64, error)
 }
 
+// Lifecycle is implemented by all caches in this package; Close releases the
+// resources held by the cache & Ping checks that it is healthy
+type Lifecycle interface {
+	Close() error
+	Ping(context.Context) error
+}
+
+// Unwrapper is implemented by cache decorators; Unwrap returns the wrapped cache
+type Unwrapper interface {
+	Unwrap() MemoryCache
+}
+
+// Unwrap returns the cache at the bottom of a chain of decorators, e.g. the RedisCache
+// of a Resilient wrapping an Instrumented RedisCache
+func Unwrap(c MemoryCache) MemoryCache {
+	for {
+		u, ok := c.(Unwrapper)
+		if !ok {
+			return c
+		}
+		c = u.Unwrap()
+	}
+}
+
 // CacheMissError represents a cache miss; a defined err type so
 // clients can distinguish between a cache-miss or other errors
 type CacheMissError struct {
@@ -38,3 +67,92 @@ type CacheMissError struct {
 func (e *CacheMissError) Error() string {
 	return fmt.Sprintf("cache-miss: no cached value found for key %v", e.key)
 }
+
+func (e *CacheMissError) Unwrap() error {
+	return e.cause
+}
+
+// IsCacheMiss returns true if the error is, or wraps, a *CacheMissError
+func IsCacheMiss(err error) bool {
+	var miss *CacheMissError
+	return errors.As(err, &miss)
+}
+
+// ValueTooLargeError is returned when an encoded value exceeds the configured maximum
+// size; the value is not written
+type ValueTooLargeError struct {
+	Key  string
+	Size int // encoded size in bytes, after compression
+	Max  int
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of %v bytes for key %v exceeds the maximum value size of %v bytes", e.Size, e.Key, e.Max)
+}
+
+// IsValueTooLarge returns true if the error is, or wraps, a *ValueTooLargeError
+func IsValueTooLarge(err error) bool {
+	var tooLarge *ValueTooLargeError
+	return errors.As(err, &tooLarge)
+}
+
+// ValueError is returned when a value can't be encoded, decoded or assigned to the
+// supplied target; the error is caused by the value, not the cache backend
+type ValueError struct {
+	Err error
+}
+
+func (e *ValueError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ValueError) Unwrap() error {
+	return e.Err
+}
+
+// IsValueError returns true if the error is, or wraps, a *ValueError
+func IsValueError(err error) bool {
+	var invalid *ValueError
+	return errors.As(err, &invalid)
+}
+
+// UnsupportedError is returned by a cache decorator when the wrapped cache doesn't
+// implement the extension interface of an operation, e.g. FetchMany of BulkCache
+type UnsupportedError struct {
+	Op    string // the operation, e.g. FetchMany
+	Cache MemoryCache
+}
+
+func (e *UnsupportedError) Error() string {
+	return fmt.Sprintf("cache %T does not support %v", e.Cache, e.Op)
+}
+
+// IsUnsupported returns true if the error is, or wraps, an *UnsupportedError
+func IsUnsupported(err error) bool {
+	var unsupported *UnsupportedError
+	return errors.As(err, &unsupported)
+}
+
+// bulkOf returns the wrapped cache as a BulkCache, or an *UnsupportedError for op
+func bulkOf(c MemoryCache, op string) (BulkCache, error) {
+	if b, ok := c.(BulkCache); ok {
+		return b, nil
+	}
+	return nil, &UnsupportedError{Op: op, Cache: c}
+}
+
+// atomicOf returns the wrapped cache as an AtomicCache, or an *UnsupportedError for op
+func atomicOf(c MemoryCache, op string) (AtomicCache, error) {
+	if a, ok := c.(AtomicCache); ok {
+		return a, nil
+	}
+	return nil, &UnsupportedError{Op: op, Cache: c}
+}
+
+// fieldsOf returns the wrapped cache as a FieldCache, or an *UnsupportedError for op
+func fieldsOf(c MemoryCache, op string) (FieldCache, error) {
+	if f, ok := c.(FieldCache); ok {
+		return f, nil
+	}
+	return nil, &UnsupportedError{Op: op, Cache: c}
+}
@@ -0,0 +1,122 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	ApiKeyHeader = "X-Api-Key" // request header that holds the API key
+
+	// apiKeyPrefixTag starts the lookup prefix of every key, so leaked keys are easy to
+	// recognize, e.g. by secret scanners
+	apiKeyPrefixTag = "gk_"
+	apiKeyIdLen     = 8  // random bytes of the lookup prefix
+	apiKeySecretLen = 32 // random bytes of the secret
+)
+
+// ErrApiKeyNotFound is returned by a KeyStore if no key exists for a prefix
+var ErrApiKeyNotFound = errors.New("api key not found")
+
+// ApiKey is a stored API key. Keys have the form "<prefix>.<secret>", the prefix is
+// stored as is to look the key up, the key itself only as a hash. A key is random
+// with 256 bits of entropy, so a plain sha256 hash is not open to brute force
+type ApiKey struct {
+	Prefix    string    `json:"prefix"`             // lookup prefix of the key
+	Hash      string    `json:"hash"`               // hex sha256 of the key, see HashApiKey
+	Principal Principal `json:"principal"`          // principal the key authenticates, with its roles
+	ExpiresAt time.Time `json:"expiresAt,omitzero"` // zero if the key never expires
+}
+
+// KeyStore supplies an interface for API key storage implementations
+type KeyStore interface {
+	// FetchKey returns the stored key for the prefix, or ErrApiKeyNotFound
+	FetchKey(ctx context.Context, prefix string) (*ApiKey, error)
+}
+
+// GenerateApiKey returns a new random key for the principal & the ApiKey to store
+// for it; the key is returned to the caller once & can't be recovered from the store
+func GenerateApiKey(pr Principal, expiresAt time.Time) (string, ApiKey, error) {
+	id := make([]byte, apiKeyIdLen)
+	secret := make([]byte, apiKeySecretLen)
+	if _, err := rand.Read(id); err != nil {
+		return "", ApiKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", ApiKey{}, err
+	}
+
+	prefix := apiKeyPrefixTag + hex.EncodeToString(id)
+	key := prefix + "." + base64.RawURLEncoding.EncodeToString(secret)
+	return key, ApiKey{Prefix: prefix, Hash: HashApiKey(key), Principal: pr, ExpiresAt: expiresAt}, nil
+}
+
+// HashApiKey returns the hex sha256 hash of the key
+func HashApiKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// ApiKeyPrefix returns the lookup prefix of the key
+func ApiKeyPrefix(key string) (string, error) {
+	prefix, secret, ok := strings.Cut(key, ".")
+	if !ok || !strings.HasPrefix(prefix, apiKeyPrefixTag) || secret == "" {
+		return "", errors.New("malformed api key")
+	}
+	return prefix, nil
+}
+
+// AuthenticateApiKey returns the principal of the key from the store, if the key is
+// stored & not expired
+func AuthenticateApiKey(ctx context.Context, store KeyStore, key string) (*Principal, error) {
+	prefix, err := ApiKeyPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := store.FetchKey(ctx, prefix)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(HashApiKey(key)), []byte(stored.Hash)) != 1 {
+		return nil, errors.New("invalid api key")
+	}
+	if !stored.ExpiresAt.IsZero() && time.Now().After(stored.ExpiresAt) {
+		return nil, errors.Errorf("api key %v is expired", prefix)
+	}
+
+	pr := stored.Principal
+	return &pr, nil
+}
+
+// apiKeyPrincipal returns the principal of the API key in the request header; roles
+// are mapped from the principal groups if the key assigns none
+func apiKeyPrincipal(ctx context.Context, ap AuthPolicy, r *http.Request, store KeyStore) (*Principal, error) {
+	key := r.Header.Get(ApiKeyHeader)
+	if key == "" {
+		return nil, errors.New("no api key found from header")
+	}
+
+	pr, err := AuthenticateApiKey(ctx, store, key)
+	if err != nil {
+		return nil, errors.New("invalid api key")
+	}
+	if len(pr.Roles) == 0 {
+		var isSuper, isAdmin bool
+		pr.Roles, isSuper, isAdmin = rolesFromGroups(ap.Config, pr.Groups)
+		pr.IsSuperAdmin, pr.IsAdmin = pr.IsSuperAdmin || isSuper, pr.IsAdmin || isAdmin
+	}
+	if pr.Roles == nil {
+		pr.Roles = Set{}
+	}
+	return pr, nil
+}
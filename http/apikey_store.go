@@ -0,0 +1,137 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/TouchBistro/gotham/sql/qb"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileKeyStore implements KeyStore from a JSON file holding an array of ApiKey
+type FileKeyStore struct {
+	path string
+
+	mu   sync.RWMutex
+	keys map[string]ApiKey
+}
+
+// NewFileKeyStore returns a FileKeyStore with the keys loaded from the file
+func NewFileKeyStore(path string) (*FileKeyStore, error) {
+	s := &FileKeyStore{path: path}
+	if err := s.Reload(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Reload reads the keys from the file again, e.g. after keys were added or revoked;
+// on error the loaded keys are kept
+func (s *FileKeyStore) Reload() error {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return errors.Wrapf(err, "error reading api key file: %v", s.path)
+	}
+	var list []ApiKey
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.Wrapf(err, "error reading api key file: %v", s.path)
+	}
+
+	keys := make(map[string]ApiKey, len(list))
+	for _, k := range list {
+		if _, ok := keys[k.Prefix]; ok {
+			return errors.Errorf("duplicate api key prefix %v in file: %v", k.Prefix, s.path)
+		}
+		keys[k.Prefix] = k
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.mu.Unlock()
+
+	log.Debugf("loaded %v api keys from %v", len(keys), s.path)
+	return nil
+}
+
+// FetchKey implements the interface method
+func (s *FileKeyStore) FetchKey(ctx context.Context, prefix string) (*ApiKey, error) {
+	s.mu.RLock()
+	k, ok := s.keys[prefix]
+	s.mu.RUnlock()
+	if !ok {
+		return nil, ErrApiKeyNotFound
+	}
+	return &k, nil
+}
+
+// ApiKeyRow is the sql/qb entity of a stored API key, the principal is stored as json
+type ApiKeyRow struct {
+	Prefix    string    `qb:"prefix,pk,r,a"`
+	Hash      string    `qb:"hash,r,a,w"`
+	Principal string    `qb:"principal,type=JSONB,r,a,w"`
+	ExpiresAt time.Time `qb:"expires_at,type=TIMESTAMPTZ,r,a,w"` // zero time if the key never expires
+}
+
+func (k ApiKeyRow) Key() qb.PrimaryKey { return k.Prefix }
+
+func (k ApiKeyRow) Equals(other ApiKeyRow) bool {
+	return k.Prefix == other.Prefix && k.Hash == other.Hash && k.Principal == other.Principal && k.ExpiresAt.Equal(other.ExpiresAt)
+}
+
+// QbKeyStore implements KeyStore from a database table, by default "public.api_key",
+// of ApiKeyRow
+type QbKeyStore struct {
+	db    *sql.DB
+	table *qb.Table[ApiKeyRow]
+}
+
+// NewQbKeyStore returns a QbKeyStore for the table, see qb.ForTable
+func NewQbKeyStore(db *sql.DB, tableName ...string) (*QbKeyStore, error) {
+	if len(tableName) == 0 {
+		tableName = []string{"api_key"}
+	}
+	table, err := qb.ForTable[ApiKeyRow](tableName...)
+	if err != nil {
+		return nil, err
+	}
+	return &QbKeyStore{db: db, table: table}, nil
+}
+
+// FetchKey implements the interface method
+func (s *QbKeyStore) FetchKey(ctx context.Context, prefix string) (*ApiKey, error) {
+	rows, err := s.table.SelectWhere(ctx, s.db, qb.WhereString("WHERE prefix = $1"), prefix)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrApiKeyNotFound
+	}
+
+	row := rows[0]
+	k := &ApiKey{Prefix: row.Prefix, Hash: row.Hash, ExpiresAt: row.ExpiresAt}
+	if err := json.Unmarshal([]byte(row.Principal), &k.Principal); err != nil {
+		return nil, errors.Wrapf(err, "error reading principal of api key %v", prefix)
+	}
+	return k, nil
+}
+
+// PutKey stores the key
+func (s *QbKeyStore) PutKey(ctx context.Context, k ApiKey) error {
+	pr, err := json.Marshal(k.Principal)
+	if err != nil {
+		return err
+	}
+	_, err = s.table.Insert(ctx, s.db, ApiKeyRow{Prefix: k.Prefix, Hash: k.Hash, Principal: string(pr), ExpiresAt: k.ExpiresAt})
+	return err
+}
+
+// DeleteKey revokes the key with the prefix
+func (s *QbKeyStore) DeleteKey(ctx context.Context, prefix string) error {
+	_, err := s.table.Delete(ctx, s.db, ApiKeyRow{Prefix: prefix})
+	return err
+}
@@ -0,0 +1,208 @@
+package http
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/gotham/sql/sqltest"
+	"github.com/gin-gonic/gin"
+)
+
+// mapKeyStore is a KeyStore backed by a map.
+type mapKeyStore map[string]ApiKey
+
+func (m mapKeyStore) FetchKey(ctx context.Context, prefix string) (*ApiKey, error) {
+	k, ok := m[prefix]
+	if !ok {
+		return nil, ErrApiKeyNotFound
+	}
+	return &k, nil
+}
+
+// newTestApiKey returns a generated key & a store holding it.
+func newTestApiKey(t *testing.T, pr Principal, expiresAt time.Time) (string, mapKeyStore) {
+	t.Helper()
+
+	key, stored, err := GenerateApiKey(pr, expiresAt)
+	if err != nil {
+		t.Fatalf("GenerateApiKey returned unexpected error: %v", err)
+	}
+	return key, mapKeyStore{stored.Prefix: stored}
+}
+
+func TestAuthenticateApiKey(t *testing.T) {
+	key, store := newTestApiKey(t, Principal{Id: "shipit", Roles: RoleSetFrom("deployer")}, time.Time{})
+	expiredKey, expired := newTestApiKey(t, Principal{Id: "old"}, time.Now().Add(-time.Minute))
+	for k, v := range expired {
+		store[k] = v
+	}
+	ctx := context.Background()
+
+	pr, err := AuthenticateApiKey(ctx, store, key)
+	if err != nil || pr.Id != "shipit" || !pr.Roles.Contains("deployer") {
+		t.Errorf("AuthenticateApiKey = (%+v, %v); want shipit deployer", pr, err)
+	}
+
+	prefix, _ := ApiKeyPrefix(key)
+	invalid := map[string]string{
+		"wrong secret": prefix + ".c2VjcmV0",
+		"unknown":      "gk_0000000000000000.c2VjcmV0",
+		"expired":      expiredKey,
+		"malformed":    "not-a-key",
+		"empty secret": prefix + ".",
+	}
+	for name, k := range invalid {
+		if _, err := AuthenticateApiKey(ctx, store, k); err == nil {
+			t.Errorf("AuthenticateApiKey(%v) returned nil error; want error", name)
+		}
+	}
+}
+
+func TestGenerateApiKey(t *testing.T) {
+	key, stored, err := GenerateApiKey(Principal{Id: "shipit"}, time.Time{})
+	if err != nil {
+		t.Fatalf("GenerateApiKey returned unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(key, stored.Prefix+".") {
+		t.Errorf("key = %q; want prefix %q", key, stored.Prefix)
+	}
+
+	data, _ := json.Marshal(stored)
+	if strings.Contains(string(data), strings.TrimPrefix(key, stored.Prefix+".")) {
+		t.Errorf("stored key %s contains the secret", data)
+	}
+	if stored.Hash != HashApiKey(key) {
+		t.Errorf("Hash = %q; want %q", stored.Hash, HashApiKey(key))
+	}
+}
+
+func TestFileKeyStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	key, stored, _ := GenerateApiKey(Principal{Id: "shipit", Roles: RoleSetFrom("deployer")}, time.Time{})
+	write := func(keys ...ApiKey) {
+		data, _ := json.Marshal(keys)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("WriteFile returned unexpected error: %v", err)
+		}
+	}
+	write(stored)
+
+	s, err := NewFileKeyStore(path)
+	if err != nil {
+		t.Fatalf("NewFileKeyStore returned unexpected error: %v", err)
+	}
+	ctx := context.Background()
+	if pr, err := AuthenticateApiKey(ctx, s, key); err != nil || !pr.Roles.Contains("deployer") {
+		t.Errorf("AuthenticateApiKey = (%+v, %v); want deployer", pr, err)
+	}
+
+	// revoked
+	write()
+	if err := s.Reload(); err != nil {
+		t.Fatalf("Reload returned unexpected error: %v", err)
+	}
+	if _, err := s.FetchKey(ctx, stored.Prefix); !errors.Is(err, ErrApiKeyNotFound) {
+		t.Errorf("FetchKey error = %v; want %v", err, ErrApiKeyNotFound)
+	}
+
+	write(stored, stored)
+	if err := s.Reload(); err == nil {
+		t.Error("Reload(duplicate prefix) returned nil error; want error")
+	}
+}
+
+func TestQbKeyStore_FetchKey(t *testing.T) {
+	key, stored, _ := GenerateApiKey(Principal{Id: "shipit", Roles: RoleSetFrom("deployer")}, time.Time{})
+	pr, _ := json.Marshal(stored.Principal)
+
+	conn := &sqltest.Conn{
+		Cols: []string{"prefix", "hash", "principal", "expires_at"},
+		Rows: [][]driver.Value{{stored.Prefix, stored.Hash, pr, time.Time{}}},
+	}
+	db := sqltest.NewDB(conn)
+	defer func() { _ = db.Close() }()
+
+	s, err := NewQbKeyStore(db)
+	if err != nil {
+		t.Fatalf("NewQbKeyStore returned unexpected error: %v", err)
+	}
+
+	got, err := AuthenticateApiKey(context.Background(), s, key)
+	if err != nil || got.Id != "shipit" || !got.Roles.Contains("deployer") {
+		t.Errorf("AuthenticateApiKey = (%+v, %v); want shipit deployer", got, err)
+	}
+	if !strings.Contains(conn.Query, "FROM public.api_key") || conn.Args[0] != stored.Prefix {
+		t.Errorf("query = %q %v; want a select from public.api_key by prefix", conn.Query, conn.Args)
+	}
+
+	conn.Rows = nil
+	if _, err := s.FetchKey(context.Background(), stored.Prefix); !errors.Is(err, ErrApiKeyNotFound) {
+		t.Errorf("FetchKey error = %v; want %v", err, ErrApiKeyNotFound)
+	}
+}
+
+func TestApiKeyAuthorizeGinHandler(t *testing.T) {
+	key, store := newTestApiKey(t, Principal{Id: "shipit", Groups: []string{"admins"}}, time.Time{})
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(ApiKeyAuthorizeGinHandler(testBearerPolicy(""), store)...)
+	engine.GET("/", func(c *gin.Context) {
+		pr := c.MustGet(ContextKeyPrincipal).(Principal)
+		c.String(http.StatusOK, pr.Id)
+	})
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(ApiKeyHeader, key)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(key); rec.Code != http.StatusOK || rec.Body.String() != "shipit" {
+		t.Errorf("valid key = (%d, %q); want (%d, %q)", rec.Code, rec.Body.String(), http.StatusOK, "shipit")
+	}
+	if rec := serve(key + "x"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("invalid key status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := serve(""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("missing key status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestApiKeyAuthorizeHttpMiddlewares(t *testing.T) {
+	admin, store := newTestApiKey(t, Principal{Id: "shipit", Roles: RoleSetFrom("admin")}, time.Time{})
+	user, users := newTestApiKey(t, Principal{Id: "cron", Roles: RoleSetFrom("user")}, time.Time{})
+	for k, v := range users {
+		store[k] = v
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), ApiKeyAuthorizeHttpMiddlewares(testBearerPolicy(""), store)...)
+
+	serve := func(key string) int {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(ApiKeyHeader, key)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := serve(admin); code != http.StatusOK {
+		t.Errorf("admin key status = %d; want %d", code, http.StatusOK)
+	}
+	if code := serve(user); code != http.StatusUnauthorized {
+		t.Errorf("user key status = %d; want %d", code, http.StatusUnauthorized)
+	}
+}
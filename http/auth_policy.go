@@ -42,9 +42,9 @@ func LoadPolicyFromFile(path string) (*AuthPolicy, error) {
 	return ap, nil
 }
 
-// loadPolicyDefault loads the default auth policy (hardcoded)
+// loadPolicyDefault loads the default auth policy (hardcoded), with its items compiled
 func loadPolicyDefault() *AuthPolicy {
-	return &AuthPolicy{
+	ap := &AuthPolicy{
 		Config: Config{
 			Roles: RolesConfig{
 				AdminRoles:      nil,
@@ -75,4 +75,8 @@ func loadPolicyDefault() *AuthPolicy {
 			},
 		},
 	}
+	if err := ap.AuthrPolicies.Compile(); err != nil {
+		log.Errorf("error compiling the default auth policy: %v", err)
+	}
+	return ap
 }
@@ -0,0 +1,112 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// principalTtl is the TTL of a principal merged with the one from the principal
+	// loader; principals from the id token claims expire with the token
+	principalTtl = 119 * time.Second
+
+	// principalRefreshAhead reloads a cached principal in the background when it
+	// expires within this duration
+	principalRefreshAhead = 30 * time.Second
+
+	// principalNegativeTtl remembers failed principal loads, so a failing loader isn't
+	// called on every request
+	principalNegativeTtl = 5 * time.Second
+)
+
+// awsalbPrincipal returns the principal for sub from the cache or, on a cache miss,
+// loads it from the ALB id token claims & the supplied loader & caches it. Concurrent
+// cache misses for the same sub share a single load, see cache.ReadThrough. If ALB or
+// JWT signature validation is enabled, the id token is verified on every request,
+// cached principal or not, & its "sub" claim must be sub. The raw token of the
+// principal is always the id token of the request
+func awsalbPrincipal(ctx context.Context, ap AuthPolicy, r *http.Request, sub string, cloader CachePrincipalLoader, loader PrincipalLoader) (*Principal, error) {
+	cfg := ap.Config.JwtConfig
+	token, err := httpRequestHeaderValue(r, cfg.IdTokenHeader, 0)
+	if err != nil {
+		if cfg.ValidateAlbSignature || cfg.ValidateJwtSignature {
+			return nil, errors.New("no id token value found from header")
+		}
+		// without an id token, only a cached principal can be used
+		pr, err := cloader.FetchPrincipal(ctx, sub)
+		if err != nil {
+			return nil, errors.New("no id token value found from header")
+		}
+		return pr, nil
+	}
+
+	// the claims of the verified id token, nil if signature validation is disabled
+	var claims map[string]any
+	if cfg.ValidateAlbSignature || cfg.ValidateJwtSignature {
+		claims, err = JwtClaimsPrincipalLoader{config: ap.Config, jwt: token}.claims(ctx)
+		if err != nil {
+			return nil, errors.New("invalid id token")
+		}
+		if tokenSub, _ := claims["sub"].(string); tokenSub != sub {
+			return nil, errors.Errorf("incorrect sub claim %v found in id token", tokenSub)
+		}
+	}
+
+	pr, err := cloader.principals().GetOrLoad(ctx, sub, principalTtl, func(ctx context.Context) (Principal, error) {
+		pr, err := loadAwsalbPrincipal(ctx, ap, token, sub, claims, loader)
+		if err != nil {
+			return Principal{}, err
+		}
+		return *pr, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if pr.Roles == nil {
+		pr.Roles = Set{}
+	}
+	pr.RawToken = token
+	return &pr, nil
+}
+
+// loadAwsalbPrincipal loads the principal for sub from the ALB id token claims, the
+// supplied claims if the token is already verified; if the claims don't identify the
+// principal, it is merged with the principal fetched from the supplied loader
+func loadAwsalbPrincipal(ctx context.Context, ap AuthPolicy, token string, sub string, claims map[string]any, loader PrincipalLoader) (*Principal, error) {
+
+	jloader := JwtClaimsPrincipalLoader{
+		config:   ap.Config,
+		jwt:      token,
+		verified: claims,
+	}
+	pr, err := jloader.FetchPrincipal(ctx, sub)
+	if err != nil {
+		return nil, errors.New("error loading principal from cliams in JWT")
+	}
+
+	// if login claim isn't there, we need to fill/sync it up from the supplied principal loader
+	// this is suppose to fetch a Principal from a system of record like a DB or some other application
+	// specific store
+	if pr.Login == "" {
+		prFromDb := pr // init with the item from claims
+		if loader != nil {
+			if prFromDb, err = loader.FetchPrincipal(ctx, sub); err != nil {
+				return nil, errors.Errorf("principal JWT token didn't contain enough claims, but error fetching principal auth info from database/n%v", err.Error())
+			}
+		}
+
+		// here we fill out roles from the gruops that are policy def specific
+		prFromDb.Roles, prFromDb.IsSuperAdmin, prFromDb.IsAdmin = rolesFromGroups(ap.Config, prFromDb.Groups)
+
+		// merge the principal from cliams with the principal from storage
+		pr.Merge(*prFromDb)                      // merge with the principal obj from database
+		pr.Expiry = time.Now().Add(principalTtl) // force 2m expiry after merge to eff ignore setting expiry from the database record
+	}
+
+	// the raw token is set per request, it isn't shared with other requests for sub
+	pr.RawToken = ""
+	return pr, nil
+}
@@ -0,0 +1,155 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// signTestJwt returns an HS256-signed JWT with the supplied sub & private claims.
+func signTestJwt(t *testing.T, sub string, claims map[string]any) string {
+	t.Helper()
+
+	b := jwt.NewBuilder().Subject(sub).Expiration(time.Now().Add(time.Hour))
+	for k, v := range claims {
+		b = b.Claim(k, v)
+	}
+	tok, err := b.Build()
+	if err != nil {
+		t.Fatalf("jwt.Builder.Build returned unexpected error: %v", err)
+	}
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.HS256, []byte("test-secret")))
+	if err != nil {
+		t.Fatalf("jwt.Sign returned unexpected error: %v", err)
+	}
+	return string(signed)
+}
+
+// testAwsalbPolicy returns an auth policy reading the sub & id token from test headers.
+func testAwsalbPolicy() AuthPolicy {
+	return AuthPolicy{
+		Config: Config{
+			JwtConfig: JwtConfig{
+				IdTokenHeader:  "X-Amzn-Oidc-Data",
+				SubClaimHeader: "X-Amzn-Oidc-Identity",
+			},
+		},
+	}
+}
+
+func TestAwsalbPrincipal_CachesLoadedPrincipal(t *testing.T) {
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+	ctx := context.Background()
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Amzn-Oidc-Data", signTestJwt(t, "sub-1", map[string]any{"login": "jane.doe@example.com"}))
+
+	pr, err := awsalbPrincipal(ctx, ap, req, "sub-1", cloader, nil)
+	if err != nil {
+		t.Fatalf("awsalbPrincipal returned unexpected error: %v", err)
+	}
+	if pr.Alias != "jane_doe" {
+		t.Errorf("Alias = %q; want %q", pr.Alias, "jane_doe")
+	}
+
+	// a cached principal doesn't need the id token header
+	pr, err = awsalbPrincipal(ctx, ap, httptest.NewRequest(http.MethodGet, "/", nil), "sub-1", cloader, nil)
+	if err != nil {
+		t.Fatalf("awsalbPrincipal for cached principal returned unexpected error: %v", err)
+	}
+	if pr.Login != "jane.doe@example.com" {
+		t.Errorf("cached Login = %q; want %q", pr.Login, "jane.doe@example.com")
+	}
+}
+
+func TestAwsalbPrincipal_MissingIdToken(t *testing.T) {
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if _, err := awsalbPrincipal(context.Background(), testAwsalbPolicy(), req, "sub-1", cloader, nil); err == nil {
+		t.Fatal("awsalbPrincipal returned nil error; want error")
+	}
+}
+
+func TestAwsalbPrincipal_DeduplicatesLoads(t *testing.T) {
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+	token := signTestJwt(t, "sub-1", nil) // no login claim, the loader is consulted
+
+	var calls atomic.Int32
+	loader := PrincipalLoaderFunc(func(ctx context.Context, sub string) (*Principal, error) {
+		calls.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		return &Principal{Id: sub, Login: "jdoe"}, nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("X-Amzn-Oidc-Data", token)
+			if _, err := awsalbPrincipal(context.Background(), ap, req, "sub-1", cloader, loader); err != nil {
+				t.Errorf("awsalbPrincipal returned unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader calls = %d; want 1", n)
+	}
+}
+
+func TestAwsalbPrincipal_RawTokenPerRequest(t *testing.T) {
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+	ctx := context.Background()
+
+	for _, token := range []string{
+		signTestJwt(t, "sub-1", map[string]any{"login": "jane.doe@example.com"}),
+		signTestJwt(t, "sub-1", map[string]any{"login": "jane.doe@example.com", "nonce": "2"}),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Amzn-Oidc-Data", token)
+		pr, err := awsalbPrincipal(ctx, ap, req, "sub-1", cloader, nil)
+		if err != nil {
+			t.Fatalf("awsalbPrincipal returned unexpected error: %v", err)
+		}
+		if pr.RawToken != token {
+			t.Errorf("RawToken = %q; want the token of the request %q", pr.RawToken, token)
+		}
+	}
+}
+
+func TestAwsalbPrincipal_RemembersLoadErrors(t *testing.T) {
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+
+	var calls atomic.Int32
+	loader := PrincipalLoaderFunc(func(ctx context.Context, sub string) (*Principal, error) {
+		calls.Add(1)
+		return nil, errors.New("database unavailable")
+	})
+
+	for range 3 {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Amzn-Oidc-Data", signTestJwt(t, "sub-1", nil))
+		if _, err := awsalbPrincipal(context.Background(), ap, req, "sub-1", cloader, loader); err == nil {
+			t.Fatal("awsalbPrincipal returned nil error; want error")
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("loader calls = %d; want 1", n)
+	}
+}
@@ -0,0 +1,284 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	albKeyMaxSize = 1 << 14
+
+	// albMaxKeyFailures bounds the remembered key fetch failures
+	albMaxKeyFailures = 1024
+
+	// albMinKeyFetchInterval limits fetches of unknown kids across all kids, so forged
+	// tokens with random kids can't flood the keys endpoint
+	albMinKeyFetchInterval = time.Second
+)
+
+// errAlbKeyFetchLimited is returned when a key fetch is within albMinKeyFetchInterval
+// of the last one
+var errAlbKeyFetchLimited = errors.New("alb public key fetches are rate limited")
+
+// albKeyIdPattern matches the key ids of ALB public keys, which are UUIDs; kids are
+// part of the key url so anything else is rejected before a fetch
+var albKeyIdPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// albVerifiers holds an AlbVerifier per JWT config, so fetched keys are shared
+// across requests
+var albVerifiers sync.Map
+
+// AlbVerifier verifies the ES256 signed JWTs that an AWS ALB passes to its targets in
+// the x-amzn-oidc-data header. The public key for the "kid" header is fetched as PEM
+// from "<keys url>/<kid>" & cached; the "signer" header must be the configured ALB
+// ARN. Any error rejects the token
+type AlbVerifier struct {
+	arn     string
+	keysUrl string
+	issuer  string
+	client  *http.Client
+
+	mu        sync.RWMutex
+	keys      map[string]*ecdsa.PublicKey
+	failures  map[string]time.Time // kid -> time of the last failed fetch
+	fetchedAt time.Time            // time of the last key fetch
+	fetches   singleflight.Group
+}
+
+// albTokenHeader is the JOSE header of an ALB token
+type albTokenHeader struct {
+	Alg    string `json:"alg"`
+	Kid    string `json:"kid"`
+	Signer string `json:"signer"`
+}
+
+// NewAlbVerifier returns an AlbVerifier for cfg.AlbArn; the keys are fetched from
+// cfg.AlbKeysUrl or, if empty, the regional ALB public keys endpoint of the ARN
+func NewAlbVerifier(cfg JwtConfig) (*AlbVerifier, error) {
+	if cfg.AlbArn == "" {
+		return nil, errors.New("alb signature validation requires an albArn")
+	}
+
+	keysUrl := cfg.AlbKeysUrl
+	if keysUrl == "" {
+		// arn:aws:elasticloadbalancing:<region>:<account>:loadbalancer/app/<name>/<id>
+		parts := strings.Split(cfg.AlbArn, ":")
+		if len(parts) < 6 || parts[2] != "elasticloadbalancing" || parts[3] == "" {
+			return nil, errors.Errorf("invalid alb arn %v", cfg.AlbArn)
+		}
+		keysUrl = "https://public-keys.auth.elb." + parts[3] + ".amazonaws.com"
+	}
+
+	return &AlbVerifier{
+		arn:      cfg.AlbArn,
+		keysUrl:  strings.TrimSuffix(keysUrl, "/"),
+		issuer:   cfg.Issuer,
+		client:   &http.Client{Timeout: jwksFetchTimeout},
+		keys:     make(map[string]*ecdsa.PublicKey),
+		failures: make(map[string]time.Time),
+	}, nil
+}
+
+// albVerifierFor returns the shared AlbVerifier for the config
+func albVerifierFor(cfg JwtConfig) (*AlbVerifier, error) {
+	key := strings.Join([]string{cfg.AlbArn, cfg.AlbKeysUrl, cfg.Issuer}, "\n")
+	if v, ok := albVerifiers.Load(key); ok {
+		return v.(*AlbVerifier), nil
+	}
+
+	v, err := NewAlbVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := albVerifiers.LoadOrStore(key, v)
+	return actual.(*AlbVerifier), nil
+}
+
+// Verify checks the signature, signer & the "exp", "nbf" & "iss" claims of the ALB
+// token, returning the "sub" claim
+func (v *AlbVerifier) Verify(ctx context.Context, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed alb token")
+	}
+
+	var header albTokenHeader
+	if err := decodeAlbSegment(parts[0], &header); err != nil {
+		return "", errors.Wrap(err, "malformed alb token header")
+	}
+	if header.Alg != "ES256" {
+		return "", errors.Errorf("unexpected alb token algorithm %q", header.Alg)
+	}
+	if header.Signer != v.arn {
+		return "", errors.Errorf("unexpected alb token signer %q", header.Signer)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return "", err
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil || len(sig) != 64 {
+		return "", errors.New("malformed alb token signature")
+	}
+	// the signature is over the segments as received, padding included
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return "", errors.New("invalid alb token signature")
+	}
+
+	var claims struct {
+		Sub string   `json:"sub"`
+		Iss string   `json:"iss"`
+		Exp *float64 `json:"exp"`
+		Nbf *float64 `json:"nbf"`
+	}
+	if err := decodeAlbSegment(parts[1], &claims); err != nil {
+		return "", errors.Wrap(err, "malformed alb token payload")
+	}
+
+	now := time.Now()
+	switch {
+	case claims.Exp == nil:
+		return "", errors.New("alb token has no exp claim")
+	case now.After(time.Unix(int64(*claims.Exp), 0).Add(JwtClockSkew)):
+		return "", errors.New("alb token is expired")
+	case claims.Nbf != nil && now.Before(time.Unix(int64(*claims.Nbf), 0).Add(-JwtClockSkew)):
+		return "", errors.New("alb token is not valid yet")
+	case v.issuer != "" && claims.Iss != v.issuer:
+		return "", errors.Errorf("unexpected alb token issuer %q", claims.Iss)
+	case claims.Sub == "":
+		return "", errors.New("alb token has no sub claim")
+	}
+	return claims.Sub, nil
+}
+
+// verifyAlbToken verifies the ALB token with the shared AlbVerifier for the config,
+// returning the "sub" claim
+func verifyAlbToken(ctx context.Context, cfg JwtConfig, token string) (string, error) {
+	v, err := albVerifierFor(cfg)
+	if err != nil {
+		return "", err
+	}
+	sub, err := v.Verify(ctx, token)
+	if err != nil {
+		log.Debugf("invalid alb token: %v", err)
+		return "", err
+	}
+	return sub, nil
+}
+
+// key returns the public key for the kid, fetching it if not cached; failed fetches
+// are not retried for jwksMinRefreshInterval
+func (v *AlbVerifier) key(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	if !albKeyIdPattern.MatchString(kid) {
+		return nil, errors.Errorf("invalid alb token kid %q", kid)
+	}
+
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	failedAt, failed := v.failures[kid]
+	v.mu.RUnlock()
+	if ok {
+		return key, nil
+	}
+	if failed && time.Since(failedAt) < jwksMinRefreshInterval {
+		return nil, errors.Errorf("alb public key %v is unavailable", kid)
+	}
+
+	res, err, _ := v.fetches.Do(kid, func() (any, error) {
+		v.mu.Lock()
+		if time.Since(v.fetchedAt) < albMinKeyFetchInterval {
+			v.mu.Unlock()
+			return nil, errAlbKeyFetchLimited
+		}
+		v.fetchedAt = time.Now()
+		v.mu.Unlock()
+
+		// the fetch is shared, so it must not fail with the context of one caller
+		return v.fetchKey(context.WithoutCancel(ctx), kid)
+	})
+	if err == errAlbKeyFetchLimited {
+		// not a failure of the kid, so it may be fetched once the interval has passed
+		return nil, err
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err != nil {
+		if len(v.failures) >= albMaxKeyFailures {
+			clear(v.failures)
+		}
+		v.failures[kid] = time.Now()
+		log.Warnf("error fetching alb public key %v: %v", kid, err)
+		return nil, err
+	}
+	key = res.(*ecdsa.PublicKey)
+	v.keys[kid] = key
+	delete(v.failures, kid)
+	return key, nil
+}
+
+// fetchKey fetches & parses the PEM encoded public key for the kid
+func (v *AlbVerifier) fetchKey(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+	uri := v.keysUrl + "/" + kid
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching alb public key from %v", uri)
+	}
+	res, err := v.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching alb public key from %v", uri)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error fetching alb public key from %v: status %v", uri, res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, albKeyMaxSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading alb public key from %v", uri)
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, errors.Errorf("no pem encoded alb public key at %v", uri)
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing alb public key from %v", uri)
+	}
+	key, ok := pub.(*ecdsa.PublicKey)
+	if !ok || key.Curve != elliptic.P256() {
+		return nil, errors.Errorf("alb public key from %v is not an ecdsa P-256 key", uri)
+	}
+	return key, nil
+}
+
+// decodeAlbSegment decodes a token segment; ALB pads the base64url segments
+func decodeAlbSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(seg, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,247 @@
+package http
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testAlbArn = "arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/test/abc"
+	testAlbKid = "7c1f4b2e-9a3d-4e8f-b6a5-0d2c3e4f5a6b"
+)
+
+// testAlbKeyServer serves PEM encoded ALB public keys by kid.
+type testAlbKeyServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	keys    map[string][]byte
+	fetches int
+}
+
+func newTestAlbKeyServer(t *testing.T, keys map[string]*ecdsa.PrivateKey) *testAlbKeyServer {
+	t.Helper()
+
+	s := &testAlbKeyServer{keys: make(map[string][]byte)}
+	for kid, key := range keys {
+		der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+		if err != nil {
+			t.Fatalf("x509.MarshalPKIXPublicKey returned unexpected error: %v", err)
+		}
+		s.keys[kid] = pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		pem, ok := s.keys[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write(pem)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newTestAlbKey returns a P-256 private key.
+func newTestAlbKey(t *testing.T) *ecdsa.PrivateKey {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey returned unexpected error: %v", err)
+	}
+	return key
+}
+
+// signTestAlbToken returns an ALB style token, with padded base64url segments, signed
+// with the key.
+func signTestAlbToken(t *testing.T, key *ecdsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+
+	h := map[string]any{"alg": "ES256", "kid": testAlbKid, "signer": testAlbArn, "typ": "JWT"}
+	for k, v := range header {
+		h[k] = v
+	}
+	c := map[string]any{"sub": "sub-1", "exp": time.Now().Add(time.Hour).Unix()}
+	for k, v := range claims {
+		if v == nil {
+			delete(c, k)
+			continue
+		}
+		c[k] = v
+	}
+
+	hdata, _ := json.Marshal(h)
+	cdata, _ := json.Marshal(c)
+	input := base64.URLEncoding.EncodeToString(hdata) + "." + base64.URLEncoding.EncodeToString(cdata)
+
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("ecdsa.Sign returned unexpected error: %v", err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.URLEncoding.EncodeToString(sig)
+}
+
+func TestAlbVerifier_Verify(t *testing.T) {
+	key := newTestAlbKey(t)
+	srv := newTestAlbKeyServer(t, map[string]*ecdsa.PrivateKey{testAlbKid: key})
+	v, err := NewAlbVerifier(JwtConfig{AlbArn: testAlbArn, AlbKeysUrl: srv.URL})
+	if err != nil {
+		t.Fatalf("NewAlbVerifier returned unexpected error: %v", err)
+	}
+
+	forger := newTestAlbKey(t)
+	now := time.Now()
+	tests := []struct {
+		name  string
+		jwt   string
+		valid bool
+	}{
+		{"valid", signTestAlbToken(t, key, nil, nil), true},
+		{"forged", signTestAlbToken(t, forger, nil, nil), false},
+		{"other signer", signTestAlbToken(t, key, map[string]any{"signer": "arn:aws:elasticloadbalancing:us-east-1:1:loadbalancer/app/other/x"}, nil), false},
+		{"other algorithm", signTestAlbToken(t, key, map[string]any{"alg": "HS256"}, nil), false},
+		{"expired", signTestAlbToken(t, key, nil, map[string]any{"exp": now.Add(-time.Hour).Unix()}), false},
+		{"no exp", signTestAlbToken(t, key, nil, map[string]any{"exp": nil}), false},
+		{"not yet valid", signTestAlbToken(t, key, nil, map[string]any{"nbf": now.Add(time.Hour).Unix()}), false},
+		{"no sub", signTestAlbToken(t, key, nil, map[string]any{"sub": nil}), false},
+		{"path kid", signTestAlbToken(t, key, map[string]any{"kid": "../" + testAlbKid}, nil), false},
+		{"non uuid kid", signTestAlbToken(t, key, map[string]any{"kid": "k1"}, nil), false},
+		{"hs256 jwt", signTestJwt(t, "sub-1", nil), false},
+		{"malformed", "not-a-jwt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sub, err := v.Verify(context.Background(), tt.jwt)
+			if tt.valid && (err != nil || sub != "sub-1") {
+				t.Errorf("Verify = (%q, %v); want sub-1", sub, err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Verify returned nil error; want error")
+			}
+		})
+	}
+
+	if srv.fetches != 1 {
+		t.Errorf("key fetches = %v; want 1", srv.fetches)
+	}
+}
+
+func TestAlbVerifier_UnknownKid(t *testing.T) {
+	key := newTestAlbKey(t)
+	srv := newTestAlbKeyServer(t, map[string]*ecdsa.PrivateKey{testAlbKid: key})
+	v, err := NewAlbVerifier(JwtConfig{AlbArn: testAlbArn, AlbKeysUrl: srv.URL})
+	if err != nil {
+		t.Fatalf("NewAlbVerifier returned unexpected error: %v", err)
+	}
+
+	token := signTestAlbToken(t, key, map[string]any{"kid": "0e9d8c7b-6a5f-4e3d-9c2b-1a0f9e8d7c6b"}, nil)
+	for range 3 {
+		if _, err := v.Verify(context.Background(), token); err == nil {
+			t.Fatal("Verify returned nil error; want error")
+		}
+	}
+	if srv.fetches != 1 {
+		t.Errorf("key fetches = %v; want 1", srv.fetches)
+	}
+}
+
+func TestAlbVerifier_FetchLimited(t *testing.T) {
+	key := newTestAlbKey(t)
+	srv := newTestAlbKeyServer(t, map[string]*ecdsa.PrivateKey{testAlbKid: key})
+	v, err := NewAlbVerifier(JwtConfig{AlbArn: testAlbArn, AlbKeysUrl: srv.URL})
+	if err != nil {
+		t.Fatalf("NewAlbVerifier returned unexpected error: %v", err)
+	}
+	ctx := context.Background()
+
+	unknown := signTestAlbToken(t, key, map[string]any{"kid": "0e9d8c7b-6a5f-4e3d-9c2b-1a0f9e8d7c6b"}, nil)
+	if _, err := v.Verify(ctx, unknown); err == nil {
+		t.Fatal("Verify(unknown kid) returned nil error; want error")
+	}
+
+	valid := signTestAlbToken(t, key, nil, nil)
+	if _, err := v.Verify(ctx, valid); err == nil {
+		t.Error("Verify(within interval) returned nil error; want error")
+	}
+	if srv.fetches != 1 {
+		t.Errorf("key fetches = %v; want 1", srv.fetches)
+	}
+
+	v.mu.Lock()
+	v.fetchedAt = time.Now().Add(-albMinKeyFetchInterval)
+	v.mu.Unlock()
+	if _, err := v.Verify(ctx, valid); err != nil {
+		t.Errorf("Verify(after interval) returned unexpected error: %v", err)
+	}
+}
+
+func TestNewAlbVerifier_KeysUrl(t *testing.T) {
+	v, err := NewAlbVerifier(JwtConfig{AlbArn: testAlbArn})
+	if err != nil {
+		t.Fatalf("NewAlbVerifier returned unexpected error: %v", err)
+	}
+	if want := "https://public-keys.auth.elb.us-east-1.amazonaws.com"; v.keysUrl != want {
+		t.Errorf("keysUrl = %q; want %q", v.keysUrl, want)
+	}
+
+	if _, err := NewAlbVerifier(JwtConfig{}); err == nil {
+		t.Error("NewAlbVerifier(no arn) returned nil error; want error")
+	}
+	if _, err := NewAlbVerifier(JwtConfig{AlbArn: "not-an-arn"}); err == nil {
+		t.Error("NewAlbVerifier(invalid arn) returned nil error; want error")
+	}
+}
+
+func TestAwsalbPrincipal_ValidateAlbSignature(t *testing.T) {
+	key := newTestAlbKey(t)
+	srv := newTestAlbKeyServer(t, map[string]*ecdsa.PrivateKey{testAlbKid: key})
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+	ap.Config.JwtConfig.ValidateAlbSignature = true
+	ap.Config.JwtConfig.AlbArn = testAlbArn
+	ap.Config.JwtConfig.AlbKeysUrl = srv.URL
+	ctx := context.Background()
+
+	request := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Amzn-Oidc-Data", token)
+		return req
+	}
+
+	valid := signTestAlbToken(t, key, nil, map[string]any{"login": "jane.doe@example.com"})
+	if pr, err := awsalbPrincipal(ctx, ap, request(valid), "sub-1", cloader, nil); err != nil || pr.Login != "jane.doe@example.com" {
+		t.Fatalf("awsalbPrincipal = (%v, %v); want jane.doe@example.com", pr, err)
+	}
+
+	forged := signTestAlbToken(t, newTestAlbKey(t), nil, nil)
+	if _, err := awsalbPrincipal(ctx, ap, request(forged), "sub-1", cloader, nil); err == nil {
+		t.Error("awsalbPrincipal(forged, cached) returned nil error; want error")
+	}
+	if _, err := awsalbPrincipal(ctx, ap, httptest.NewRequest(http.MethodGet, "/", nil), "sub-1", cloader, nil); err == nil {
+		t.Error("awsalbPrincipal(no token, cached) returned nil error; want error")
+	}
+	if _, err := awsalbPrincipal(ctx, ap, request(valid), "sub-2", cloader, nil); err == nil || !strings.Contains(err.Error(), "sub-1") {
+		t.Errorf("awsalbPrincipal(other sub) error = %v; want the incorrect sub-1 claim", err)
+	}
+}
@@ -0,0 +1,57 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// bearerAuthScheme is the Authorization header scheme of bearer tokens
+const bearerAuthScheme = "Bearer"
+
+// bearerToken returns the token of an "Authorization: Bearer <token>" request header
+func bearerToken(r *http.Request) (string, error) {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
+		return "", errors.New("no authorization header found")
+	}
+	scheme, token, ok := strings.Cut(auth, " ")
+	if !ok || !strings.EqualFold(scheme, bearerAuthScheme) {
+		return "", errors.New("authorization header is not a bearer token")
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return "", errors.New("empty bearer token")
+	}
+	return token, nil
+}
+
+// bearerPrincipal returns the principal from the claims of the request bearer token.
+// Unlike the ALB id token, a bearer token comes straight from the client, so it is
+// always verified against the JwtConfig keys, whether ValidateJwtSignature is set or not
+func bearerPrincipal(ctx context.Context, ap AuthPolicy, r *http.Request) (*Principal, error) {
+	token, err := bearerToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := ap.Config
+	cfg.JwtConfig.ValidateJwtSignature = true
+	cfg.JwtConfig.ValidateAlbSignature = false
+
+	jloader := JwtClaimsPrincipalLoader{
+		config: cfg,
+		jwt:    token,
+	}
+	pr, err := jloader.FetchPrincipal(ctx, "")
+	if err != nil {
+		return nil, errors.New("invalid bearer token")
+	}
+	if pr.Roles == nil {
+		pr.Roles = Set{}
+	}
+
+	pr.RawToken = token
+	return pr, nil
+}
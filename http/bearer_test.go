@@ -0,0 +1,145 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// testBearerPolicy returns an auth policy verifying bearer tokens against the jwks uri,
+// allowing members of the "admins" group & denying everyone else.
+func testBearerPolicy(jwksUri string) AuthPolicy {
+	return AuthPolicy{
+		Config: Config{
+			JwtConfig: JwtConfig{JwksUri: jwksUri},
+			Roles: RolesConfig{
+				AdminRoles:  RoleSetFrom("admin"),
+				Definitions: map[string]Set{"admin": RoleSetFrom("admins")},
+			},
+		},
+		AuthrPolicies: Policies{
+			{Name: "allow_admins", HttpMethod: AllMethods, HttpPath: AllPaths, Effect: PolicyEffectAllow, Subjects: RoleSetFrom("admin")},
+			{Name: "deny_all", HttpMethod: AllMethods, HttpPath: AllPaths, Effect: PolicyEffectDeny, Subjects: RoleSetFrom(Everyone)},
+		},
+	}
+}
+
+// bearerTokens returns a valid admin token, a valid non-admin token & a forged admin
+// token for the key.
+func bearerTokens(t *testing.T, key jwk.Key) (admin, user, forged string) {
+	t.Helper()
+
+	withGroups := func(groups ...any) func(*jwt.Builder) *jwt.Builder {
+		return func(b *jwt.Builder) *jwt.Builder { return b.Claim("groups", groups) }
+	}
+	admin = signRS256(t, testToken(t, withGroups("admins")), key)
+	user = signRS256(t, testToken(t, withGroups("users")), key)
+	forged = signRS256(t, testToken(t, withGroups("admins")), newTestJwk(t, "k1"))
+	return admin, user, forged
+}
+
+func TestBearerToken(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+		valid  bool
+	}{
+		{"Bearer abc.def.ghi", "abc.def.ghi", true},
+		{"bearer abc.def.ghi", "abc.def.ghi", true},
+		{"", "", false},
+		{"Basic dXNlcjpwYXNz", "", false},
+		{"Bearer", "", false},
+		{"Bearer  ", "", false},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tt.header != "" {
+			req.Header.Set("Authorization", tt.header)
+		}
+		got, err := bearerToken(req)
+		if tt.valid && (err != nil || got != tt.want) {
+			t.Errorf("bearerToken(%q) = (%q, %v); want %q", tt.header, got, err, tt.want)
+		}
+		if !tt.valid && err == nil {
+			t.Errorf("bearerToken(%q) returned nil error; want error", tt.header)
+		}
+	}
+}
+
+func TestBearerAuthorizeGinHandler(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	srv := newTestJwksServer(t, key)
+	admin, user, forged := bearerTokens(t, key)
+
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(BearerAuthorizeGinHandler(testBearerPolicy(srv.URL))...)
+	engine.GET("/", func(c *gin.Context) {
+		pr := c.MustGet(ContextKeyPrincipal).(Principal)
+		c.String(http.StatusOK, pr.Id)
+	})
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(admin); rec.Code != http.StatusOK || rec.Body.String() != "sub-1" {
+		t.Errorf("admin token = (%d, %q); want (%d, %q)", rec.Code, rec.Body.String(), http.StatusOK, "sub-1")
+	}
+	if rec := serve(user); rec.Code != http.StatusUnauthorized {
+		t.Errorf("user token status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	for name, token := range map[string]string{"forged": forged, "missing": ""} {
+		rec := serve(token)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("%v token status = %d; want %d", name, rec.Code, http.StatusUnauthorized)
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != "Bearer" {
+			t.Errorf("%v token WWW-Authenticate = %q; want %q", name, got, "Bearer")
+		}
+	}
+}
+
+func TestBearerAuthorizeHttpMiddlewares(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	srv := newTestJwksServer(t, key)
+	admin, user, forged := bearerTokens(t, key)
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v, err := getValue(r.Context(), ContextKeyPrincipal)
+		if err != nil {
+			t.Errorf("getValue returned unexpected error: %v", err)
+			return
+		}
+		_, _ = w.Write([]byte(v.(Principal).Id))
+	}), BearerAuthorizeHttpMiddlewares(testBearerPolicy(srv.URL))...)
+
+	serve := func(token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve(admin); rec.Code != http.StatusOK || rec.Body.String() != "sub-1" {
+		t.Errorf("admin token = (%d, %q); want (%d, %q)", rec.Code, rec.Body.String(), http.StatusOK, "sub-1")
+	}
+	if rec := serve(user); rec.Code != http.StatusUnauthorized {
+		t.Errorf("user token status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+	if rec := serve(forged); rec.Code != http.StatusUnauthorized {
+		t.Errorf("forged token status = %d; want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
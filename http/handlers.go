@@ -3,8 +3,10 @@ package http
 import (
 	"context"
 	"net/http"
+	"slices"
 
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
 )
 
 // contextKey is an unexported type for context keys in this package,
@@ -58,6 +60,18 @@ func getValue(ctx context.Context, key string) (any, error) {
 	return val, nil
 }
 
+// compilePolicies compiles a copy of the auth policy items once, when an auth handler is
+// created; on error the handler fails closed & denies every request
+func compilePolicies(ap *AuthPolicy) error {
+	policies := slices.Clone(ap.AuthrPolicies)
+	if err := policies.Compile(); err != nil {
+		log.Errorf("invalid auth policy, all requests are denied: %v", err)
+		return err
+	}
+	ap.AuthrPolicies = policies
+	return nil
+}
+
 // upgradeRequestContext upgrades the http.Request with a new context that contains
 // the value map
 func upgradeRequestContext(r *http.Request) *http.Request {
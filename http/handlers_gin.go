@@ -3,7 +3,6 @@ package http
 import (
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/TouchBistro/gotham/cache"
 	"github.com/gin-gonic/gin"
@@ -75,6 +74,35 @@ func AwsalbAuthorizeGinHandler(pol AuthPolicy, loader PrincipalLoader) []gin.Han
 	return funcs
 }
 
+// BearerAuthorizeGinHandler returns an array of gin handlers as defined by the supplied auth
+// policy, like AwsalbAuthorizeGinHandler, for requests that authenticate with an
+// "Authorization: Bearer <jwt>" header, e.g. service-to-service calls & CLI clients. The
+// token is verified against the jwks of the policy config & the principal is built from
+// its claims
+func BearerAuthorizeGinHandler(pol AuthPolicy) []gin.HandlerFunc {
+	funcs := make([]gin.HandlerFunc, 0)
+	funcs = append(funcs, actionProcessingGinHandler(pol.PreActions)...)
+	funcs = append(funcs, principalAuthGinHandler(pol, bearerAuthScheme, func(r *http.Request) (*Principal, error) {
+		return bearerPrincipal(r.Context(), pol, r)
+	}))
+	funcs = append(funcs, actionProcessingGinHandler(pol.PostActions)...)
+	return funcs
+}
+
+// ApiKeyAuthorizeGinHandler returns an array of gin handlers as defined by the supplied auth
+// policy, like AwsalbAuthorizeGinHandler, for requests that authenticate with an API key in
+// the X-Api-Key header, e.g. automation that can't do OIDC. The principal of the key is
+// fetched from the supplied key store
+func ApiKeyAuthorizeGinHandler(pol AuthPolicy, store KeyStore) []gin.HandlerFunc {
+	funcs := make([]gin.HandlerFunc, 0)
+	funcs = append(funcs, actionProcessingGinHandler(pol.PreActions)...)
+	funcs = append(funcs, principalAuthGinHandler(pol, "", func(r *http.Request) (*Principal, error) {
+		return apiKeyPrincipal(r.Context(), pol, r, store)
+	}))
+	funcs = append(funcs, actionProcessingGinHandler(pol.PostActions)...)
+	return funcs
+}
+
 // helper function
 
 // actionProcessingGinHandler creates gin middleware functions for the supplied
@@ -91,10 +119,16 @@ func actionProcessingGinHandler(actions []PolicyAction) []gin.HandlerFunc {
 // & the JWT-encoded oidc user claims from the supplied http request header & decides
 // if the request must be processed further or aborted
 func awsalbAuthGinHandler(ap AuthPolicy, loader PrincipalLoader) gin.HandlerFunc {
+	compileErr := compilePolicies(&ap)
 	return func(c *gin.Context) {
 
 		log.Debugf("processing auth for %v %v", c.Request.Method, c.Request.URL.Path)
 
+		if compileErr != nil {
+			abortRespondAndLogErrorGin(c, http.StatusInternalServerError, "invalid auth policy: "+compileErr.Error())
+			return
+		}
+
 		ctx := c.Request.Context()
 
 		var err error
@@ -108,56 +142,57 @@ func awsalbAuthGinHandler(ap AuthPolicy, loader PrincipalLoader) gin.HandlerFunc
 		// fetch cached principal
 		var pr *Principal
 		prefix := "principal"
-		cache, err := cache.Initialize()
+		mc, err := cache.Initialize()
 		if err != nil {
-			abortRespondAndLogErrorGin(c, http.StatusUnauthorized, "error initialzing cache")
+			log.Warnf("error initializing cache, principals are not cached: %v", err)
+			mc = new(cache.NilCache)
+		}
+
+		cloader := CachePrincipalLoader{prefix, mc}
+		if pr, err = awsalbPrincipal(ctx, ap, c.Request, sub, cloader, loader); err != nil {
+			abortRespondAndLogErrorGin(c, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		cloader := CachePrincipalLoader{prefix, cache}
-		if pr, err = cloader.FetchPrincipal(ctx, sub); err != nil {
+		pol, err := ap.AuthrPolicies.Match(*pr, *c.Request)
+		if err != nil {
+			abortRespondAndLogErrorGin(c, http.StatusUnauthorized, err.Error())
+			return
+		}
 
-			var oidcDataHeaderVal string
-			if oidcDataHeaderVal, err = httpRequestHeaderValue(c.Request, ap.Config.JwtConfig.IdTokenHeader, 0); err != nil {
-				abortRespondAndLogErrorGin(c, http.StatusUnauthorized, "no id token value found from header")
-				return
-			}
+		if pol.Effect != PolicyEffectAllow {
+			msg := fmt.Sprintf("access to %v %v to %v denied by auth policy", c.Request.Method, c.Request.URL, pr.Login)
+			abortRespondAndLogErrorGin(c, http.StatusUnauthorized, msg)
+			return
+		}
 
-			jloader := JwtClaimsPrincipalLoader{
-				config: ap.Config,
-				jwt:    oidcDataHeaderVal,
-			}
-			if pr, err = jloader.FetchPrincipal(ctx, sub); err != nil {
-				abortRespondAndLogErrorGin(c, http.StatusUnauthorized, "error loading principal from cliams in JWT")
-				return
-			}
+		// set principal to context, all set go to next handler...
+		c.Set(ContextKeyPrincipal, *pr)  // set pr for later use
+		c.Set(ContextKeyAlias, pr.Alias) // set alias for each fetch
+	}
+}
 
-			// if login claim isn't there, we need to fill/sync it up from the supplied principal loader
-			// this is suppose to fetch a Principal from a system of record like a DB or some other application
-			// specific store
-			if pr.Login == "" {
-				var prFromDb *Principal
-				if prFromDb, err = loader.FetchPrincipal(ctx, sub); err != nil {
-					// if prFromDb, err = loadPrincipalFromDb(ctx, ap.Config, sub); err != nil {
-					abortRespondAndLogErrorGin(c, http.StatusUnauthorized, fmt.Sprintf("principal JWT token didn't contain enough claims, but error fetching principal auth info from database/n%v", err.Error()))
-					return
-				}
-
-				// here we fill out roles from the gruops that are policy def specific
-				prFromDb.Roles, prFromDb.IsSuperAdmin, prFromDb.IsAdmin = rolesFromGroups(ap.Config, prFromDb.Groups)
-
-				// merge the principal from cliams with the principal from storage
-				pr.Merge(*prFromDb)                           // merge with the principal obj from database
-				pr.Expiry = time.Now().Add(119 * time.Second) // force 2m expiry after merge to eff ignore setting expiry from the database record
-			}
+// principalAuthGinHandler returns a gin middleware that uses the supplied auth policy & the
+// principal authenticated from the request & decides if the request must be processed further
+// or aborted; failed authentications are challenged with the scheme, if not empty
+func principalAuthGinHandler(ap AuthPolicy, challenge string, authenticate func(*http.Request) (*Principal, error)) gin.HandlerFunc {
+	compileErr := compilePolicies(&ap)
+	return func(c *gin.Context) {
+
+		log.Debugf("processing auth for %v %v", c.Request.Method, c.Request.URL.Path)
 
-			// put raw token in the principal obj context
-			pr.RawToken = oidcDataHeaderVal
+		if compileErr != nil {
+			abortRespondAndLogErrorGin(c, http.StatusInternalServerError, "invalid auth policy: "+compileErr.Error())
+			return
+		}
 
-			// before caching, we force the expiry in principal to 2 min
-			if err = cloader.Persist(ctx, *pr); err != nil {
-				log.Warnf("error caching principal for external id %v", sub)
+		pr, err := authenticate(c.Request)
+		if err != nil {
+			if challenge != "" {
+				c.Header("WWW-Authenticate", challenge)
 			}
+			abortRespondAndLogErrorGin(c, http.StatusUnauthorized, err.Error())
+			return
 		}
 
 		pol, err := ap.AuthrPolicies.Match(*pr, *c.Request)
@@ -173,8 +208,8 @@ func awsalbAuthGinHandler(ap AuthPolicy, loader PrincipalLoader) gin.HandlerFunc
 		}
 
 		// set principal to context, all set go to next handler...
-		c.Set(ContextKeyPrincipal, *pr)  // set pr for later use
-		c.Set(ContextKeyAlias, pr.Alias) // set alias for each fetch
+		c.Set(ContextKeyPrincipal, *pr)
+		c.Set(ContextKeyAlias, pr.Alias)
 	}
 }
 
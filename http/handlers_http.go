@@ -4,7 +4,6 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"time"
 
 	"github.com/TouchBistro/gotham/cache"
 	log "github.com/sirupsen/logrus"
@@ -107,6 +106,35 @@ func AwsalbAuthorizeHttpMiddlewares(pol AuthPolicy, loader PrincipalLoader) []Mi
 	return middlewares
 }
 
+// BearerAuthorizeHttpMiddlewares returns an array of http middlewares as defined by the supplied
+// auth policy, like AwsalbAuthorizeHttpMiddlewares, for requests that authenticate with an
+// "Authorization: Bearer <jwt>" header, e.g. service-to-service calls & CLI clients. The
+// token is verified against the jwks of the policy config & the principal is built from
+// its claims
+func BearerAuthorizeHttpMiddlewares(pol AuthPolicy) []Middleware {
+	middlewares := make([]Middleware, 0)
+	middlewares = append(middlewares, actionProcessingHttpMiddlewares(pol.PreActions)...)
+	middlewares = append(middlewares, principalAuthHttpMiddleware(pol, bearerAuthScheme, func(r *http.Request) (*Principal, error) {
+		return bearerPrincipal(r.Context(), pol, r)
+	}))
+	middlewares = append(middlewares, actionProcessingHttpMiddlewares(pol.PostActions)...)
+	return middlewares
+}
+
+// ApiKeyAuthorizeHttpMiddlewares returns an array of http middlewares as defined by the supplied
+// auth policy, like AwsalbAuthorizeHttpMiddlewares, for requests that authenticate with an API
+// key in the X-Api-Key header, e.g. automation that can't do OIDC. The principal of the key is
+// fetched from the supplied key store
+func ApiKeyAuthorizeHttpMiddlewares(pol AuthPolicy, store KeyStore) []Middleware {
+	middlewares := make([]Middleware, 0)
+	middlewares = append(middlewares, actionProcessingHttpMiddlewares(pol.PreActions)...)
+	middlewares = append(middlewares, principalAuthHttpMiddleware(pol, "", func(r *http.Request) (*Principal, error) {
+		return apiKeyPrincipal(r.Context(), pol, r, store)
+	}))
+	middlewares = append(middlewares, actionProcessingHttpMiddlewares(pol.PostActions)...)
+	return middlewares
+}
+
 // helper function
 
 // actionProcessingHttpMiddlewares creates net/http middleware functions for the supplied
@@ -123,11 +151,17 @@ func actionProcessingHttpMiddlewares(actions []PolicyAction) []Middleware {
 // & the JWT-encoded oidc user claims from the supplied http request header & decides
 // if the request must be processed further or aborted
 func awsalbAuthHttpMiddleware(ap AuthPolicy, loader PrincipalLoader) Middleware {
+	compileErr := compilePolicies(&ap)
 	return MiddlewareFunc(func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 			log.Debugf("processing auth for %v %v", r.Method, r.URL.Path)
 
+			if compileErr != nil {
+				abortRespondAndLogErrorHttp(w, r, http.StatusInternalServerError, "invalid auth policy: "+compileErr.Error())
+				return
+			}
+
 			// ensure the request is upgraded with a value map
 			r = upgradeRequestContext(r)
 			ctx := r.Context()
@@ -143,59 +177,73 @@ func awsalbAuthHttpMiddleware(ap AuthPolicy, loader PrincipalLoader) Middleware
 			// fetch cached principal
 			var pr *Principal
 			prefix := "principal"
-			cache, err := cache.Initialize()
+			mc, err := cache.Initialize()
 			if err != nil {
-				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, "error initialzing cache")
+				log.Warnf("error initializing cache, principals are not cached: %v", err)
+				mc = new(cache.NilCache)
+			}
+
+			cloader := CachePrincipalLoader{prefix, mc}
+			if pr, err = awsalbPrincipal(ctx, ap, r, sub, cloader, loader); err != nil {
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, err.Error())
 				return
 			}
 
-			cloader := CachePrincipalLoader{prefix, cache}
-			if pr, err = cloader.FetchPrincipal(ctx, sub); err != nil {
+			pol, err := ap.AuthrPolicies.Match(*pr, *r)
+			if err != nil {
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
 
-				var oidcDataHeaderVal string
-				if oidcDataHeaderVal, err = httpRequestHeaderValue(r, ap.Config.JwtConfig.IdTokenHeader, 0); err != nil {
-					abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, "no id token value found from header")
-					return
-				}
+			if pol.Effect != PolicyEffectAllow {
+				msg := fmt.Sprintf("access to %v %v to %v denied by auth policy", r.Method, r.URL, pr.Login)
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, msg)
+				return
+			}
 
-				jloader := JwtClaimsPrincipalLoader{
-					config: ap.Config,
-					jwt:    oidcDataHeaderVal,
-				}
-				if pr, err = jloader.FetchPrincipal(ctx, sub); err != nil {
-					abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, "error loading principal from cliams in JWT")
-					return
-				}
+			// set principal to context, all set go to next handler...
+			if err = setValue(ctx, ContextKeyPrincipal, *pr); err != nil {
+				msg := fmt.Sprintf("access to %v %v to %v denied, error saving principal in context due to %v", r.Method, r.URL, pr.Login, err.Error())
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, msg)
+				return
+			}
+			if err = setValue(ctx, ContextKeyAlias, pr.Alias); err != nil {
+				msg := fmt.Sprintf("access to %v %v to %v denied, error saving principal in context due to %v", r.Method, r.URL, pr.Login, err.Error())
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, msg)
+				return
+			}
 
-				// if login claim isn't there, we need to fill/sync it up from the supplied principal loader
-				// this is suppose to fetch a Principal from a system of record like a DB or some other application
-				// specific store
-				if pr.Login == "" {
-					// var prFromDb *Principal
-					prFromDb := pr // init with the item from cache
-					if loader != nil {
-						if prFromDb, err = loader.FetchPrincipal(ctx, sub); err != nil {
-							// if prFromDb, err = loadPrincipalFromDb(ctx, ap.Config, sub); err != nil {
-							abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, fmt.Sprintf("principal JWT token didn't contain enough claims, but error fetching principal auth info from database/n%v", err.Error()))
-							return
-						}
-					}
-
-					// here we fill out roles from the gruops that are policy def specific
-					prFromDb.Roles, prFromDb.IsSuperAdmin, prFromDb.IsAdmin = rolesFromGroups(ap.Config, prFromDb.Groups)
-
-					// merge the principal from cliams with the principal from storage
-					pr.Merge(*prFromDb)                           // merge with the principal obj from database
-					pr.Expiry = time.Now().Add(119 * time.Second) // force 2m expiry after merge to eff ignore setting expiry from the database record
-				}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
 
-				// put raw token in the principal obj context
-				pr.RawToken = oidcDataHeaderVal
+// principalAuthHttpMiddleware returns an net/http middleware that uses the supplied auth policy
+// & the principal authenticated from the request & decides if the request must be processed
+// further or aborted; failed authentications are challenged with the scheme, if not empty
+func principalAuthHttpMiddleware(ap AuthPolicy, challenge string, authenticate func(*http.Request) (*Principal, error)) Middleware {
+	compileErr := compilePolicies(&ap)
+	return MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			log.Debugf("processing auth for %v %v", r.Method, r.URL.Path)
+
+			if compileErr != nil {
+				abortRespondAndLogErrorHttp(w, r, http.StatusInternalServerError, "invalid auth policy: "+compileErr.Error())
+				return
+			}
 
-				// before caching, we force the expiry in principal to 2 min
-				if err = cloader.Persist(ctx, *pr); err != nil {
-					log.Warnf("error caching principal for external id %v", sub)
+			// ensure the request is upgraded with a value map
+			r = upgradeRequestContext(r)
+			ctx := r.Context()
+
+			pr, err := authenticate(r)
+			if err != nil {
+				if challenge != "" {
+					w.Header().Set("WWW-Authenticate", challenge)
 				}
+				abortRespondAndLogErrorHttp(w, r, http.StatusUnauthorized, err.Error())
+				return
 			}
 
 			pol, err := ap.AuthrPolicies.Match(*pr, *r)
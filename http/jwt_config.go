@@ -1,7 +1,10 @@
 package http
 
 // JwtConfig contains all JWT related values, e.g request headers to read ID & Access tokens, the
-// header that holds the "sub" claim & Jwt validation related parameters
+// header that holds the "sub" claim & Jwt validation related parameters. When ValidateJwtSignature
+// is set, tokens are verified with the keys in Jwks (JWKs or JWK sets, as JSON) or else the JWK
+// set served at JwksUri; the "iss" & "aud" claims are checked if Issuer & Audience are set.
+// When ValidateAlbSignature is set, the id token is verified as an AWS ALB token, see AlbVerifier
 type JwtConfig struct {
 	IdTokenHeader        string   `json:"idTokenHeader"`
 	AccessTokenHeader    string   `json:"accessTokenHeader"`
@@ -9,4 +12,9 @@ type JwtConfig struct {
 	ValidateJwtSignature bool     `json:"validateJwtSignature"`
 	Jwks                 []string `json:"jwks"`
 	JwksUri              string   `json:"jwksUri"`
+	Issuer               string   `json:"issuer"`   // expected "iss" claim, not checked if empty
+	Audience             string   `json:"audience"` // expected "aud" claim, not checked if empty
+	ValidateAlbSignature bool     `json:"validateAlbSignature"`
+	AlbArn               string   `json:"albArn"`     // expected "signer" header of ALB tokens
+	AlbKeysUrl           string   `json:"albKeysUrl"` // base url of the ALB public keys, by default the endpoint of the AlbArn region
 }
@@ -0,0 +1,272 @@
+package http
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jws"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	JwtClockSkew               = 30 * time.Second // tolerated clock skew when checking "exp" & "nbf"
+	DefaultJwksRefreshInterval = time.Hour        // interval after which a fetched JWK set is refreshed
+
+	// jwksMinRefreshInterval limits refreshes for unknown key ids & after failed fetches,
+	// so forged tokens can't flood the JWKS endpoint
+	jwksMinRefreshInterval = time.Minute
+	jwksFetchTimeout       = 10 * time.Second
+	jwksMaxSize            = 1 << 20
+)
+
+// jwtVerifiers holds a JwtVerifier per JWT config, so fetched JWK sets are shared
+// across requests
+var jwtVerifiers sync.Map
+
+// JwtVerifier verifies the signature of JWTs against a JWK set & validates the
+// "exp", "nbf", "iss" & "aud" claims
+type JwtVerifier struct {
+	keys     jwksSource
+	issuer   string
+	audience string
+}
+
+// NewJwtVerifier returns a JwtVerifier for the keys in cfg.Jwks or, if none are set,
+// the JWK set fetched from cfg.JwksUri
+func NewJwtVerifier(cfg JwtConfig) (*JwtVerifier, error) {
+	v := &JwtVerifier{issuer: cfg.Issuer, audience: cfg.Audience}
+
+	switch {
+	case len(cfg.Jwks) > 0:
+		set := jwk.NewSet()
+		for _, s := range cfg.Jwks {
+			keys, err := jwk.ParseString(s)
+			if err != nil {
+				return nil, errors.Wrap(err, "error parsing jwks")
+			}
+			for i := 0; i < keys.Len(); i++ {
+				key, _ := keys.Key(i)
+				if err := set.AddKey(key); err != nil {
+					return nil, errors.Wrap(err, "error parsing jwks")
+				}
+			}
+		}
+		public, err := jwk.PublicSetOf(set)
+		if err != nil {
+			return nil, errors.Wrap(err, "error parsing jwks")
+		}
+		v.keys = staticJwks{public}
+	case cfg.JwksUri != "":
+		v.keys = &remoteJwks{
+			uri:        cfg.JwksUri,
+			client:     &http.Client{Timeout: jwksFetchTimeout},
+			refresh:    DefaultJwksRefreshInterval,
+			minRefresh: jwksMinRefreshInterval,
+		}
+	default:
+		return nil, errors.New("jwt signature validation requires jwks or a jwksUri")
+	}
+	return v, nil
+}
+
+// jwtVerifierFor returns the shared JwtVerifier for the config
+func jwtVerifierFor(cfg JwtConfig) (*JwtVerifier, error) {
+	key := strings.Join([]string{cfg.JwksUri, cfg.Issuer, cfg.Audience, strings.Join(cfg.Jwks, "\n")}, "\n")
+	if v, ok := jwtVerifiers.Load(key); ok {
+		return v.(*JwtVerifier), nil
+	}
+
+	v, err := NewJwtVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	actual, _ := jwtVerifiers.LoadOrStore(key, v)
+	return actual.(*JwtVerifier), nil
+}
+
+// verifyJwt verifies the jwt with the shared verifier for the config
+func verifyJwt(ctx context.Context, cfg JwtConfig, jwtStr string) (jwt.Token, error) {
+	v, err := jwtVerifierFor(cfg)
+	if err != nil {
+		return nil, err
+	}
+	token, err := v.Verify(ctx, jwtStr)
+	if err != nil {
+		log.Debugf("invalid jwt: %v", err)
+		return nil, err
+	}
+	return token, nil
+}
+
+// Verify returns the parsed token if its signature & claims are valid; tokens without
+// an "exp" claim are rejected
+func (v *JwtVerifier) Verify(ctx context.Context, jwtStr string) (jwt.Token, error) {
+	msg, err := jws.Parse([]byte(jwtStr))
+	if err != nil {
+		return nil, errors.Wrap(err, "error parsing jwt")
+	}
+	if len(msg.Signatures()) != 1 {
+		return nil, errors.Errorf("jwt has %v signatures, want 1", len(msg.Signatures()))
+	}
+
+	set, err := v.keys.keySet(ctx, msg.Signatures()[0].ProtectedHeaders().KeyID())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []jwt.ParseOption{
+		// the algorithm is inferred from the key type, never taken from the token
+		jwt.WithKeySet(set, jws.WithInferAlgorithmFromKey(true), jws.WithRequireKid(false)),
+		jwt.WithValidate(true),
+		jwt.WithAcceptableSkew(JwtClockSkew),
+		jwt.WithRequiredClaim(jwt.ExpirationKey),
+	}
+	if v.issuer != "" {
+		opts = append(opts, jwt.WithIssuer(v.issuer))
+	}
+	if v.audience != "" {
+		opts = append(opts, jwt.WithAudience(v.audience))
+	}
+
+	token, err := jwt.Parse([]byte(jwtStr), opts...)
+	if err != nil {
+		return nil, errors.Wrap(err, "error verifying jwt")
+	}
+	return token, nil
+}
+
+// jwksSource supplies the JWK set to verify a token signed with the key id
+type jwksSource interface {
+	keySet(ctx context.Context, kid string) (jwk.Set, error)
+}
+
+// staticJwks is a jwksSource for configured keys
+type staticJwks struct {
+	set jwk.Set
+}
+
+func (s staticJwks) keySet(ctx context.Context, kid string) (jwk.Set, error) {
+	return s.set, nil
+}
+
+// remoteJwks is a jwksSource that fetches the JWK set from a uri & refreshes it
+// periodically, or when a token is signed with an unknown key id, i.e. a rotated key.
+// Fetches are shared & run outside the lock; while a refresh is in flight the cached
+// keys are used for known key ids. If a refresh fails, the previously fetched keys
+// are used
+type remoteJwks struct {
+	uri        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+
+	mu          sync.Mutex
+	set         jwk.Set
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	refreshing  bool
+	fetches     singleflight.Group
+}
+
+func (r *remoteJwks) keySet(ctx context.Context, kid string) (jwk.Set, error) {
+	r.mu.Lock()
+	set := r.set
+	if set != nil && (!r.stale(kid) || (!r.refreshing && time.Since(r.attemptedAt) < r.minRefresh)) {
+		r.mu.Unlock()
+		return set, nil
+	}
+	if !r.refreshing {
+		r.attemptedAt, r.refreshing = time.Now(), true
+	}
+	r.mu.Unlock()
+
+	res := r.fetches.DoChan(r.uri, func() (any, error) {
+		// the fetch is shared, so it must not fail with the context of one caller
+		return r.refreshSet(context.WithoutCancel(ctx))
+	})
+	if set != nil {
+		if _, ok := set.LookupKeyID(kid); ok || kid == "" {
+			// a periodic refresh, the cached keys are still valid
+			return set, nil
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil, errors.Wrapf(ctx.Err(), "error fetching jwks from %v", r.uri)
+	case out := <-res:
+		if out.Err != nil {
+			return nil, out.Err
+		}
+		return out.Val.(jwk.Set), nil
+	}
+}
+
+// refreshSet fetches the JWK set & caches it, or returns the cached keys if the fetch
+// fails
+func (r *remoteJwks) refreshSet(ctx context.Context) (jwk.Set, error) {
+	startedAt := time.Now()
+	set, err := r.fetch(ctx)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.refreshing = false
+	if err != nil {
+		if r.set == nil {
+			return nil, err
+		}
+		log.Warnf("error refreshing jwks from %v, using the cached keys: %v", r.uri, err)
+		return r.set, nil
+	}
+
+	log.Debugf("fetched %v keys from jwks %v", set.Len(), r.uri)
+	r.set, r.fetchedAt = set, startedAt
+	return r.set, nil
+}
+
+// stale returns true if the keys are due for a refresh or the key id is unknown; the
+// caller must hold the lock
+func (r *remoteJwks) stale(kid string) bool {
+	if time.Since(r.fetchedAt) >= r.refresh {
+		return true
+	}
+	if kid == "" {
+		return false
+	}
+	_, ok := r.set.LookupKeyID(kid)
+	return !ok
+}
+
+// fetch fetches & parses the JWK set
+func (r *remoteJwks) fetch(ctx context.Context) (jwk.Set, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.uri, nil)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching jwks from %v", r.uri)
+	}
+	res, err := r.client.Do(req)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error fetching jwks from %v", r.uri)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("error fetching jwks from %v: status %v", r.uri, res.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(res.Body, jwksMaxSize))
+	if err != nil {
+		return nil, errors.Wrapf(err, "error reading jwks from %v", r.uri)
+	}
+	set, err := jwk.Parse(body)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error parsing jwks from %v", r.uri)
+	}
+	return jwk.PublicSetOf(set)
+}
@@ -0,0 +1,336 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lestrrat-go/jwx/v2/jwa"
+	"github.com/lestrrat-go/jwx/v2/jwk"
+	"github.com/lestrrat-go/jwx/v2/jwt"
+)
+
+// newTestJwk returns an RSA private JWK with the supplied key id.
+func newTestJwk(t *testing.T, kid string) jwk.Key {
+	t.Helper()
+
+	raw, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey returned unexpected error: %v", err)
+	}
+	key, err := jwk.FromRaw(raw)
+	if err != nil {
+		t.Fatalf("jwk.FromRaw returned unexpected error: %v", err)
+	}
+	_ = key.Set(jwk.KeyIDKey, kid)
+	_ = key.Set(jwk.AlgorithmKey, jwa.RS256)
+	return key
+}
+
+// publicJwks returns the JSON JWK set of the public keys.
+func publicJwks(t *testing.T, keys ...jwk.Key) []byte {
+	t.Helper()
+
+	set := jwk.NewSet()
+	for _, key := range keys {
+		_ = set.AddKey(key)
+	}
+	public, err := jwk.PublicSetOf(set)
+	if err != nil {
+		t.Fatalf("jwk.PublicSetOf returned unexpected error: %v", err)
+	}
+	data, _ := json.Marshal(public)
+	return data
+}
+
+// testJwksServer is an httptest JWKS endpoint whose keys can be rotated.
+type testJwksServer struct {
+	*httptest.Server
+	mu      sync.Mutex
+	jwks    []byte
+	fetches int
+}
+
+func newTestJwksServer(t *testing.T, keys ...jwk.Key) *testJwksServer {
+	t.Helper()
+
+	s := &testJwksServer{jwks: publicJwks(t, keys...)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.fetches++
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(s.jwks)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *testJwksServer) rotate(t *testing.T, keys ...jwk.Key) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jwks = publicJwks(t, keys...)
+}
+
+// signRS256 returns the token signed with the key.
+func signRS256(t *testing.T, tok jwt.Token, key jwk.Key) string {
+	t.Helper()
+
+	signed, err := jwt.Sign(tok, jwt.WithKey(jwa.RS256, key))
+	if err != nil {
+		t.Fatalf("jwt.Sign returned unexpected error: %v", err)
+	}
+	return string(signed)
+}
+
+// testToken returns a token for sub-1 issued by the test issuer for the test audience,
+// modified by fn.
+func testToken(t *testing.T, fn func(*jwt.Builder) *jwt.Builder) jwt.Token {
+	t.Helper()
+
+	b := jwt.NewBuilder().
+		Subject("sub-1").
+		Issuer("https://issuer.example.com").
+		Audience([]string{"gotham"}).
+		Expiration(time.Now().Add(time.Hour))
+	if fn != nil {
+		b = fn(b)
+	}
+	tok, err := b.Build()
+	if err != nil {
+		t.Fatalf("jwt.Builder.Build returned unexpected error: %v", err)
+	}
+	return tok
+}
+
+func TestJwtVerifier_Verify(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	srv := newTestJwksServer(t, key)
+	v, err := NewJwtVerifier(JwtConfig{JwksUri: srv.URL, Issuer: "https://issuer.example.com", Audience: "gotham"})
+	if err != nil {
+		t.Fatalf("NewJwtVerifier returned unexpected error: %v", err)
+	}
+
+	forger := newTestJwk(t, "k1")
+	now := time.Now()
+	tests := []struct {
+		name  string
+		jwt   string
+		valid bool
+	}{
+		{"valid", signRS256(t, testToken(t, nil), key), true},
+		{"forged", signRS256(t, testToken(t, nil), forger), false},
+		{"expired", signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.Expiration(now.Add(-time.Hour)) }), key), false},
+		{"not yet valid", signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.NotBefore(now.Add(time.Hour)) }), key), false},
+		{"wrong issuer", signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.Issuer("https://evil.example.com") }), key), false},
+		{"wrong audience", signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.Audience([]string{"other"}) }), key), false},
+		{"unsigned", signTestJwt(t, "sub-1", nil), false},
+		{"malformed", "not.a.jwt", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok, err := v.Verify(context.Background(), tt.jwt)
+			if tt.valid && (err != nil || tok.Subject() != "sub-1") {
+				t.Errorf("Verify = (%v, %v); want sub-1 token", tok, err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Verify returned nil error; want error")
+			}
+		})
+	}
+
+	if srv.fetches != 1 {
+		t.Errorf("jwks fetches = %v; want 1", srv.fetches)
+	}
+}
+
+func TestJwtVerifier_KeyRotation(t *testing.T) {
+	k1, k2 := newTestJwk(t, "k1"), newTestJwk(t, "k2")
+	srv := newTestJwksServer(t, k1)
+	v, err := NewJwtVerifier(JwtConfig{JwksUri: srv.URL})
+	if err != nil {
+		t.Fatalf("NewJwtVerifier returned unexpected error: %v", err)
+	}
+	v.keys.(*remoteJwks).minRefresh = 0
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k1)); err != nil {
+		t.Fatalf("Verify(k1) returned unexpected error: %v", err)
+	}
+
+	srv.rotate(t, k2)
+	if _, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k2)); err != nil {
+		t.Errorf("Verify(k2) returned unexpected error: %v", err)
+	}
+	if _, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k1)); err == nil {
+		t.Error("Verify(k1) returned nil error after rotation; want error")
+	}
+}
+
+func TestJwtVerifier_MinRefreshInterval(t *testing.T) {
+	srv := newTestJwksServer(t, newTestJwk(t, "k1"))
+	v, err := NewJwtVerifier(JwtConfig{JwksUri: srv.URL})
+	if err != nil {
+		t.Fatalf("NewJwtVerifier returned unexpected error: %v", err)
+	}
+
+	unknown := newTestJwk(t, "unknown")
+	for range 3 {
+		if _, err := v.Verify(context.Background(), signRS256(t, testToken(t, nil), unknown)); err == nil {
+			t.Fatal("Verify returned nil error; want error")
+		}
+	}
+	if srv.fetches != 1 {
+		t.Errorf("jwks fetches = %v; want 1", srv.fetches)
+	}
+}
+
+func TestJwtVerifier_RefreshInFlight(t *testing.T) {
+	k1, k2 := newTestJwk(t, "k1"), newTestJwk(t, "k2")
+	srv := newTestJwksServer(t, k1)
+	v, err := NewJwtVerifier(JwtConfig{JwksUri: srv.URL})
+	if err != nil {
+		t.Fatalf("NewJwtVerifier returned unexpected error: %v", err)
+	}
+	jwks := v.keys.(*remoteJwks)
+	ctx := context.Background()
+
+	if _, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k1)); err != nil {
+		t.Fatalf("Verify(k1) returned unexpected error: %v", err)
+	}
+
+	// hold the refresh for the rotated key in the jwks server
+	srv.rotate(t, k1, k2)
+	jwks.mu.Lock()
+	jwks.attemptedAt = time.Now().Add(-jwks.minRefresh)
+	jwks.mu.Unlock()
+	srv.mu.Lock()
+
+	cancelled, cancel := context.WithCancel(ctx)
+	first := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(cancelled, signRS256(t, testToken(t, nil), k2))
+		first <- err
+	}()
+	for refreshing := false; !refreshing; {
+		jwks.mu.Lock()
+		refreshing = jwks.refreshing
+		jwks.mu.Unlock()
+	}
+
+	// tokens signed with a cached key don't wait for the refresh
+	if _, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k1)); err != nil {
+		t.Errorf("Verify(k1, refreshing) returned unexpected error: %v", err)
+	}
+
+	// the refresh outlives the caller that started it
+	second := make(chan error, 1)
+	go func() {
+		_, err := v.Verify(ctx, signRS256(t, testToken(t, nil), k2))
+		second <- err
+	}()
+	cancel()
+	<-first
+	srv.mu.Unlock()
+	if err := <-second; err != nil {
+		t.Errorf("Verify(k2) returned unexpected error: %v", err)
+	}
+	if srv.fetches != 2 {
+		t.Errorf("jwks fetches = %v; want 2", srv.fetches)
+	}
+}
+
+func TestJwtVerifier_StaticJwks(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	data, _ := json.Marshal(key)
+	v, err := NewJwtVerifier(JwtConfig{Jwks: []string{string(data)}})
+	if err != nil {
+		t.Fatalf("NewJwtVerifier returned unexpected error: %v", err)
+	}
+
+	if _, err := v.Verify(context.Background(), signRS256(t, testToken(t, nil), key)); err != nil {
+		t.Errorf("Verify returned unexpected error: %v", err)
+	}
+	if _, err := v.Verify(context.Background(), signRS256(t, testToken(t, nil), newTestJwk(t, "k1"))); err == nil {
+		t.Error("Verify(forged) returned nil error; want error")
+	}
+}
+
+func TestNewJwtVerifier_Invalid(t *testing.T) {
+	if _, err := NewJwtVerifier(JwtConfig{}); err == nil {
+		t.Error("NewJwtVerifier(no keys) returned nil error; want error")
+	}
+	if _, err := NewJwtVerifier(JwtConfig{Jwks: []string{"{"}}); err == nil {
+		t.Error("NewJwtVerifier(invalid jwks) returned nil error; want error")
+	}
+}
+
+func TestJwtClaimsPrincipalLoader_ValidateJwtSignature(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	srv := newTestJwksServer(t, key)
+	cfg := Config{JwtConfig: JwtConfig{ValidateJwtSignature: true, JwksUri: srv.URL}}
+	ctx := context.Background()
+
+	valid := JwtClaimsPrincipalLoader{config: cfg, jwt: signRS256(t, testToken(t, nil), key)}
+	if pr, err := valid.FetchPrincipal(ctx, "sub-1"); err != nil || pr.Id != "sub-1" {
+		t.Errorf("FetchPrincipal = (%v, %v); want sub-1 principal", pr, err)
+	}
+
+	forged := JwtClaimsPrincipalLoader{config: cfg, jwt: signTestJwt(t, "sub-1", nil)}
+	if _, err := forged.FetchPrincipal(ctx, "sub-1"); err == nil {
+		t.Error("FetchPrincipal(forged) returned nil error; want error")
+	}
+
+	forged.config.JwtConfig.ValidateJwtSignature = false
+	if _, err := forged.FetchPrincipal(ctx, "sub-1"); err != nil {
+		t.Errorf("FetchPrincipal(unvalidated) returned unexpected error: %v", err)
+	}
+}
+
+func TestJwtClaimsPrincipalLoader_Verified(t *testing.T) {
+	cfg := Config{JwtConfig: JwtConfig{ValidateJwtSignature: true, JwksUri: "http://127.0.0.1:0"}}
+	l := JwtClaimsPrincipalLoader{config: cfg, jwt: "not-a-jwt", verified: map[string]any{"sub": "sub-1", "login": "jdoe"}}
+
+	if pr, err := l.FetchPrincipal(context.Background(), "sub-1"); err != nil || pr.Login != "jdoe" {
+		t.Errorf("FetchPrincipal = (%v, %v); want jdoe", pr, err)
+	}
+}
+
+func TestAwsalbPrincipal_ValidateJwtSignature(t *testing.T) {
+	key := newTestJwk(t, "k1")
+	srv := newTestJwksServer(t, key)
+	cloader := CachePrincipalLoader{"principal", newTestRamCache(t)}
+	ap := testAwsalbPolicy()
+	ap.Config.JwtConfig.ValidateJwtSignature = true
+	ap.Config.JwtConfig.JwksUri = srv.URL
+	ctx := context.Background()
+
+	request := func(token string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Amzn-Oidc-Data", token)
+		return req
+	}
+
+	valid := signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.Claim("login", "jane.doe@example.com") }), key)
+	if pr, err := awsalbPrincipal(ctx, ap, request(valid), "sub-1", cloader, nil); err != nil || pr.Login != "jane.doe@example.com" {
+		t.Fatalf("awsalbPrincipal = (%v, %v); want jane.doe@example.com", pr, err)
+	}
+
+	if _, err := awsalbPrincipal(ctx, ap, request(signTestJwt(t, "sub-1", nil)), "sub-1", cloader, nil); err == nil {
+		t.Error("awsalbPrincipal(forged, cached) returned nil error; want error")
+	}
+	expired := signRS256(t, testToken(t, func(b *jwt.Builder) *jwt.Builder { return b.Expiration(time.Now().Add(-time.Hour)) }), key)
+	if _, err := awsalbPrincipal(ctx, ap, request(expired), "sub-1", cloader, nil); err == nil {
+		t.Error("awsalbPrincipal(expired, cached) returned nil error; want error")
+	}
+	if _, err := awsalbPrincipal(ctx, ap, request(valid), "sub-2", cloader, nil); err == nil {
+		t.Error("awsalbPrincipal(other sub) returned nil error; want error")
+	}
+}
@@ -1,11 +1,13 @@
 package http
 
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/TouchBistro/goutils/color"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
@@ -19,6 +21,9 @@ import (
 // * /api/v1/*       | admin                | allow  |
 // * *               | *                    | deny   |
 //
+// paths match exactly unless the item selects a glob (/api/v1/*/orders/**), a
+// ServeMux style pattern (/api/v1/users/{alias}) or an anchored regex; the params
+// captured by patterns & named regex groups are checked by the item conditions
 
 type PolicyEffect string
 
@@ -36,31 +41,103 @@ const (
 )
 
 type PolicyItem struct {
-	Priority   int64        `json:"-"`        // assigned at parse
-	Name       string       `json:"name"`     // human-readable name for this policy item
-	HttpMethod string       `json:"method"`   // an http method, or everything if not supplied
-	HttpPath   string       `json:"url"`      // a pattern or regex that matches the path/object
-	Effect     PolicyEffect `json:"effect"`   // allow | deny
-	Subjects   Set          `json:"subjects"` // a set of subjects to whom this applies; uses custom unmarshall logic
+	Priority   int64             `json:"-"`                    // assigned at parse
+	Name       string            `json:"name"`                 // human-readable name for this policy item
+	HttpMethod string            `json:"method"`               // an http method, or everything if not supplied
+	HttpPath   string            `json:"url"`                  // a pattern or regex that matches the path/object
+	PathMatch  PathMatch         `json:"match,omitempty"`      // how HttpPath matches: exact (default) | glob | pattern | regex
+	Conditions []PolicyCondition `json:"conditions,omitempty"` // conditions on the path params, all must hold
+	Effect     PolicyEffect      `json:"effect"`               // allow | deny
+	Subjects   Set               `json:"subjects"`             // a set of subjects to whom this applies; uses custom unmarshall logic
+
+	matcher pathMatcher // compiled HttpPath, nil until compiled
+}
+
+// UnmarshalJSON impl custom unmarshall logic for PolicyItem, compiling the path at load
+func (p *PolicyItem) UnmarshalJSON(data []byte) error {
+	type item PolicyItem // without the UnmarshalJSON method
+	var it item
+	if err := json.Unmarshal(data, &it); err != nil {
+		return err
+	}
+	*p = PolicyItem(it)
+	return p.Compile()
+}
+
+// Compile compiles the HttpPath pattern & validates the conditions; items loaded from
+// json are compiled at load & the auth handlers compile their policies when created.
+// Items that are never compiled are compiled on every match
+func (p *PolicyItem) Compile() error {
+	m, err := newPathMatcher(p.PathMatch, p.HttpPath)
+	if err != nil {
+		return errors.Wrapf(err, "error compiling policy %v", p.Name)
+	}
+	for _, c := range p.Conditions {
+		if err := c.validate(m.names()); err != nil {
+			return errors.Wrapf(err, "error compiling policy %v", p.Name)
+		}
+	}
+	p.matcher = m
+	return nil
+}
+
+// matchPath returns the path params if the item matches the path
+func (p PolicyItem) matchPath(urlPath string) (map[string]string, bool) {
+	m := p.matcher
+	if m == nil {
+		if err := p.Compile(); err != nil {
+			log.Warnf("policy %v not matched: %v", p.Name, err)
+			return nil, false
+		}
+		m = p.matcher
+	}
+	return m.match(urlPath)
 }
 
 type Policies []PolicyItem
 
+// Compile compiles every policy item, see PolicyItem.Compile
+func (p Policies) Compile() error {
+	for i := range p {
+		if err := p[i].Compile(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Match matches the supplied sub with the policies & returns a
 // matching policy based on the pre-defined rules. If no match is found, a non-nil
 // error is returns. Also, if an error occurs during matching, a non-nil error
 // specifying the details is returned.
 func (p Policies) Match(pr Principal, req http.Request) (*PolicyItem, error) {
+	item, _, err := p.MatchWithParams(pr, req)
+	return item, err
+}
+
+// MatchWithParams matches like Match, also returning the path params captured by the
+// matching policy
+func (p Policies) MatchWithParams(pr Principal, req http.Request) (*PolicyItem, map[string]string, error) {
 	for _, item := range p {
 		log.Tracef("matching: %v %v %v to %v (%v)", pr, req.Method, req.URL.Path, item.Name, item.Priority)
 		if item.HttpMethod == AllMethods || strings.EqualFold(item.HttpMethod, req.Method) {
-			if item.HttpPath == AllPaths || strings.EqualFold(item.HttpPath, req.URL.Path) {
-				if item.Subjects.ContainsSet(pr.Roles) {
+			if params, ok := item.matchPath(req.URL.Path); ok {
+				if item.Subjects.ContainsSet(pr.Roles) && item.conditionsHold(pr, params) {
 					log.Debugf("auth match found: %v %v %v to %v (%v)", color.Green(pr.Login), req.Method, req.URL.Path, color.Green(item.Name), item.Priority)
-					return &item, nil
+					return &item, params, nil
 				}
 			}
 		}
 	}
-	return nil, fmt.Errorf("subject %v not explicitly authorized to %v", color.Red(pr.Login), req.URL)
+	return nil, nil, fmt.Errorf("subject %v not explicitly authorized to %v", color.Red(pr.Login), req.URL)
+}
+
+// conditionsHold returns true if all conditions hold for the principal & path params
+func (p PolicyItem) conditionsHold(pr Principal, params map[string]string) bool {
+	for _, c := range p.Conditions {
+		if !c.Eval(pr, params) {
+			return false
+		}
+	}
+	return true
 }
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// principalRefPrefix marks a condition value that refers to a principal attribute
+const principalRefPrefix = "$"
+
+// PolicyCondition is a condition on a path param captured by the HttpPath of a policy
+// item, e.g. the item of the pattern "/users/{alias}/orders" with the condition
+// {"param": "alias", "equals": "$alias"} only matches the requests of a user for their
+// own orders. Values starting with "$" refer to the principal: $id, $alias, $login &
+// $email
+type PolicyCondition struct {
+	Param  string   `json:"param"`            // name of the path param
+	Equals string   `json:"equals,omitempty"` // the param must equal this value
+	In     []string `json:"in,omitempty"`     // the param must be one of these values
+}
+
+// validate checks that the condition refers to a captured param & known principal
+// attributes
+func (c PolicyCondition) validate(params []string) error {
+	if !slices.Contains(params, c.Param) {
+		return errors.Errorf("condition on unknown path param %q", c.Param)
+	}
+	if c.Equals == "" && len(c.In) == 0 {
+		return errors.Errorf("condition on path param %q has no equals or in value", c.Param)
+	}
+	for _, v := range append([]string{c.Equals}, c.In...) {
+		if strings.HasPrefix(v, principalRefPrefix) {
+			if _, ok := principalAttribute(Principal{}, v); !ok {
+				return errors.Errorf("condition on path param %q refers to unknown principal attribute %q", c.Param, v)
+			}
+		}
+	}
+	return nil
+}
+
+// Eval returns true if the path param satisfies the condition for the principal
+func (c PolicyCondition) Eval(pr Principal, params map[string]string) bool {
+	v, ok := params[c.Param]
+	if !ok {
+		return false
+	}
+	if c.Equals != "" && !conditionValueEquals(pr, c.Equals, v) {
+		return false
+	}
+	if len(c.In) > 0 && !slices.ContainsFunc(c.In, func(want string) bool { return conditionValueEquals(pr, want, v) }) {
+		return false
+	}
+	return true
+}
+
+// conditionValueEquals returns true if the param value equals the condition value,
+// resolved against the principal
+func conditionValueEquals(pr Principal, want, v string) bool {
+	if strings.HasPrefix(want, principalRefPrefix) {
+		attr, ok := principalAttribute(pr, want)
+		// an unset attribute never matches
+		return ok && attr != "" && attr == v
+	}
+	return want == v
+}
+
+// principalAttribute returns the principal attribute for the "$name" reference
+func principalAttribute(pr Principal, ref string) (string, bool) {
+	switch strings.TrimPrefix(ref, principalRefPrefix) {
+	case "id":
+		return pr.Id, true
+	case "alias":
+		return pr.Alias, true
+	case "login":
+		return pr.Login, true
+	case "email":
+		return pr.Email, true
+	default:
+		return "", false
+	}
+}
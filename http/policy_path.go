@@ -0,0 +1,259 @@
+package http
+
+import (
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// PathMatch selects how the HttpPath of a policy item matches request paths
+type PathMatch string
+
+const (
+	PathMatchExact   PathMatch = "exact"   // the path, case-insensitive; the default
+	PathMatchGlob    PathMatch = "glob"    // e.g. /users/*/orders/**, case-insensitive
+	PathMatchPattern PathMatch = "pattern" // net/http ServeMux style, e.g. /users/{id}/orders/{rest...}
+	PathMatchRegex   PathMatch = "regex"   // a regex anchored to the whole path, named groups are params
+)
+
+// pathMatcher matches request paths, returning the captured path params
+type pathMatcher interface {
+	match(urlPath string) (map[string]string, bool)
+
+	// names returns the names of the params the matcher captures
+	names() []string
+}
+
+// newPathMatcher compiles the matcher for the pattern
+func newPathMatcher(typ PathMatch, pattern string) (pathMatcher, error) {
+	if pattern == AllPaths {
+		return allPathsMatcher{}, nil
+	}
+
+	switch typ {
+	case "", PathMatchExact:
+		return exactMatcher(pattern), nil
+	case PathMatchGlob:
+		return newGlobMatcher(pattern)
+	case PathMatchPattern:
+		return newPatternMatcher(pattern)
+	case PathMatchRegex:
+		return newRegexMatcher(pattern)
+	default:
+		return nil, errors.Errorf("unsupported path match %q", typ)
+	}
+}
+
+// splitPath splits the path into its segments, ignoring the leading slash
+func splitPath(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "/"), "/")
+}
+
+// allPathsMatcher matches every path
+type allPathsMatcher struct{}
+
+func (allPathsMatcher) match(urlPath string) (map[string]string, bool) { return nil, true }
+func (allPathsMatcher) names() []string                                { return nil }
+
+// exactMatcher matches the path, case-insensitive
+type exactMatcher string
+
+func (m exactMatcher) match(urlPath string) (map[string]string, bool) {
+	return nil, strings.EqualFold(string(m), urlPath)
+}
+func (m exactMatcher) names() []string { return nil }
+
+// globMatcher matches paths segment by segment, "*" matches within a segment (see
+// path.Match) & a "**" segment matches any number of segments
+type globMatcher struct {
+	segments []string
+}
+
+func newGlobMatcher(pattern string) (globMatcher, error) {
+	segments := splitPath(strings.ToLower(pattern))
+	for _, seg := range segments {
+		if seg != "**" && strings.Contains(seg, "**") {
+			return globMatcher{}, errors.Errorf("invalid glob %v: ** must be a whole segment", pattern)
+		}
+		if _, err := path.Match(seg, ""); err != nil {
+			return globMatcher{}, errors.Wrapf(err, "invalid glob %v", pattern)
+		}
+	}
+	return globMatcher{segments}, nil
+}
+
+func (m globMatcher) match(urlPath string) (map[string]string, bool) {
+	return nil, matchGlobSegments(m.segments, splitPath(strings.ToLower(urlPath)))
+}
+func (m globMatcher) names() []string { return nil }
+
+// matchGlobSegments returns true if the path segments match the glob segments
+func matchGlobSegments(glob, segments []string) bool {
+	for len(glob) > 0 {
+		if glob[0] == "**" {
+			for i := 0; i <= len(segments); i++ {
+				if matchGlobSegments(glob[1:], segments[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(glob[0], segments[0]); !ok {
+			return false
+		}
+		glob, segments = glob[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// patternMatcher matches net/http ServeMux style patterns: "{name}" matches a segment,
+// a final "{name...}" or a trailing slash matches the rest of the path & a final "{$}"
+// matches only the path ending with the slash. Literal segments are case-insensitive
+type patternMatcher struct {
+	segments []patternSegment
+	prefix   bool // the final segment matches the rest of the path
+}
+
+type patternSegment struct {
+	literal string
+	param   string // the param name, if not a literal
+}
+
+func newPatternMatcher(pattern string) (patternMatcher, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return patternMatcher{}, errors.Errorf("invalid pattern %v: must start with /", pattern)
+	}
+
+	var m patternMatcher
+	raw := splitPath(pattern)
+	seen := make(map[string]bool)
+	for i, seg := range raw {
+		last := i == len(raw)-1
+		if !strings.HasPrefix(seg, "{") || !strings.HasSuffix(seg, "}") {
+			if strings.ContainsAny(seg, "{}") {
+				return patternMatcher{}, errors.Errorf("invalid pattern %v: a param must be a whole segment", pattern)
+			}
+			if last && seg == "" {
+				// trailing slash, e.g. /static/
+				m.segments = append(m.segments, patternSegment{param: ""})
+				m.prefix = true
+				break
+			}
+			m.segments = append(m.segments, patternSegment{literal: seg})
+			continue
+		}
+
+		name := seg[1 : len(seg)-1]
+		switch {
+		case name == "$":
+			if !last {
+				return patternMatcher{}, errors.Errorf("invalid pattern %v: {$} must be last", pattern)
+			}
+			m.segments = append(m.segments, patternSegment{literal: ""})
+			continue
+		case strings.HasSuffix(name, "..."):
+			if !last {
+				return patternMatcher{}, errors.Errorf("invalid pattern %v: %v must be last", pattern, seg)
+			}
+			name = strings.TrimSuffix(name, "...")
+			m.prefix = true
+		}
+		if !isParamName(name) {
+			return patternMatcher{}, errors.Errorf("invalid pattern %v: bad param name %q", pattern, name)
+		}
+		if seen[name] {
+			return patternMatcher{}, errors.Errorf("invalid pattern %v: duplicate param %v", pattern, name)
+		}
+		seen[name] = true
+		m.segments = append(m.segments, patternSegment{param: name})
+	}
+	return m, nil
+}
+
+func (m patternMatcher) match(urlPath string) (map[string]string, bool) {
+	segments := splitPath(urlPath)
+	if len(segments) < len(m.segments) || (!m.prefix && len(segments) != len(m.segments)) {
+		return nil, false
+	}
+
+	params := make(map[string]string)
+	for i, seg := range m.segments {
+		if m.prefix && i == len(m.segments)-1 {
+			if seg.param != "" {
+				params[seg.param] = strings.Join(segments[i:], "/")
+			}
+			return params, true
+		}
+		if seg.param == "" {
+			if !strings.EqualFold(seg.literal, segments[i]) {
+				return nil, false
+			}
+			continue
+		}
+		if segments[i] == "" {
+			return nil, false
+		}
+		params[seg.param] = segments[i]
+	}
+	return params, true
+}
+
+func (m patternMatcher) names() []string {
+	var names []string
+	for _, seg := range m.segments {
+		if seg.param != "" {
+			names = append(names, seg.param)
+		}
+	}
+	return names
+}
+
+// isParamName returns true for valid go identifiers
+func isParamName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && !('a' <= r && r <= 'z') && !('A' <= r && r <= 'Z') && (i == 0 || !('0' <= r && r <= '9')) {
+			return false
+		}
+	}
+	return true
+}
+
+// regexMatcher matches the regex anchored to the whole path, named groups are params
+type regexMatcher struct {
+	re *regexp.Regexp
+}
+
+func newRegexMatcher(pattern string) (regexMatcher, error) {
+	re, err := regexp.Compile(`^(?:` + pattern + `)$`)
+	if err != nil {
+		return regexMatcher{}, errors.Wrapf(err, "invalid regex %v", pattern)
+	}
+	return regexMatcher{re}, nil
+}
+
+func (m regexMatcher) match(urlPath string) (map[string]string, bool) {
+	sub := m.re.FindStringSubmatch(urlPath)
+	if sub == nil {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, name := range m.re.SubexpNames() {
+		if name != "" {
+			params[name] = sub[i]
+		}
+	}
+	return params, true
+}
+
+func (m regexMatcher) names() []string {
+	return slices.DeleteFunc(slices.Clone(m.re.SubexpNames()), func(name string) bool { return name == "" })
+}
@@ -0,0 +1,186 @@
+package http
+
+import (
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathMatcher(t *testing.T) {
+	tests := []struct {
+		typ     PathMatch
+		pattern string
+		path    string
+		want    bool
+		params  map[string]string
+	}{
+		{PathMatchExact, "/api/v1/dbs", "/API/v1/dbs", true, nil},
+		{PathMatchExact, "/api/v1/dbs", "/api/v1/dbs/1", false, nil},
+		{PathMatchGlob, "*", "/anything/at/all", true, nil},
+		{PathMatchGlob, "/users/*/orders/**", "/users/42/orders", true, nil},
+		{PathMatchGlob, "/users/*/orders/**", "/users/42/orders/7/items", true, nil},
+		{PathMatchGlob, "/users/*/orders/**", "/users/42/invoices", false, nil},
+		{PathMatchGlob, "/users/*/orders/**", "/users/42/43/orders", false, nil},
+		{PathMatchGlob, "/files/*.json", "/files/a.json", true, nil},
+		{PathMatchGlob, "/files/*.json", "/files/a.xml", false, nil},
+		{PathMatchPattern, "/users/{id}/orders/{oid}", "/users/42/orders/7", true, map[string]string{"id": "42", "oid": "7"}},
+		{PathMatchPattern, "/users/{id}/orders/{oid}", "/users/42/orders", false, nil},
+		{PathMatchPattern, "/users/{id}", "/users/", false, nil},
+		{PathMatchPattern, "/files/{path...}", "/files/a/b.txt", true, map[string]string{"path": "a/b.txt"}},
+		{PathMatchPattern, "/static/", "/static/css/app.css", true, map[string]string{}},
+		{PathMatchPattern, "/static/{$}", "/static/", true, map[string]string{}},
+		{PathMatchPattern, "/static/{$}", "/static/app.css", false, nil},
+		{PathMatchRegex, `/users/(?P<id>[0-9]+)`, "/users/42", true, map[string]string{"id": "42"}},
+		{PathMatchRegex, `/users/(?P<id>[0-9]+)`, "/users/42/orders", false, nil},
+		{PathMatchRegex, `/users/[0-9]+|/admin`, "/admin/users/1", false, nil},
+	}
+
+	for _, tt := range tests {
+		m, err := newPathMatcher(tt.typ, tt.pattern)
+		if err != nil {
+			t.Errorf("newPathMatcher(%v, %q) returned unexpected error: %v", tt.typ, tt.pattern, err)
+			continue
+		}
+		params, ok := m.match(tt.path)
+		if ok != tt.want {
+			t.Errorf("%v %q match(%q) = %v; want %v", tt.typ, tt.pattern, tt.path, ok, tt.want)
+		}
+		if ok && tt.params != nil && !maps.Equal(params, tt.params) {
+			t.Errorf("%v %q match(%q) params = %v; want %v", tt.typ, tt.pattern, tt.path, params, tt.params)
+		}
+	}
+}
+
+func TestPolicyItem_CompileInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		item PolicyItem
+	}{
+		{"unknown match", PolicyItem{HttpPath: "/a", PathMatch: "fuzzy"}},
+		{"bad glob", PolicyItem{HttpPath: "/a/[", PathMatch: PathMatchGlob}},
+		{"partial **", PolicyItem{HttpPath: "/a/b**", PathMatch: PathMatchGlob}},
+		{"bad regex", PolicyItem{HttpPath: "/a/(", PathMatch: PathMatchRegex}},
+		{"rest not last", PolicyItem{HttpPath: "/{rest...}/a", PathMatch: PathMatchPattern}},
+		{"duplicate param", PolicyItem{HttpPath: "/{id}/{id}", PathMatch: PathMatchPattern}},
+		{"partial param", PolicyItem{HttpPath: "/a{id}", PathMatch: PathMatchPattern}},
+		{"unknown param", PolicyItem{HttpPath: "/{id}", PathMatch: PathMatchPattern, Conditions: []PolicyCondition{{Param: "alias", Equals: "$alias"}}}},
+		{"unknown attribute", PolicyItem{HttpPath: "/{id}", PathMatch: PathMatchPattern, Conditions: []PolicyCondition{{Param: "id", Equals: "$phone"}}}},
+		{"no value", PolicyItem{HttpPath: "/{id}", PathMatch: PathMatchPattern, Conditions: []PolicyCondition{{Param: "id"}}}},
+	}
+
+	for _, tt := range tests {
+		if err := tt.item.Compile(); err == nil {
+			t.Errorf("Compile(%v) returned nil error; want error", tt.name)
+		}
+	}
+}
+
+func TestPolicies_MatchConditions(t *testing.T) {
+	p := Policies{
+		{Name: "own_orders", HttpMethod: AllMethods, HttpPath: "/users/{alias}/orders/{rest...}", PathMatch: PathMatchPattern,
+			Conditions: []PolicyCondition{{Param: "alias", Equals: "$alias"}}, Effect: PolicyEffectAllow, Subjects: RoleSetFrom(Everyone)},
+		{Name: "public_stores", HttpMethod: http.MethodGet, HttpPath: `/stores/(?P<store>[a-z]+)`, PathMatch: PathMatchRegex,
+			Conditions: []PolicyCondition{{Param: "store", In: []string{"north", "south"}}}, Effect: PolicyEffectAllow, Subjects: RoleSetFrom(Everyone)},
+		{Name: "deny_all", HttpMethod: AllMethods, HttpPath: AllPaths, Effect: PolicyEffectDeny, Subjects: RoleSetFrom(Everyone)},
+	}
+	if err := p.Compile(); err != nil {
+		t.Fatalf("Compile returned unexpected error: %v", err)
+	}
+	jane := Principal{Login: "jane.doe", Alias: "jane_doe", Roles: Set{}}
+
+	tests := []struct {
+		method, path string
+		want         string
+		params       map[string]string
+	}{
+		{http.MethodGet, "/users/jane_doe/orders/1", "own_orders", map[string]string{"alias": "jane_doe", "rest": "1"}},
+		{http.MethodGet, "/users/john_doe/orders/1", "deny_all", nil},
+		{http.MethodGet, "/stores/north", "public_stores", map[string]string{"store": "north"}},
+		{http.MethodGet, "/stores/east", "deny_all", nil},
+	}
+
+	for _, tt := range tests {
+		item, params, err := p.MatchWithParams(jane, *httptest.NewRequest(tt.method, tt.path, nil))
+		if err != nil {
+			t.Errorf("MatchWithParams(%v) returned unexpected error: %v", tt.path, err)
+			continue
+		}
+		if item.Name != tt.want {
+			t.Errorf("MatchWithParams(%v) = %v; want %v", tt.path, item.Name, tt.want)
+		}
+		if tt.params != nil && !maps.Equal(params, tt.params) {
+			t.Errorf("MatchWithParams(%v) params = %v; want %v", tt.path, params, tt.params)
+		}
+	}
+}
+
+func TestPolicies_MatchUncompiled(t *testing.T) {
+	p := Policies{{Name: "orders", HttpMethod: AllMethods, HttpPath: "/users/*/orders/**", PathMatch: PathMatchGlob, Effect: PolicyEffectAllow, Subjects: RoleSetFrom(Everyone)}}
+
+	item, err := p.Match(Principal{Roles: Set{}}, *httptest.NewRequest(http.MethodGet, "/users/1/orders/2", nil))
+	if err != nil || item.Name != "orders" {
+		t.Errorf("Match = (%v, %v); want orders", item, err)
+	}
+}
+
+func TestLoadPolicyFromFile_CompilesPaths(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, items ...map[string]any) string {
+		data, _ := json.Marshal(map[string]any{"authrPolicy": items})
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("WriteFile returned unexpected error: %v", err)
+		}
+		return path
+	}
+
+	ap, err := LoadPolicyFromFile(write("valid.json", map[string]any{
+		"name": "users", "method": "*", "url": "/users/{id}", "match": "pattern", "effect": "allow", "subjects": []string{"*"},
+	}))
+	if err != nil {
+		t.Fatalf("LoadPolicyFromFile returned unexpected error: %v", err)
+	}
+	if ap.AuthrPolicies[0].matcher == nil {
+		t.Error("matcher = nil; want the path compiled at load")
+	}
+
+	_, err = LoadPolicyFromFile(write("invalid.json", map[string]any{
+		"name": "users", "method": "*", "url": "/users/(", "match": "regex", "effect": "allow", "subjects": []string{"*"},
+	}))
+	if err == nil {
+		t.Error("LoadPolicyFromFile(invalid regex) returned nil error; want error")
+	}
+}
+
+func TestPrincipalAuthHttpMiddleware_InvalidPolicy(t *testing.T) {
+	ap := AuthPolicy{AuthrPolicies: Policies{
+		{Name: "bad_regex", HttpMethod: AllMethods, HttpPath: "/a/(", PathMatch: PathMatchRegex, Effect: PolicyEffectAllow, Subjects: RoleSetFrom(Everyone)},
+	}}
+	authenticate := func(r *http.Request) (*Principal, error) {
+		return &Principal{Id: "sub-1", Roles: Set{}}, nil
+	}
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler reached with an invalid auth policy")
+	}), principalAuthHttpMiddleware(ap, "", authenticate))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a/", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d; want %d", rec.Code, http.StatusInternalServerError)
+	}
+	if ap.AuthrPolicies[0].matcher != nil {
+		t.Error("the policies of the caller were modified; want a compiled copy")
+	}
+}
+
+func TestLoadPolicyDefault_Compiled(t *testing.T) {
+	for _, item := range loadPolicyDefault().AuthrPolicies {
+		if item.matcher == nil {
+			t.Errorf("%v matcher = nil; want the path compiled", item.Name)
+		}
+	}
+}
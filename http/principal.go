@@ -7,35 +7,40 @@ import (
 // Principal
 type Principal struct {
 	// The identifier for the principal, normally the sub
-	Id string `json:"id" claim:"sub"` // sub
+	Id string `json:"id" claim:"sub" cache:"id"` // sub
 
 	// User alias
-	Alias string `json:"alias"` // deried alias
+	Alias string `json:"alias" cache:"alias"` // deried alias
 
 	// Attributes mapped from claims
 	// login
-	Login string `json:"login" claim:"login"` // unique login
+	Login string `json:"login" claim:"login" cache:"login"` // unique login
 
 	// fname
-	FirstName string `json:"fname" claim:"fname"` // first name
+	FirstName string `json:"fname" claim:"fname" cache:"fname"` // first name
 	// lname
-	LastName string `json:"lname" claim:"lname"` // last name
+	LastName string `json:"lname" claim:"lname" cache:"lname"` // last name
 	// eml
-	Email string `json:"email" claim:"eml"` // email address
+	Email string `json:"email" claim:"eml" cache:"email"` // email address
 	// groups
-	Groups []string `json:"groups" claim:"groups"` // groups assignments
+	Groups []string `json:"groups" claim:"groups" cache:"groups"` // groups assignments
 	// managerId
-	ManagerId string `json:"managerId" claim:"managerId"` // manager id
+	ManagerId string `json:"managerId" claim:"managerId" cache:"managerId"` // manager id
 	// managerName
-	ManagerName string `json:"managerName" claim:"manager"` // manager name
+	ManagerName string `json:"managerName" claim:"manager" cache:"managerName"` // manager name
 
 	// raw claims
-	RawClaims    map[string]any `json:"claims"`
-	Roles        Set            `json:"roles"`        // roles assigned, mapped from groups
-	RawToken     string         `json:"raw"`          // raw id token awsalb token
-	IsAdmin      bool           `json:"isAdmin"`      // is admiistrator
-	IsSuperAdmin bool           `json:"isSuperAdmin"` // is super adming
-	Expiry       time.Time
+	RawClaims    map[string]any `json:"claims" cache:"claims"`
+	Roles        Set            `json:"roles" cache:"roles"`               // roles assigned, mapped from groups
+	RawToken     string         `json:"raw" cache:"raw"`                   // raw id token awsalb token
+	IsAdmin      bool           `json:"isAdmin" cache:"isAdmin"`           // is admiistrator
+	IsSuperAdmin bool           `json:"isSuperAdmin" cache:"isSuperAdmin"` // is super adming
+	Expiry       time.Time      `cache:"expiry"`
+}
+
+// ExpiresAt returns the expiry of the principal, a cached principal expires with it
+func (p Principal) ExpiresAt() time.Time {
+	return p.Expiry
 }
 
 // Merge does a field-by-field merge, by taking the non-zero value from the other
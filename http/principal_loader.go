@@ -2,8 +2,9 @@ package http
 
 import (
 	"context"
-	"fmt"
+	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/TouchBistro/gotham/cache"
@@ -29,8 +30,9 @@ func (f PrincipalLoaderFunc) FetchPrincipal(ctx context.Context, sub string) (*P
 // //
 // //
 type JwtClaimsPrincipalLoader struct {
-	config Config
-	jwt    string
+	config   Config
+	jwt      string
+	verified map[string]any // claims of the jwt if already verified, so it isn't verified again
 }
 
 // FetchPrincipal implements the interface method
@@ -39,7 +41,7 @@ func (l JwtClaimsPrincipalLoader) FetchPrincipal(ctx context.Context, subject st
 	log.Debugf("loading principal for sub %v from jwt claims", subject)
 
 	// get claism from jwt
-	claims, err := util.ClaimsFromJwt(l.jwt)
+	claims, err := l.claims(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -150,6 +152,29 @@ func (l JwtClaimsPrincipalLoader) FetchPrincipal(ctx context.Context, subject st
 	}, nil
 }
 
+// claims returns the claims of the jwt; if signature validation is enabled, forged,
+// expired or otherwise invalid tokens are rejected
+func (l JwtClaimsPrincipalLoader) claims(ctx context.Context) (map[string]any, error) {
+	if l.verified != nil {
+		return l.verified, nil
+	}
+	if l.config.JwtConfig.ValidateAlbSignature {
+		if _, err := verifyAlbToken(ctx, l.config.JwtConfig, l.jwt); err != nil {
+			return nil, err
+		}
+		return util.ClaimsFromJwt(l.jwt)
+	}
+	if !l.config.JwtConfig.ValidateJwtSignature {
+		return util.ClaimsFromJwt(l.jwt)
+	}
+
+	token, err := verifyJwt(ctx, l.config.JwtConfig, l.jwt)
+	if err != nil {
+		return nil, err
+	}
+	return util.ClaimsFromToken(token), nil
+}
+
 // CachePrincipalLoader implements PrincipalLoader from a memory cache
 // //
 // //
@@ -161,37 +186,62 @@ type CachePrincipalLoader struct {
 // FetchPrincipal implements the interface method
 func (l CachePrincipalLoader) FetchPrincipal(ctx context.Context, subject string) (*Principal, error) {
 
-	key := l.buildCacheKey(subject)
-	pr := Principal{
-		Roles: Set{},
-	}
+	key := l.key(subject)
 
-	ttl, err := l.Cache.FetchWithTtl(ctx, key, &pr)
+	pr, ttl, err := l.principals().GetWithTtl(ctx, subject)
 	if err != nil {
 		log.Debugf("cache miss for key=%v (sub) when fetching cached principal", key)
 		return nil, err
 	}
+	if pr.Roles == nil {
+		pr.Roles = Set{}
+	}
 
 	log.Debugf("cache hit for key=%v (sub) ttl=%v expiry=%v when fetching cached principal", key, time.Now().Add(*ttl), pr.Expiry)
 	return &pr, nil
 }
 
 func (l CachePrincipalLoader) Persist(ctx context.Context, pr Principal) error {
-	key := l.buildCacheKey(pr.Id) // Id is the value of "sub" claim
 	ttl := time.Until(pr.Expiry)
-	log.Debugf("caching principal key=%v (sub), ttl=%v", key, ttl)
+	log.Debugf("caching principal key=%v (sub), ttl=%v", l.key(pr.Id), ttl) // Id is the value of "sub" claim
 
-	if err := l.Cache.PutWithTtl(ctx, key, pr, ttl); err != nil {
+	if err := l.principals().Set(ctx, pr.Id, pr, ttl); err != nil {
 		return err
 	}
 	return nil
 }
 
-func (l CachePrincipalLoader) buildCacheKey(sub string) string {
-	if l.KeyPrefix != "" {
-		return fmt.Sprintf("%v::%v", l.KeyPrefix, sub)
+// key returns the cache key of the principal for the subject, "prefix::sub"
+func (l CachePrincipalLoader) key(subject string) string {
+	return l.principals().Key(subject)
+}
+
+// principalCaches holds the typed principal cache per key prefix & cache, so loads of
+// a principal are shared across requests
+var principalCaches sync.Map
+
+// principalCacheKey identifies a typed principal cache in principalCaches
+type principalCacheKey struct {
+	prefix string
+	cache  cache.MemoryCache
+}
+
+// principals returns the shared typed view of the cache for principals, keyed
+// "prefix::sub" as-is; loads through it refresh ahead of expiry & remember failures
+// for a short time
+func (l CachePrincipalLoader) principals() *cache.Typed[Principal] {
+	opts := cache.ReadThroughOptions{RefreshAhead: principalRefreshAhead, NegativeTtl: principalNegativeTtl}
+	if l.Cache != nil && !reflect.TypeOf(l.Cache).Comparable() {
+		return cache.NewTypedWithOptions[Principal](l.Cache, l.KeyPrefix, opts)
+	}
+
+	key := principalCacheKey{l.KeyPrefix, l.Cache}
+	if t, ok := principalCaches.Load(key); ok {
+		return t.(*cache.Typed[Principal])
 	}
-	return sub
+	t := cache.NewTypedWithOptions[Principal](l.Cache, l.KeyPrefix, opts)
+	actual, _ := principalCaches.LoadOrStore(key, t)
+	return actual.(*cache.Typed[Principal])
 }
 
 // StaticPrincipalLoader is a mocking helper function that returns a PrincipalLoader that
@@ -0,0 +1,71 @@
+package http
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/gotham/cache"
+)
+
+// newTestRamCache returns a RamCache that is stopped at test cleanup.
+func newTestRamCache(t *testing.T) *cache.RamCache {
+	t.Helper()
+	r, err := cache.NewRamCache(cache.RamConfig{})
+	if err != nil {
+		t.Fatalf("cache.NewRamCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(r.Stop)
+	return r
+}
+
+func TestCachePrincipalLoader_PersistFetch(t *testing.T) {
+	r := newTestRamCache(t)
+	l := CachePrincipalLoader{"principal", r}
+	ctx := context.Background()
+
+	pr := Principal{Id: "sub-1", Login: "jdoe@example.com", Roles: RoleSetFrom("admin"), Expiry: time.Now().Add(time.Minute)}
+	if err := l.Persist(ctx, pr); err != nil {
+		t.Fatalf("Persist returned unexpected error: %v", err)
+	}
+
+	got, err := l.FetchPrincipal(ctx, "sub-1")
+	if err != nil {
+		t.Fatalf("FetchPrincipal returned unexpected error: %v", err)
+	}
+	if got.Login != pr.Login || !got.Roles.Contains("admin") {
+		t.Errorf("FetchPrincipal = %+v; want %+v", got, pr)
+	}
+
+	var raw Principal
+	if err := r.Fetch(ctx, "principal::sub-1", &raw); err != nil {
+		t.Errorf("Fetch(principal::sub-1) returned unexpected error: %v", err)
+	}
+}
+
+func TestCachePrincipalLoader_FetchMiss(t *testing.T) {
+	l := CachePrincipalLoader{"principal", newTestRamCache(t)}
+
+	if _, err := l.FetchPrincipal(context.Background(), "unknown"); !cache.IsCacheMiss(err) {
+		t.Fatalf("FetchPrincipal error = %v; want cache miss", err)
+	}
+}
+
+func TestCachePrincipalLoader_RawKeys(t *testing.T) {
+	r := newTestRamCache(t)
+	l := CachePrincipalLoader{"{principal}", r}
+	ctx := context.Background()
+
+	sub := "sub::" + strings.Repeat("x", 200)
+	if err := l.Persist(ctx, Principal{Id: sub, Expiry: time.Now().Add(time.Minute)}); err != nil {
+		t.Fatalf("Persist returned unexpected error: %v", err)
+	}
+	var raw Principal
+	if err := r.Fetch(ctx, "{principal}::"+sub, &raw); err != nil {
+		t.Errorf("Fetch({principal}::sub) returned unexpected error: %v", err)
+	}
+	if l.principals() != l.principals() {
+		t.Error("principals returned a new typed cache; want the shared one")
+	}
+}
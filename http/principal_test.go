@@ -0,0 +1,27 @@
+package http
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrincipal_CacheFields(t *testing.T) {
+	r := newTestRamCache(t)
+	ctx := context.Background()
+
+	pr := Principal{Id: "sub-1", Login: "jdoe@example.com", Roles: RoleSetFrom("user")}
+	if err := r.PutFields(ctx, "principal::sub-1", pr, 0); err != nil {
+		t.Fatalf("PutFields returned unexpected error: %v", err)
+	}
+	if err := r.PutFields(ctx, "principal::sub-1", Principal{Roles: RoleSetFrom("admin")}, 0, "roles"); err != nil {
+		t.Fatalf("PutFields(roles) returned unexpected error: %v", err)
+	}
+
+	var got Principal
+	if err := r.FetchFields(ctx, "principal::sub-1", &got, "login", "roles"); err != nil {
+		t.Fatalf("FetchFields returned unexpected error: %v", err)
+	}
+	if got.Login != pr.Login || !got.Roles.Contains("admin") || got.Roles.Contains("user") {
+		t.Errorf("FetchFields = %+v; want login %v & roles [admin]", got, pr.Login)
+	}
+}
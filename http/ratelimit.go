@@ -0,0 +1,116 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/TouchBistro/gotham/cache"
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// RateLimitGinHandler returns a gin handler that limits requests with the supplied
+// limiter, keyed by the request context principal alias or, for unauthenticated
+// requests, the client IP (gin ClientIP, configure the engine trusted proxies).
+// Rejected requests are aborted with an HTTP 429 Too Many Requests status code &
+// a Retry-After header. If the limiter fails, the request is allowed
+func RateLimitGinHandler(limiter *cache.RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var pr *Principal
+		if v, ok := c.Get(ContextKeyPrincipal); ok {
+			if p, ok := v.(Principal); ok {
+				pr = &p
+			}
+		}
+
+		res, ok := rateLimit(c.Request.Context(), limiter, rateLimitKey(pr, c.ClientIP()))
+		if !ok {
+			return
+		}
+		setRateLimitHeaders(c.Writer.Header(), res)
+		if !res.Allowed {
+			abortRespondAndLogErrorGin(c, http.StatusTooManyRequests, rateLimitMessage(c.Request))
+		}
+	}
+}
+
+// RateLimitHttpMiddleware returns a net/http middleware that limits requests with the
+// supplied limiter, see RateLimitGinHandler. The client IP is the last X-Forwarded-For
+// address, added by the nearest proxy (e.g. an AWS ALB), else the remote address
+func RateLimitHttpMiddleware(limiter *cache.RateLimiter) Middleware {
+	return MiddlewareFunc(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var pr *Principal
+			if v, err := getValue(r.Context(), ContextKeyPrincipal); err == nil {
+				if p, ok := v.(Principal); ok {
+					pr = &p
+				}
+			}
+
+			res, ok := rateLimit(r.Context(), limiter, rateLimitKey(pr, clientIp(r)))
+			if ok {
+				setRateLimitHeaders(w.Header(), res)
+				if !res.Allowed {
+					abortRespondAndLogErrorHttp(w, r, http.StatusTooManyRequests, rateLimitMessage(r))
+					return
+				}
+			}
+
+			// go to the next handler
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// helper functions
+
+// rateLimit takes a token for key, false if the limiter failed
+func rateLimit(ctx context.Context, limiter *cache.RateLimiter, key string) (cache.RateLimitResult, bool) {
+	res, err := limiter.Allow(ctx, key)
+	if err != nil {
+		log.Warnf("error rate limiting %v, allowing request: %v", key, err)
+		return res, false
+	}
+	return res, true
+}
+
+// rateLimitKey returns the principal alias, or the client IP if there's no principal
+func rateLimitKey(pr *Principal, ip string) string {
+	if pr != nil && pr.Alias != "" {
+		return "principal:" + pr.Alias
+	}
+	return "ip:" + ip
+}
+
+// clientIp returns the last X-Forwarded-For address, else the remote address host
+func clientIp(r *http.Request) string {
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) > 0 {
+		addrs := strings.Split(xff[len(xff)-1], ",")
+		if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+			return ip
+		}
+	}
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+// setRateLimitHeaders sets the rate limit headers, & Retry-After for rejected requests
+func setRateLimitHeaders(h http.Header, res cache.RateLimitResult) {
+	h.Set("X-RateLimit-Limit", strconv.Itoa(res.Limit))
+	h.Set("X-RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(int(math.Ceil(res.RetryAfter.Seconds()))))
+	}
+}
+
+// rateLimitMessage returns the response message for a rejected request
+func rateLimitMessage(r *http.Request) string {
+	return fmt.Sprintf("rate limit exceeded for %v %v", r.Method, r.URL.Path)
+}
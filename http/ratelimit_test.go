@@ -0,0 +1,84 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/gotham/cache"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRateLimiter returns a RateLimiter allowing one request per minute.
+func newTestRateLimiter(t *testing.T) *cache.RateLimiter {
+	t.Helper()
+	l, err := cache.NewRateLimiter(newTestRamCache(t), "test", cache.RateLimit{Limit: 1, Period: time.Minute})
+	if err != nil {
+		t.Fatalf("NewRateLimiter returned unexpected error: %v", err)
+	}
+	return l
+}
+
+func TestRateLimitGinHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.Use(func(c *gin.Context) {
+		if alias := c.GetHeader("X-Test-Alias"); alias != "" {
+			c.Set(ContextKeyPrincipal, Principal{Alias: alias})
+		}
+	})
+	engine.Use(RateLimitGinHandler(newTestRateLimiter(t)))
+	engine.GET("/", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	serve := func(alias string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Test-Alias", alias)
+		rec := httptest.NewRecorder()
+		engine.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("jane"); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	rec := serve("jane")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("Retry-After = %q; want %q", got, "60")
+	}
+	if rec := serve("john"); rec.Code != http.StatusOK {
+		t.Errorf("other principal status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimitHttpMiddleware(t *testing.T) {
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}), RateLimitHttpMiddleware(newTestRateLimiter(t)))
+
+	serve := func(xff string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Forwarded-For", xff)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := serve("1.1.1.1, 10.0.0.1"); rec.Code != http.StatusOK {
+		t.Fatalf("first request status = %d; want %d", rec.Code, http.StatusOK)
+	}
+	// a spoofed leading address does not change the client IP
+	rec := serve("2.2.2.2, 10.0.0.1")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("second request status = %d; want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Error("Retry-After header not set")
+	}
+	if rec := serve("10.0.0.2"); rec.Code != http.StatusOK {
+		t.Errorf("other client status = %d; want %d", rec.Code, http.StatusOK)
+	}
+}
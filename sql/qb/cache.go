@@ -0,0 +1,170 @@
+package qb
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/TouchBistro/gotham/cache"
+	log "github.com/sirupsen/logrus"
+)
+
+// cacheKeyPrefix is the namespace of all keys written by the select cache
+const cacheKeyPrefix = "qb"
+
+// selectCache is a cache-aside store for select results. Results are keyed by the
+// select statement, its arguments & the current generation of every table read by
+// the statement; a write to a table replaces its generation, so all cached results
+// reading the table are no longer found & expire with their TTL
+type selectCache struct {
+	cache  cache.MemoryCache
+	ttl    time.Duration
+	loader *cache.ReadThrough
+}
+
+func newSelectCache(c cache.MemoryCache, ttl time.Duration) *selectCache {
+	return &selectCache{
+		cache:  c,
+		ttl:    ttl,
+		loader: cache.NewReadThrough(c, cache.ReadThroughOptions{}),
+	}
+}
+
+// generationKey returns the cache key of the generation of the supplied table
+func generationKey(table string) string {
+	return cacheKeyPrefix + cache.KeySeparator + table + cache.KeySeparator + "gen"
+}
+
+// newGeneration returns a random table generation
+func newGeneration() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// generation returns the current generation of the table; a table without a
+// generation, e.g. evicted from the cache, is given a new one so results cached
+// before the eviction are never served
+func (c *selectCache) generation(ctx context.Context, table string) (string, error) {
+	var gen string
+	err := c.cache.Fetch(ctx, generationKey(table), &gen)
+	if err == nil {
+		return gen, nil
+	}
+	if !cache.IsCacheMiss(err) {
+		return "", err
+	}
+	return c.invalidate(ctx, table)
+}
+
+// invalidate replaces the generation of the table & returns the new generation
+func (c *selectCache) invalidate(ctx context.Context, table string) (string, error) {
+	gen, err := newGeneration()
+	if err != nil {
+		return "", err
+	}
+	if err := c.cache.Put(ctx, generationKey(table), gen); err != nil {
+		return "", err
+	}
+	return gen, nil
+}
+
+// key returns the cache key for the statement & arguments reading the tables
+func (c *selectCache) key(ctx context.Context, tables []string, stmt string, args []any) (string, error) {
+	h := sha256.New()
+	for _, table := range tables {
+		gen, err := c.generation(ctx, table)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(h, "%v@%v\n", table, gen)
+	}
+	fmt.Fprintf(h, "%v\n", stmt)
+	for _, arg := range args {
+		if valuer, ok := arg.(driver.Valuer); ok {
+			if v, err := valuer.Value(); err == nil {
+				arg = v
+			}
+		}
+		fmt.Fprintf(h, "%#v\n", arg)
+	}
+	return cacheKeyPrefix + cache.KeySeparator + strings.Join(tables, ",") + cache.KeySeparator + hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cachedSelect returns the cached rows for the statement & arguments, loading them
+// with the supplied function on a cache miss. If the cache fails, the rows are loaded
+// from the database
+func cachedSelect[T Entity[T]](ctx context.Context, c *selectCache, tables []string, stmt string, args []any, load func(context.Context) ([]T, error)) ([]T, error) {
+	key, err := c.key(ctx, tables, stmt, args)
+	if err != nil {
+		log.Warnf("error reading select cache generations for %v, selecting from database: %v", tables, err)
+		return load(ctx)
+	}
+
+	var rows []T
+	err = c.loader.GetOrLoad(ctx, key, c.ttl, &rows, func(ctx context.Context) (any, error) {
+		return load(ctx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// cached rows are shared, so callers get their own slice
+	return append(make([]T, 0, len(rows)), rows...), nil
+}
+
+// WithCache enables caching of SelectWhere & Select results in the supplied cache for
+// the supplied TTL. Insert, Update & Delete invalidate the cached results of the table;
+// the Tx variants invalidate before the transaction is committed, so call Invalidate
+// again after the commit
+func (t *Table[T]) WithCache(c cache.MemoryCache, ttl time.Duration) *Table[T] {
+	t.cache = newSelectCache(c, ttl)
+	return t
+}
+
+// Invalidate invalidates the cached select results of this table, including the results
+// of any cached Query reading it; a no-op if caching is not enabled
+func (t Table[T]) Invalidate(ctx context.Context) error {
+	if t.cache == nil {
+		return nil
+	}
+	_, err := t.cache.invalidate(ctx, t.tableName())
+	return err
+}
+
+// invalidateAfterWrite invalidates the cached select results after a write; errors are
+// logged, as the write itself succeeded
+func (t Table[T]) invalidateAfterWrite(ctx context.Context) {
+	if err := t.Invalidate(ctx); err != nil {
+		log.Errorf("error invalidating select cache for %v: %v", t.tableName(), err)
+	}
+}
+
+// tableName returns the schema qualified table name
+func (t Table[T]) tableName() string {
+	return fmt.Sprintf("%v.%v", t.tableMetadata.schema, t.tableMetadata.table)
+}
+
+// WithCache enables caching of SelectWhere & Select results in the supplied cache for
+// the supplied TTL. Cached results are invalidated by writes through a caching Table
+// for any of the tables in the query, using the same cache
+func (q *Query[T]) WithCache(c cache.MemoryCache, ttl time.Duration) *Query[T] {
+	q.cache = newSelectCache(c, ttl)
+	return q
+}
+
+// tableNames returns the schema qualified names of the tables in the query
+func (q Query[T]) tableNames() []string {
+	names := make([]string, 0, len(q.metadata))
+	for _, tmd := range q.metadata {
+		names = append(names, fmt.Sprintf("%v.%v", tmd.schema, tmd.table))
+	}
+	return names
+}
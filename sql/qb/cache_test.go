@@ -0,0 +1,178 @@
+package qb
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TouchBistro/gotham/cache"
+	"github.com/TouchBistro/gotham/sql/sqltest"
+)
+
+// newTestCache returns a RamCache that is stopped at test cleanup.
+func newTestCache(t *testing.T) *cache.RamCache {
+	t.Helper()
+	c, err := cache.NewRamCache(cache.RamConfig{})
+	if err != nil {
+		t.Fatalf("NewRamCache returned unexpected error: %v", err)
+	}
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func TestTable_WithCache_SelectWhere(t *testing.T) {
+	conn := &sqltest.Conn{Cols: []string{"id"}, Rows: [][]driver.Value{{int64(42)}}}
+	db := sqltest.NewDB(conn)
+	defer func() { _ = db.Close() }()
+
+	tbl, err := ForTable[SimpleEntity]("schem.simple")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	tbl.WithCache(newTestCache(t), time.Minute)
+	ctx := context.Background()
+
+	if _, err := tbl.SelectWhere(ctx, db, WhereString("WHERE id = $1"), 42); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// the database now fails, cached results are still served
+	conn.QueryErr = errors.New("database down")
+	result, err := tbl.SelectWhere(ctx, db, WhereString("WHERE id = $1"), 42)
+	if err != nil {
+		t.Fatalf("cached SelectWhere returned unexpected error: %v", err)
+	}
+	if len(result) != 1 || result[0].Id != 42 {
+		t.Errorf("cached SelectWhere = %v; want [{42}]", result)
+	}
+
+	// other arguments are not cached
+	if _, err := tbl.SelectWhere(ctx, db, WhereString("WHERE id = $1"), 43); err == nil {
+		t.Error("SelectWhere with other args returned nil error; want database error")
+	}
+}
+
+func TestTable_WithCache_InvalidatedByWrites(t *testing.T) {
+	writes := map[string]func(*Table[SimpleEntity], *sqltest.Conn) error{
+		"insert": func(tbl *Table[SimpleEntity], conn *sqltest.Conn) error {
+			_, err := tbl.Insert(context.Background(), sqltest.NewDB(conn), SimpleEntity{Id: 1})
+			return err
+		},
+		"update": func(tbl *Table[SimpleEntity], conn *sqltest.Conn) error {
+			_, err := tbl.Update(context.Background(), sqltest.NewDB(conn), SimpleEntity{Id: 1})
+			return err
+		},
+		"delete": func(tbl *Table[SimpleEntity], conn *sqltest.Conn) error {
+			_, err := tbl.Delete(context.Background(), sqltest.NewDB(conn), SimpleEntity{Id: 1})
+			return err
+		},
+	}
+
+	for name, write := range writes {
+		t.Run(name, func(t *testing.T) {
+			conn := &sqltest.Conn{Cols: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}, RowsAffected: 1}
+			db := sqltest.NewDB(conn)
+			defer func() { _ = db.Close() }()
+
+			tbl, _ := ForTable[SimpleEntity]("schem.simple")
+			tbl.WithCache(newTestCache(t), time.Minute)
+			ctx := context.Background()
+
+			_, _ = tbl.Select(ctx, db)
+			if err := write(tbl, conn); err != nil {
+				t.Fatalf("write returned unexpected error: %v", err)
+			}
+
+			conn.Rows = [][]driver.Value{{int64(1)}, {int64(2)}}
+			result, err := tbl.Select(ctx, db)
+			if err != nil {
+				t.Fatalf("Select returned unexpected error: %v", err)
+			}
+			if len(result) != 2 {
+				t.Errorf("Select after %v = %v; want fresh rows", name, result)
+			}
+		})
+	}
+}
+
+func TestTable_WithCache_InvalidatedOnce(t *testing.T) {
+	conn := &sqltest.Conn{Cols: []string{"id"}, Rows: [][]driver.Value{{int64(1)}}, RowsAffected: 1}
+	db := sqltest.NewDB(conn)
+	defer func() { _ = db.Close() }()
+
+	// count the generation puts
+	var invalidations int
+	c := cache.NewInstrumented(newTestCache(t), cache.InstrumentOptions{
+		Trace: func(ctx context.Context, op cache.Op, key string) (context.Context, func(error)) {
+			if op == cache.OpPut && strings.HasSuffix(key, cache.KeySeparator+"gen") {
+				invalidations++
+			}
+			return ctx, func(error) {}
+		},
+	})
+
+	tbl, _ := ForTable[SimpleEntity]("schem.simple")
+	tbl.WithCache(c, time.Minute)
+	ctx := context.Background()
+
+	_, _ = tbl.Select(ctx, db) // creates the generation
+	invalidations = 0
+	if _, err := tbl.Insert(ctx, db, SimpleEntity{Id: 1}); err != nil {
+		t.Fatalf("Insert returned unexpected error: %v", err)
+	}
+	if invalidations != 1 {
+		t.Errorf("Insert invalidated %d times; want 1", invalidations)
+	}
+}
+
+func TestTable_WithCache_ResultsNotShared(t *testing.T) {
+	conn := &sqltest.Conn{Cols: []string{"id"}, Rows: [][]driver.Value{{int64(42)}}}
+	db := sqltest.NewDB(conn)
+	defer func() { _ = db.Close() }()
+
+	tbl, _ := ForTable[SimpleEntity]("schem.simple")
+	tbl.WithCache(newTestCache(t), time.Minute)
+	ctx := context.Background()
+
+	first, _ := tbl.Select(ctx, db)
+	first[0].Id = 0
+
+	second, _ := tbl.Select(ctx, db)
+	if second[0].Id != 42 {
+		t.Errorf("cached Id = %d; want 42", second[0].Id)
+	}
+}
+
+func TestQuery_WithCache_InvalidatedByTable(t *testing.T) {
+	conn := &sqltest.Conn{
+		Cols: []string{"left_id", "left_name", "right_id", "right_name"},
+		Rows: [][]driver.Value{{int64(1), "left_val", int64(2), "right_val"}},
+	}
+	db := sqltest.NewDB(conn)
+	defer func() { _ = db.Close() }()
+
+	c := newTestCache(t)
+	q, _ := ForQuery[CompositeLeftJoinEntity]()
+	q.WithCache(c, time.Minute)
+	ctx := context.Background()
+
+	if _, err := q.Select(ctx, db); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	conn.QueryErr = errors.New("database down")
+	if _, err := q.Select(ctx, db); err != nil {
+		t.Fatalf("cached Select returned unexpected error: %v", err)
+	}
+
+	tables := q.tableNames()
+	tbl, _ := ForTable[SimpleEntity](tables[1])
+	if err := tbl.WithCache(c, time.Minute).Invalidate(ctx); err != nil {
+		t.Fatalf("Invalidate returned unexpected error: %v", err)
+	}
+	if _, err := q.Select(ctx, db); err == nil {
+		t.Error("Select after invalidation returned nil error; want database error")
+	}
+}
@@ -1,161 +1,25 @@
 package qb
 
-// db_mock_test.go provides a minimal database/sql driver mock so that
-// DB-interaction methods on Table and Query can be exercised in unit tests
-// without a live PostgreSQL connection.
+// db_mock_test.go exercises DB-interaction methods on Table and Query against
+// the sqltest driver mock, without a live PostgreSQL connection.
 
 import (
 	"context"
-	"database/sql"
 	"database/sql/driver"
 	"errors"
-	"io"
 	"testing"
-)
-
-// --- mock driver ---
-
-const mockDriverName = "qb_test_mock"
-
-func init() {
-	sql.Register(mockDriverName, &mockDriver{})
-}
-
-type mockDriver struct {
-	conn *mockConn
-}
-
-func (d *mockDriver) Open(name string) (driver.Conn, error) {
-	if d.conn != nil {
-		return d.conn, nil
-	}
-	return &mockConn{}, nil
-}
-
-type mockConn struct {
-	execErr  error
-	queryErr error
-	rows     [][]driver.Value
-	cols     []string
-	// rowsAffected returned by Exec
-	rowsAffected int64
-	beginErr     error
-	commitErr    error
-	rollbackErr  error
-}
-
-func (c *mockConn) Prepare(query string) (driver.Stmt, error) {
-	return &mockStmt{conn: c}, nil
-}
-
-func (c *mockConn) Close() error  { return nil }
-func (c *mockConn) Begin() (driver.Tx, error) {
-	if c.beginErr != nil {
-		return nil, c.beginErr
-	}
-	return &mockTx{conn: c}, nil
-}
-
-type mockTx struct {
-	conn *mockConn
-}
-
-func (t *mockTx) Commit() error {
-	if t.conn.commitErr != nil {
-		return t.conn.commitErr
-	}
-	return nil
-}
-
-func (t *mockTx) Rollback() error {
-	if t.conn.rollbackErr != nil {
-		return t.conn.rollbackErr
-	}
-	return nil
-}
 
-type mockStmt struct {
-	conn *mockConn
-}
-
-func (s *mockStmt) Close() error { return nil }
-func (s *mockStmt) NumInput() int { return -1 } // variadic
-
-func (s *mockStmt) Exec(args []driver.Value) (driver.Result, error) {
-	if s.conn.execErr != nil {
-		return nil, s.conn.execErr
-	}
-	return &mockResult{rowsAffected: s.conn.rowsAffected}, nil
-}
-
-func (s *mockStmt) Query(args []driver.Value) (driver.Rows, error) {
-	if s.conn.queryErr != nil {
-		return nil, s.conn.queryErr
-	}
-	return &mockRows{
-		cols:    s.conn.cols,
-		data:    s.conn.rows,
-		current: -1,
-	}, nil
-}
-
-type mockResult struct {
-	rowsAffected int64
-}
-
-func (r *mockResult) LastInsertId() (int64, error) { return 0, nil }
-func (r *mockResult) RowsAffected() (int64, error) { return r.rowsAffected, nil }
-
-type mockRows struct {
-	cols    []string
-	data    [][]driver.Value
-	current int
-}
-
-func (r *mockRows) Columns() []string { return r.cols }
-
-func (r *mockRows) Close() error { return nil }
-
-func (r *mockRows) Next(dest []driver.Value) error {
-	r.current++
-	if r.current >= len(r.data) {
-		return io.EOF
-	}
-	copy(dest, r.data[r.current])
-	return nil
-}
-
-// --- helper: open mock db ---
-
-func newMockDB(conn *mockConn) *sql.DB {
-	// Each test needs a unique DSN to get its own connection from sql's pool.
-	// We use a shared global driver and inject via conn field.
-	drv := sql.Drivers()
-	_ = drv
-	// Register a new driver name each call is messy; instead we use a
-	// driver that always returns the supplied conn.
-	drvName := mockDriverName + "_" + randomID()
-	sql.Register(drvName, &mockDriver{conn: conn})
-	db, _ := sql.Open(drvName, "test")
-	db.SetMaxOpenConns(1)
-	return db
-}
-
-var mockIDCounter int
-
-func randomID() string {
-	mockIDCounter++
-	return string(rune('a' + mockIDCounter))
-}
+	"github.com/TouchBistro/gotham/sql/sqltest"
+)
 
 // --- tests using mock DB ---
 
 func TestSelectWhere_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id", "name", "description", "transation_type"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"id", "name", "description", "transation_type"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -174,11 +38,11 @@ func TestSelectWhere_EmptyRows(t *testing.T) {
 }
 
 func TestSelect_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id", "name", "description", "transation_type"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"id", "name", "description", "transation_type"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -197,10 +61,10 @@ func TestSelect_EmptyRows(t *testing.T) {
 }
 
 func TestSelectWhere_QueryError(t *testing.T) {
-	conn := &mockConn{
-		queryErr: errors.New("query error"),
+	conn := &sqltest.Conn{
+		QueryErr: errors.New("query error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -216,11 +80,11 @@ func TestSelectWhere_QueryError(t *testing.T) {
 }
 
 func TestSelectWhere_WithArgs(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id", "name", "description", "transation_type"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"id", "name", "description", "transation_type"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -239,11 +103,11 @@ func TestSelectWhere_WithArgs(t *testing.T) {
 }
 
 func TestSelectTx_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id", "name", "description", "transation_type"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"id", "name", "description", "transation_type"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -270,11 +134,11 @@ func TestSelectTx_EmptyRows(t *testing.T) {
 }
 
 func TestSelectWhereTx_WithArgs(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id", "name", "description", "transation_type"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"id", "name", "description", "transation_type"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -301,10 +165,10 @@ func TestSelectWhereTx_WithArgs(t *testing.T) {
 }
 
 func TestInsert_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -324,10 +188,10 @@ func TestInsert_NonEmptyEntities(t *testing.T) {
 }
 
 func TestInsertTx_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -355,10 +219,10 @@ func TestInsertTx_NonEmptyEntities(t *testing.T) {
 }
 
 func TestUpdate_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -378,10 +242,10 @@ func TestUpdate_NonEmptyEntities(t *testing.T) {
 }
 
 func TestUpdateTx_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -409,10 +273,10 @@ func TestUpdateTx_NonEmptyEntities(t *testing.T) {
 }
 
 func TestDelete_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -432,10 +296,10 @@ func TestDelete_NonEmptyEntities(t *testing.T) {
 }
 
 func TestDeleteTx_NonEmptyEntities(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -464,10 +328,10 @@ func TestDeleteTx_NonEmptyEntities(t *testing.T) {
 
 // Test error propagation from InsertTx -> Insert
 func TestInsert_ExecError_PropagatesError(t *testing.T) {
-	conn := &mockConn{
-		execErr: errors.New("exec error"),
+	conn := &sqltest.Conn{
+		ExecErr: errors.New("exec error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -485,10 +349,10 @@ func TestInsert_ExecError_PropagatesError(t *testing.T) {
 
 // Test error propagation from UpdateTx -> Update
 func TestUpdate_ExecError_PropagatesError(t *testing.T) {
-	conn := &mockConn{
-		execErr: errors.New("exec error"),
+	conn := &sqltest.Conn{
+		ExecErr: errors.New("exec error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -506,10 +370,10 @@ func TestUpdate_ExecError_PropagatesError(t *testing.T) {
 
 // Test error propagation from DeleteTx -> Delete
 func TestDelete_ExecError_PropagatesError(t *testing.T) {
-	conn := &mockConn{
-		execErr: errors.New("exec error"),
+	conn := &sqltest.Conn{
+		ExecErr: errors.New("exec error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -527,10 +391,10 @@ func TestDelete_ExecError_PropagatesError(t *testing.T) {
 
 // Test SelectWhereTx with args (covers the len(args)>0 branch)
 func TestSelectWhereTx_WithArgs_QueryError(t *testing.T) {
-	conn := &mockConn{
-		queryErr: errors.New("query error"),
+	conn := &sqltest.Conn{
+		QueryErr: errors.New("query error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -563,13 +427,13 @@ func (s SimpleEntity) Key() PrimaryKey              { return s.Id }
 func (s SimpleEntity) Equals(other SimpleEntity) bool { return s.Id == other.Id }
 
 func TestSelectWhere_WithRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"id"},
-		rows: [][]driver.Value{
+	conn := &sqltest.Conn{
+		Cols: []string{"id"},
+		Rows: [][]driver.Value{
 			{int64(42)},
 		},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[SimpleEntity]("schem.simple")
@@ -592,12 +456,12 @@ func TestSelectWhere_WithRows(t *testing.T) {
 
 // Test SelectWhere commit error path
 func TestSelectWhere_CommitError(t *testing.T) {
-	conn := &mockConn{
-		cols:      []string{"id", "name", "description", "transation_type"},
-		rows:      [][]driver.Value{},
-		commitErr: errors.New("commit error"),
+	conn := &sqltest.Conn{
+		Cols:      []string{"id", "name", "description", "transation_type"},
+		Rows:      [][]driver.Value{},
+		CommitErr: errors.New("commit error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -614,11 +478,11 @@ func TestSelectWhere_CommitError(t *testing.T) {
 
 // Test Insert commit error path
 func TestInsert_CommitError(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
-		commitErr:    errors.New("commit error"),
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
+		CommitErr:    errors.New("commit error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -636,11 +500,11 @@ func TestInsert_CommitError(t *testing.T) {
 
 // Test Update commit error path
 func TestUpdate_CommitError(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
-		commitErr:    errors.New("commit error"),
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
+		CommitErr:    errors.New("commit error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -658,11 +522,11 @@ func TestUpdate_CommitError(t *testing.T) {
 
 // Test Delete commit error path
 func TestDelete_CommitError(t *testing.T) {
-	conn := &mockConn{
-		rowsAffected: 1,
-		commitErr:    errors.New("commit error"),
+	conn := &sqltest.Conn{
+		RowsAffected: 1,
+		CommitErr:    errors.New("commit error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[Test]("schem.tab")
@@ -681,11 +545,11 @@ func TestDelete_CommitError(t *testing.T) {
 // --- Query-level mock DB tests ---
 
 func TestQuery_SelectWhere_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -704,11 +568,11 @@ func TestQuery_SelectWhere_EmptyRows(t *testing.T) {
 }
 
 func TestQuery_Select_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -727,11 +591,11 @@ func TestQuery_Select_EmptyRows(t *testing.T) {
 }
 
 func TestQuery_SelectTx_EmptyRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -758,10 +622,10 @@ func TestQuery_SelectTx_EmptyRows(t *testing.T) {
 }
 
 func TestQuery_SelectWhere_QueryError(t *testing.T) {
-	conn := &mockConn{
-		queryErr: errors.New("query error"),
+	conn := &sqltest.Conn{
+		QueryErr: errors.New("query error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -777,12 +641,12 @@ func TestQuery_SelectWhere_QueryError(t *testing.T) {
 }
 
 func TestQuery_SelectWhere_CommitError(t *testing.T) {
-	conn := &mockConn{
-		cols:      []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
-		rows:      [][]driver.Value{},
-		commitErr: errors.New("commit error"),
+	conn := &sqltest.Conn{
+		Cols:      []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
+		Rows:      [][]driver.Value{},
+		CommitErr: errors.New("commit error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -798,11 +662,11 @@ func TestQuery_SelectWhere_CommitError(t *testing.T) {
 }
 
 func TestQuery_SelectWhereTx_WithArgs(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
-		rows: [][]driver.Value{},
+	conn := &sqltest.Conn{
+		Cols: []string{"left.left_id", "left.left_name", "right.right_id", "right.right_name"},
+		Rows: [][]driver.Value{},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -830,13 +694,13 @@ func TestQuery_SelectWhereTx_WithArgs(t *testing.T) {
 
 // Test with rows returned to exercise the Query mapper function
 func TestQuery_SelectWhere_WithRows(t *testing.T) {
-	conn := &mockConn{
-		cols: []string{"left_id", "left_name", "right_id", "right_name"},
-		rows: [][]driver.Value{
+	conn := &sqltest.Conn{
+		Cols: []string{"left_id", "left_name", "right_id", "right_name"},
+		Rows: [][]driver.Value{
 			{int64(1), "left_val", int64(2), "right_val"},
 		},
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	q, err := ForQuery[CompositeLeftJoinEntity]()
@@ -874,10 +738,10 @@ func TestForTable_EmptyStruct_Error(t *testing.T) {
 
 // Test SelectWhere error path (rollback)
 func TestSelectWhere_RollbackOnQueryError(t *testing.T) {
-	conn := &mockConn{
-		queryErr: errors.New("query error"),
+	conn := &sqltest.Conn{
+		QueryErr: errors.New("query error"),
 	}
-	db := newMockDB(conn)
+	db := sqltest.NewDB(conn)
 	defer func() { _ = db.Close() }()
 
 	tbl, err := ForTable[SimpleEntity]("schem.simple")
@@ -37,10 +37,22 @@ type Query[T Entity[T]] struct {
 	selectStmt      string
 	selectBatchSize int
 	mapper          SqlRowsToEntityMapperFn[T]
+	cache           *selectCache // cache for select results, nil if caching is not enabled
 }
 
 // SelectWhere selects all rows from the underlying database query after applying the supplied "Where" condition & arguments into this Table object
 func (q Query[T]) SelectWhere(ctx context.Context, conn *sql.DB, where WhereClause, args ...any) ([]T, error) {
+	if q.cache != nil {
+		stmt := fmt.Sprintf("%v %v", q.selectStmt, where.WhereClause())
+		return cachedSelect(ctx, q.cache, q.tableNames(), stmt, args, func(ctx context.Context) ([]T, error) {
+			return q.selectWhere(ctx, conn, where, args...)
+		})
+	}
+	return q.selectWhere(ctx, conn, where, args...)
+}
+
+// selectWhere selects the rows from the database, see SelectWhere
+func (q Query[T]) selectWhere(ctx context.Context, conn *sql.DB, where WhereClause, args ...any) ([]T, error) {
 
 	// start transaction
 	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
@@ -137,6 +137,8 @@ type Table[T Entity[T]] struct {
 	numUpdatableCols int
 	// indicates if an updatable column at index (updatable only) is an array
 	updatableColTypeArray []bool
+	// cache for select results, nil if caching is not enabled
+	cache *selectCache
 }
 
 // Metadata returns column metadata for this Table & satisfied ObjectWithMetadata interface
@@ -148,6 +150,17 @@ type Table[T Entity[T]] struct {
 
 // SelectWhere selects all rows from the underlying database table after applying the supplied "Where" condition & arguments into this Table object
 func (t Table[T]) SelectWhere(ctx context.Context, conn *sql.DB, where WhereClause, args ...any) ([]T, error) {
+	if t.cache != nil {
+		stmt := fmt.Sprintf("%v %v", t.selectStmt, where.WhereClause())
+		return cachedSelect(ctx, t.cache, []string{t.tableName()}, stmt, args, func(ctx context.Context) ([]T, error) {
+			return t.selectWhere(ctx, conn, where, args...)
+		})
+	}
+	return t.selectWhere(ctx, conn, where, args...)
+}
+
+// selectWhere selects the rows from the database, see SelectWhere
+func (t Table[T]) selectWhere(ctx context.Context, conn *sql.DB, where WhereClause, args ...any) ([]T, error) {
 
 	// start transaction
 	tx, err := conn.BeginTx(ctx, &sql.TxOptions{})
@@ -223,7 +236,7 @@ func (t Table[T]) Insert(ctx context.Context, conn *sql.DB, entities ...T) (int6
 		return 0, err
 	}
 
-	if rowsAffected, err := t.InsertTx(ctx, tx, entities...); err != nil {
+	if rowsAffected, err := t.insertTx(ctx, tx, entities...); err != nil {
 		if err2 := tx.Rollback(); err2 != nil {
 			log.Error(err2)
 		}
@@ -233,12 +246,27 @@ func (t Table[T]) Insert(ctx context.Context, conn *sql.DB, entities ...T) (int6
 			log.Error(err)
 			return 0, err
 		}
+		t.invalidateAfterWrite(ctx)
 		return rowsAffected, nil
 	}
 }
 
 // Insert uses an auto-generated batch INSERT DML template & executes it on the supplied entities as array parameters  on the given transaction context
 func (t Table[T]) InsertTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
+	if len(entities) == 0 {
+		return 0, nil
+	}
+
+	rowsAffected, err := t.insertTx(ctx, tx, entities...)
+	if err != nil {
+		return 0, err
+	}
+	t.invalidateAfterWrite(ctx)
+	return rowsAffected, nil
+}
+
+// insertTx executes the INSERT on the given transaction context without invalidating the select cache
+func (t Table[T]) insertTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
 
 	if len(entities) == 0 {
 		return 0, nil
@@ -324,7 +352,7 @@ func (t Table[T]) Update(ctx context.Context, conn *sql.DB, entities ...T) (int6
 		return 0, err
 	}
 
-	if rowsAffected, err := t.UpdateTx(ctx, tx, entities...); err != nil {
+	if rowsAffected, err := t.updateTx(ctx, tx, entities...); err != nil {
 		if err2 := tx.Rollback(); err2 != nil {
 			log.Error(err2)
 		}
@@ -334,12 +362,27 @@ func (t Table[T]) Update(ctx context.Context, conn *sql.DB, entities ...T) (int6
 			log.Error(err)
 			return 0, err
 		}
+		t.invalidateAfterWrite(ctx)
 		return rowsAffected, nil
 	}
 }
 
 // Update uses an auto-generated batch UPDATE DML template for this table & executes it on the supplied entities as array parameters on the given transaction context
 func (t Table[T]) UpdateTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
+	if len(entities) == 0 {
+		return 0, nil
+	}
+
+	rowsAffected, err := t.updateTx(ctx, tx, entities...)
+	if err != nil {
+		return 0, err
+	}
+	t.invalidateAfterWrite(ctx)
+	return rowsAffected, nil
+}
+
+// updateTx executes the UPDATE on the given transaction context without invalidating the select cache
+func (t Table[T]) updateTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
 
 	if len(entities) == 0 {
 		return 0, nil
@@ -422,7 +465,7 @@ func (t Table[T]) Delete(ctx context.Context, conn *sql.DB, entities ...T) (int6
 		return 0, err
 	}
 
-	if rowsAffected, err := t.DeleteTx(ctx, tx, entities...); err != nil {
+	if rowsAffected, err := t.deleteTx(ctx, tx, entities...); err != nil {
 		if err2 := tx.Rollback(); err2 != nil {
 			log.Error(err2)
 		}
@@ -432,12 +475,27 @@ func (t Table[T]) Delete(ctx context.Context, conn *sql.DB, entities ...T) (int6
 			log.Error(err)
 			return 0, err
 		}
+		t.invalidateAfterWrite(ctx)
 		return rowsAffected, nil
 	}
 }
 
 // DeleteTx uses an auto-generated batch DELETE DML statement & executes it on the supplied entities as array parametes on the given transaction context
 func (t Table[T]) DeleteTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
+	if len(entities) == 0 {
+		return 0, nil
+	}
+
+	rowsAffected, err := t.deleteTx(ctx, tx, entities...)
+	if err != nil {
+		return 0, err
+	}
+	t.invalidateAfterWrite(ctx)
+	return rowsAffected, nil
+}
+
+// deleteTx executes the DELETE on the given transaction context without invalidating the select cache
+func (t Table[T]) deleteTx(ctx context.Context, tx *sql.Tx, entities ...T) (int64, error) {
 
 	if len(entities) == 0 {
 		return 0, nil
@@ -0,0 +1,116 @@
+// Package sqltest provides a minimal database/sql driver so that code talking to a
+// *sql.DB can be exercised in unit tests without a live database connection.
+package sqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strconv"
+	"sync/atomic"
+)
+
+// driverName prefixes the name every opened db registers its driver under.
+const driverName = "sqltest"
+
+// drivers counts the registered drivers so each db gets a unique name.
+var drivers atomic.Int64
+
+// Conn is a driver.Conn answering every query with its rows and every exec with its
+// affected rows, recording the last statement and arguments.
+type Conn struct {
+	Cols []string
+	Rows [][]driver.Value
+	// RowsAffected returned by Exec
+	RowsAffected int64
+
+	ExecErr     error
+	QueryErr    error
+	BeginErr    error
+	CommitErr   error
+	RollbackErr error
+
+	// Query and Args of the last exec or query
+	Query string
+	Args  []driver.Value
+}
+
+// NewDB returns a *sql.DB holding a single connection backed by the conn.
+func NewDB(conn *Conn) *sql.DB {
+	// sql.Register panics on duplicates, so every db gets its own driver name
+	name := driverName + "_" + strconv.FormatInt(drivers.Add(1), 10)
+	sql.Register(name, dbDriver{conn})
+	db, _ := sql.Open(name, "")
+	db.SetMaxOpenConns(1)
+	return db
+}
+
+// dbDriver opens its conn.
+type dbDriver struct{ conn *Conn }
+
+func (d dbDriver) Open(name string) (driver.Conn, error) { return d.conn, nil }
+
+func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: c, query: query}, nil
+}
+
+func (c *Conn) Close() error { return nil }
+
+func (c *Conn) Begin() (driver.Tx, error) {
+	if c.BeginErr != nil {
+		return nil, c.BeginErr
+	}
+	return tx{c}, nil
+}
+
+type tx struct{ conn *Conn }
+
+func (t tx) Commit() error   { return t.conn.CommitErr }
+func (t tx) Rollback() error { return t.conn.RollbackErr }
+
+type stmt struct {
+	conn  *Conn
+	query string
+}
+
+func (s *stmt) Close() error  { return nil }
+func (s *stmt) NumInput() int { return -1 } // variadic
+
+func (s *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.conn.Query, s.conn.Args = s.query, args
+	if s.conn.ExecErr != nil {
+		return nil, s.conn.ExecErr
+	}
+	return result{s.conn.RowsAffected}, nil
+}
+
+func (s *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.conn.Query, s.conn.Args = s.query, args
+	if s.conn.QueryErr != nil {
+		return nil, s.conn.QueryErr
+	}
+	return &rows{cols: s.conn.Cols, data: s.conn.Rows}, nil
+}
+
+type result struct{ rowsAffected int64 }
+
+func (r result) LastInsertId() (int64, error) { return 0, nil }
+func (r result) RowsAffected() (int64, error) { return r.rowsAffected, nil }
+
+type rows struct {
+	cols []string
+	data [][]driver.Value
+	next int
+}
+
+func (r *rows) Columns() []string { return r.cols }
+func (r *rows) Close() error      { return nil }
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.data) {
+		return io.EOF
+	}
+	copy(dest, r.data[r.next])
+	r.next++
+	return nil
+}
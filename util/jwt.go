@@ -6,13 +6,18 @@ import ( // "log"
 )
 
 // ClaimsFromJwt fetches the specified claim values from the supplied jwt/jws.
-// No verification is performed currently
+// No verification is performed, verified tokens are passed to ClaimsFromToken
 func ClaimsFromJwt(jwtStr string, claims ...string) (map[string]any, error) {
 
 	token, err := jwt.Parse([]byte(jwtStr), jwt.WithVerify(false))
 	if err != nil {
 		return nil, err
 	}
+	return ClaimsFromToken(token, claims...), nil
+}
+
+// ClaimsFromToken fetches the specified claim values from the supplied parsed token
+func ClaimsFromToken(token jwt.Token, claims ...string) map[string]any {
 
 	m := make(map[string]any)
 	m["exp"] = token.Expiration()
@@ -29,12 +34,12 @@ func ClaimsFromJwt(jwtStr string, claims ...string) (map[string]any, error) {
 				m[k] = v
 			}
 		}
-		return m, nil
+		return m
 	}
 
 	// if not cliams supplied, return all + exp
 	m = token.PrivateClaims()
 	m["exp"] = token.Expiration()
 	m["sub"] = token.Subject()
-	return m, nil
+	return m
 }